@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/diff"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// diffOutput is the JSON output of the diff subcommand
+type diffOutput struct {
+	*diff.Report
+	Detections []wappalyzer.DetectionChange `json:"detections,omitempty"`
+}
+
+// runDiff implements the diff subcommand and returns the exit code: 0 unless
+// -fail-on-change is set and something changed, which gives 1, and 2 when
+// the fingerprints or the corpus could not be loaded
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	formatFlag := flags.String("format", "text", "Output format: text or json")
+	corpusFlag := flags.String("corpus", "", "Directory of recorded responses (.http files) to replay through both sets")
+	failFlag := flags.Bool("fail-on-change", false, "Exit with 1 when detections of the corpus change, or without a corpus when the fingerprints do")
+	cacheDirFlag := flags.String("cache-dir", "", "Custom cache directory for downloaded fingerprints")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fingerprints-manager diff [flags] <old> <new>\n\n")
+		fmt.Fprintf(flags.Output(), "Compares two fingerprint sets, each a technologies file, a fingerprints\ndirectory, a zip or tar archive of one or a fingerprints archive URL.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *formatFlag != "text" && *formatFlag != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q\n", *formatFlag)
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	config := downloader.DefaultConfig()
+	if *cacheDirFlag != "" {
+		config.CacheDir = *cacheDirFlag
+	}
+	oldSource, newSource := pathSource(flags.Arg(0)), pathSource(flags.Arg(1))
+
+	// Compare the definitions
+	oldTechnologies, oldCategories, err := loadSource(config, oldSource)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", flags.Arg(0), err)
+		return 2
+	}
+	newTechnologies, newCategories, err := loadSource(config, newSource)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", flags.Arg(1), err)
+		return 2
+	}
+
+	report, err := diff.Compare(oldTechnologies, newTechnologies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing fingerprints: %v\n", err)
+		return 2
+	}
+	output := diffOutput{Report: report}
+	replayed := -1
+
+	// Replay the corpus through both sets
+	if *corpusFlag != "" {
+		responses, err := wappalyzer.LoadCorpus(*corpusFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+
+		wappalyzer.SetDownloaderConfig(config)
+		oldInstance, err := wappalyzer.New(wappalyzer.WithSources(oldSource))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", flags.Arg(0), err)
+			return 2
+		}
+		newInstance, err := wappalyzer.New(wappalyzer.WithSources(newSource))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", flags.Arg(1), err)
+			return 2
+		}
+
+		output.Detections = wappalyzer.CompareDetections(oldInstance, newInstance, responses)
+		replayed = len(responses)
+	}
+
+	if *formatFlag == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(output)
+	} else {
+		printDiff(output, categoryNames(oldCategories, newCategories), replayed)
+	}
+
+	changed := !report.Empty()
+	if *corpusFlag != "" {
+		changed = len(output.Detections) > 0
+	}
+	if *failFlag && changed {
+		return 1
+	}
+	return 0
+}
+
+// printDiff prints the differences for a human reader. Replayed is the
+// number of responses replayed, or -1 without a corpus.
+func printDiff(output diffOutput, categories map[string]string, replayed int) {
+	for _, name := range output.Added {
+		fmt.Printf("+ %s\n", name)
+	}
+	for _, name := range output.Removed {
+		fmt.Printf("- %s\n", name)
+	}
+	for _, change := range output.Changed {
+		fmt.Printf("~ %s\n", change.Name)
+		for _, field := range change.Fields {
+			for _, value := range field.Removed {
+				fmt.Printf("    %s: - %s\n", field.Field, describeValue(field.Field, value, categories))
+			}
+			for _, value := range field.Added {
+				fmt.Printf("    %s: + %s\n", field.Field, describeValue(field.Field, value, categories))
+			}
+		}
+	}
+
+	if replayed >= 0 {
+		if len(output.Added)+len(output.Removed)+len(output.Changed) > 0 {
+			fmt.Println()
+		}
+		for _, change := range output.Detections {
+			var parts []string
+			for _, name := range change.Appeared {
+				parts = append(parts, "+"+name)
+			}
+			for _, name := range change.Disappeared {
+				parts = append(parts, "-"+name)
+			}
+			fmt.Printf("%s: %s\n", change.Response, strings.Join(parts, " "))
+		}
+	}
+
+	fmt.Printf("%d added, %d removed, %d changed", len(output.Added), len(output.Removed), len(output.Changed))
+	if replayed >= 0 {
+		fmt.Printf(", detections changed on %d of %d responses", len(output.Detections), replayed)
+	}
+	fmt.Println()
+}
+
+// describeValue quotes a field value, naming the categories of category IDs
+func describeValue(field, value string, categories map[string]string) string {
+	if field == "cats" || field == "requiresCategory" {
+		if name, ok := categories[value]; ok {
+			return fmt.Sprintf("%s (%s)", value, name)
+		}
+		return value
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// categoryNames maps category IDs to their names, preferring the new set
+func categoryNames(sets ...map[string]json.RawMessage) map[string]string {
+	names := make(map[string]string)
+	for _, categories := range sets {
+		for id, data := range categories {
+			var category struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(data, &category) == nil && category.Name != "" {
+				names[id] = category.Name
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/lint"
+)
+
+// runLint implements the lint subcommand and returns the exit code: 0 when
+// the fingerprints are valid, 1 when issues were found and 2 when they
+// could not be loaded
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	formatFlag := flags.String("format", "text", "Output format: text or json")
+	categoriesFlag := flags.String("categories", "", "categories.json to check category IDs against, when linting a single technologies file")
+	strictFlag := flags.Bool("strict", false, "Fail on warnings too")
+	cacheDirFlag := flags.String("cache-dir", "", "Custom cache directory for fingerprints")
+	urlFlag := flags.String("url", "", "Custom URL to download fingerprints from")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fingerprints-manager lint [flags] [path]\n\n")
+		fmt.Fprintf(flags.Output(), "Lints a technologies file, a fingerprints directory, a zip or tar archive of\none or a fingerprints archive URL, or the cached fingerprints when no path is\ngiven.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *formatFlag != "text" && *formatFlag != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q\n", *formatFlag)
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Load the fingerprints to lint
+	config := downloader.DefaultConfig()
+	if *cacheDirFlag != "" {
+		config.CacheDir = *cacheDirFlag
+	}
+	if *urlFlag != "" {
+		config.ReleaseURL = *urlFlag
+	}
+
+	source := downloader.Source{URL: config.ReleaseURL}
+	if flags.NArg() == 1 {
+		source = pathSource(flags.Arg(0))
+	}
+	technologies, categories, err := loadSource(config, source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading fingerprints: %v\n", err)
+		return 2
+	}
+
+	if *categoriesFlag != "" {
+		data, err := os.ReadFile(*categoriesFlag)
+		if err == nil {
+			err = json.Unmarshal(data, &categories)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading categories: %v\n", err)
+			return 2
+		}
+	}
+
+	report := lint.Lint(technologies, categories)
+
+	if *formatFlag == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(report)
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Println(issue)
+		}
+		if categories == nil {
+			fmt.Println("No categories available, category IDs were not checked")
+		}
+		fmt.Printf("%d technologies, %d errors, %d warnings\n", report.Technologies, report.Errors, report.Warnings)
+	}
+
+	if !report.OK() || (*strictFlag && report.Warnings > 0) {
+		return 1
+	}
+	return 0
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
 )
 
 var (
@@ -20,6 +22,12 @@ var (
 	statusFlag     = flag.Bool("status", false, "Show status of the fingerprints cache")
 	versionFlag    = flag.Bool("version", false, "Show version information")
 	noDownloadFlag = flag.Bool("no-download", false, "Don't download fingerprints, just show or clear cache")
+	compileFlag    = flag.String("compile", "", "Compile the fingerprints into a binary database at the given path")
+	snapshotFlag   = flag.String("snapshot", "", "Write the fingerprints as an embeddable snapshot into the given directory")
+	sha256Flag     = flag.String("sha256", "", "SHA-256 digest the downloaded archive must match")
+	checksumsFlag  = flag.String("checksums-url", "", "URL of a sha256sum checksums file listing the archive")
+	signatureFlag  = flag.String("signature-url", "", "URL of a detached signature of the archive")
+	publicKeyFlag  = flag.String("public-key", "", "Public key (minisign or base64 ed25519) or key file to check signatures with")
 )
 
 // Version information
@@ -30,6 +38,20 @@ const (
 )
 
 func main() {
+	// Subcommands have flags of their own
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "test":
+			os.Exit(runTest(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "releases":
+			os.Exit(runReleases(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
 	// Show version and exit if requested
@@ -57,6 +79,15 @@ func main() {
 
 	config.ForceDownload = *forceFlag
 
+	// Integrity verification
+	config.SHA256 = *sha256Flag
+	config.ChecksumURL = *checksumsFlag
+	config.SignatureURL = *signatureFlag
+	config.PublicKey = *publicKeyFlag
+	if data, err := os.ReadFile(*publicKeyFlag); err == nil {
+		config.PublicKey = string(data)
+	}
+
 	// Create cache dir if it doesn't exist
 	if err := os.MkdirAll(config.CacheDir, 0755); err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating cache directory: %v\n", err)
@@ -95,46 +126,151 @@ func main() {
 
 	fmt.Println("Fingerprints downloaded and cached successfully!")
 	showCacheStatus(config)
+
+	// Compile a binary database if requested
+	if *compileFlag != "" {
+		if err := compileDatabase(config, *compileFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error compiling database: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nCompiled database written to %s\n", *compileFlag)
+	}
+
+	// Write an embeddable snapshot if requested
+	if *snapshotFlag != "" {
+		if err := writeSnapshot(config, *snapshotFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing snapshot: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nSnapshot written to %s\n", *snapshotFlag)
+	}
 }
 
-// clearCache removes all cached fingerprint files
-func clearCache(config *downloader.Config) {
-	files := []string{
-		filepath.Join(config.CacheDir, "technologies.json"),
-		filepath.Join(config.CacheDir, "categories.json"),
-		filepath.Join(config.CacheDir, "groups.json"),
+// writeSnapshot copies the cached fingerprints into dir together with a
+// snapshot.json describing the release they came from
+func writeSnapshot(config *downloader.Config, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
 	}
 
-	for _, file := range files {
-		if _, err := os.Stat(file); err == nil {
-			if err := os.Remove(file); err != nil {
-				fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", file, err)
-			} else {
-				fmt.Printf("Removed %s\n", file)
-			}
+	cacheDir, err := downloader.CurrentCacheDir(config)
+	if err != nil {
+		return err
+	}
+
+	names := []string{"technologies.json", "categories.json", "groups.json"}
+	files := make(map[string][]byte, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(cacheDir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read cached %s: %v", name, err)
+		}
+		files[name] = data
+	}
+
+	// An empty snapshot would build, and leave offline scanners with nothing
+	technologies, err := downloader.ParseTechnologies(files["technologies.json"])
+	if err != nil {
+		return fmt.Errorf("invalid cached technologies.json: %v", err)
+	}
+	if len(technologies) == 0 {
+		return fmt.Errorf("the cached fingerprints contain no technologies, refusing to write an empty snapshot")
+	}
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), files[name], 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
 		}
 	}
+
+	// The release tag is informational, don't fail the snapshot without it
+	version, err := downloader.ResolveReleaseTag(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	info, err := json.MarshalIndent(map[string]string{
+		"version": version,
+		"date":    time.Now().UTC().Format(time.RFC3339),
+		"source":  config.ReleaseURL,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, "snapshot.json"), append(info, '\n'), 0644)
 }
 
-// showCacheStatus displays information about the current cache
-func showCacheStatus(config *downloader.Config) {
-	files := []string{
-		filepath.Join(config.CacheDir, "technologies.json"),
-		filepath.Join(config.CacheDir, "categories.json"),
-		filepath.Join(config.CacheDir, "groups.json"),
+// compileDatabase compiles the cached fingerprints into a binary database
+func compileDatabase(config *downloader.Config, path string) error {
+	// The fingerprints were just downloaded, build from the cache as is
+	cached := *config
+	cached.ForceDownload = false
+	cached.Offline = true
+
+	wappalyzer.SetDownloaderConfig(&cached)
+	w, err := wappalyzer.New()
+	if err != nil {
+		return err
 	}
 
+	// Write next to the destination and rename, so readers never see a partial file
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", tmpPath, err)
+	}
+
+	if err := w.WriteDatabase(file, config.ReleaseURL); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// clearCache removes all cached fingerprint files
+func clearCache(config *downloader.Config) {
+	if err := downloader.ClearCache(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error clearing cache: %v\n", err)
+		return
+	}
+	fmt.Printf("Removed cached fingerprints from %s\n", config.CacheDir)
+}
+
+// showCacheStatus displays information about the current cache
+func showCacheStatus(config *downloader.Config) {
 	fmt.Printf("Cache directory: %s\n", config.CacheDir)
 	fmt.Printf("Cache expiry: %v\n", config.CacheExpiry)
 	fmt.Printf("Download URL: %s\n", config.ReleaseURL)
+
+	cacheDir, err := downloader.CurrentCacheDir(config)
+	if err != nil {
+		fmt.Println("\nStatus: Cache is incomplete or missing")
+		return
+	}
+
+	fmt.Printf("Cache version: %s\n", filepath.Base(cacheDir))
+	if pinned := downloader.PinnedRelease(config); pinned != "" {
+		fmt.Printf("Pinned release: %s (never expires)\n", pinned)
+	}
 	fmt.Println("\nCached files:")
 
-	allExist := true
+	files := []string{
+		filepath.Join(cacheDir, "technologies.json"),
+		filepath.Join(cacheDir, "categories.json"),
+		filepath.Join(cacheDir, "groups.json"),
+	}
+
 	for _, file := range files {
 		info, err := os.Stat(file)
 		if err != nil {
 			fmt.Printf("  %s: Not found\n", filepath.Base(file))
-			allExist = false
 			continue
 		}
 
@@ -155,11 +291,7 @@ func showCacheStatus(config *downloader.Config) {
 		fmt.Println()
 	}
 
-	if allExist {
-		fmt.Println("\nStatus: Cache is complete")
-	} else {
-		fmt.Println("\nStatus: Cache is incomplete or missing")
-	}
+	fmt.Println("\nStatus: Cache is complete")
 }
 
 // formatDuration formats a duration in a human-readable format
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// fixtureDir holds the small, pinned fingerprint set of the regression tests
+const fixtureDir = "../../pkg/wappalyzer/testdata/regression/fingerprints"
+
+// fixtureArchive zips the fixture fingerprints as a release archive
+func fixtureArchive(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"technologies.json", "categories.json", "groups.json"} {
+		data, err := os.ReadFile(filepath.Join(fixtureDir, name))
+		if err != nil {
+			t.Fatalf("could not read the fixture: %v", err)
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("could not create %s: %v", name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("could not close archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestCompileDatabaseUsesCache checks that compiling after a forced download
+// builds from the cache instead of downloading the archive again
+func TestCompileDatabaseUsesCache(t *testing.T) {
+	archive := fixtureArchive(t)
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	config := downloader.DefaultConfig()
+	config.ReleaseURL = server.URL + "/wappalyzer-fingerprints.zip"
+	config.CacheDir = t.TempDir()
+	config.Client = server.Client()
+	config.ForceDownload = true
+
+	if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "fingerprints.db")
+	if err := compileDatabase(config, path); err != nil {
+		t.Fatalf("compileDatabase() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("the archive was downloaded %d times, want once", n)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("no database written: %v", err)
+	}
+	if !config.ForceDownload || config.Offline {
+		t.Errorf("compileDatabase() changed the configuration it was given")
+	}
+}
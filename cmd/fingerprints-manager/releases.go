@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// runReleases implements the releases subcommand, listing, pinning and
+// rolling back the releases kept in the cache, and returns the exit code
+func runReleases(args []string) int {
+	action := "list"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		action, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet("releases", flag.ExitOnError)
+	formatFlag := flags.String("format", "text", "Output format of list: text or json")
+	cacheDirFlag := flags.String("cache-dir", "", "Custom cache directory for fingerprints")
+	urlFlag := flags.String("url", "", "Custom URL to download fingerprints from, pinned tags are resolved against it")
+	keepFlag := flags.Int("keep", downloader.DefaultKeepVersions, "Number of releases to keep in the cache, pinned ones aside")
+	offlineFlag := flags.Bool("offline", false, "Only pin releases already in the cache")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fingerprints-manager releases [list|pin <version>|unpin|rollback] [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "Lists the releases kept in the cache, pins one by tag or version name,\ndownloading it if needed, removes the pin, or pins the release used before\nthe current one.\n\n")
+		flags.PrintDefaults()
+	}
+	operands := parseInterspersed(flags, args)
+
+	config := downloader.DefaultConfig()
+	if *cacheDirFlag != "" {
+		config.CacheDir = *cacheDirFlag
+	}
+	if *urlFlag != "" {
+		config.ReleaseURL = *urlFlag
+	}
+	config.KeepVersions = *keepFlag
+	config.Offline = *offlineFlag
+
+	ctx := context.Background()
+
+	switch {
+	case action == "list" && len(operands) == 0:
+		if *formatFlag != "text" && *formatFlag != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unknown format %q\n", *formatFlag)
+			return 2
+		}
+		releases, err := downloader.ListReleases(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if *formatFlag == "json" {
+			if releases == nil {
+				releases = []downloader.Release{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(releases)
+		} else {
+			printReleases(releases)
+		}
+
+	case action == "pin" && len(operands) == 1:
+		release, err := downloader.PinRelease(ctx, config, operands[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error pinning release: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Pinned %s\n", describeRelease(release))
+
+	case action == "rollback" && len(operands) == 0:
+		release, err := downloader.RollbackRelease(ctx, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rolling back: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Rolled back to %s, pinned until unpinned\n", describeRelease(release))
+
+	case action == "unpin" && len(operands) == 0:
+		if err := downloader.UnpinRelease(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println("Unpinned, the latest release is used once the cache expires (use --force to download it now)")
+
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	return 0
+}
+
+// parseInterspersed parses flags given before, between and after the
+// operands, and returns the operands
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var operands []string
+	for flags.Parse(args); flags.NArg() > 0; flags.Parse(args) {
+		operands = append(operands, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	return operands
+}
+
+// printReleases prints the cached releases as a table, marking the current one
+func printReleases(releases []downloader.Release) {
+	if len(releases) == 0 {
+		fmt.Println("No releases cached")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tVERSION\tTAG\tUPDATED\t")
+	for _, release := range releases {
+		marker, tag, status := "", release.Tag, ""
+		if release.Current {
+			marker = "*"
+		}
+		if tag == "" {
+			tag = "-"
+		}
+		if release.Pinned {
+			status = "pinned"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", marker, release.Name, tag,
+			release.UpdatedAt.UTC().Format("2006-01-02 15:04 UTC"), status)
+	}
+	w.Flush()
+}
+
+// describeRelease names a release by its tag and version
+func describeRelease(release *downloader.Release) string {
+	if release.Tag == "" {
+		return "version " + release.Name
+	}
+	return fmt.Sprintf("release %s (version %s)", release.Tag, release.Name)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// pathSource returns the fingerprints source named on the command line: a
+// URL of a fingerprints archive, a directory, a technologies file or an
+// archive of the split layout
+func pathSource(path string) downloader.Source {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return downloader.Source{URL: path}
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return downloader.Source{Dir: path}
+	}
+	return downloader.Source{File: path}
+}
+
+// loadSource loads the technologies and categories of a single source
+func loadSource(config *downloader.Config, source downloader.Source) (map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	sourceConfig := *config
+	sourceConfig.Sources = []downloader.Source{source}
+
+	layered, err := downloader.GetLayeredFingerprints(context.Background(), &sourceConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Sources without categories leave the map empty rather than nil
+	categories := layered.Categories
+	if len(categories) == 0 {
+		categories = nil
+	}
+	return layered.Technologies, categories, nil
+}
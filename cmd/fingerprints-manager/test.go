@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// runTest implements the test subcommand, which runs the test vectors of the
+// technologies, and returns the exit code: 0 when every test passes, 1 when
+// some fail and 2 when the fingerprints could not be loaded
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	formatFlag := flags.String("format", "text", "Output format: text or json")
+	categoriesFlag := flags.String("categories", "", "categories.json to use, when testing a single technologies file")
+	verboseFlag := flags.Bool("v", false, "List the technologies that pass too")
+	cacheDirFlag := flags.String("cache-dir", "", "Custom cache directory for fingerprints")
+	urlFlag := flags.String("url", "", "Custom URL to download fingerprints from")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fingerprints-manager test [flags] [path]\n\n")
+		fmt.Fprintf(flags.Output(), "Runs the test vectors in the tests field of each technology through the\ncompiled fingerprints, and reports the failures per technology. The path is\na technologies file, a fingerprints directory, a zip or tar archive of one or\na fingerprints archive URL, the cached fingerprints when no path is given.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *formatFlag != "text" && *formatFlag != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q\n", *formatFlag)
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Load the fingerprints to test
+	config := downloader.DefaultConfig()
+	if *cacheDirFlag != "" {
+		config.CacheDir = *cacheDirFlag
+	}
+	if *urlFlag != "" {
+		config.ReleaseURL = *urlFlag
+	}
+
+	source := downloader.Source{URL: config.ReleaseURL}
+	if flags.NArg() == 1 {
+		source = pathSource(flags.Arg(0))
+	}
+	technologies, categories, err := loadSource(config, source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading fingerprints: %v\n", err)
+		return 2
+	}
+
+	technologiesJSON, err := json.Marshal(technologies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	var categoriesJSON []byte
+	if *categoriesFlag != "" {
+		categoriesJSON, err = os.ReadFile(*categoriesFlag)
+	} else if categories != nil {
+		categoriesJSON, err = json.Marshal(categories)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading categories: %v\n", err)
+		return 2
+	}
+
+	report, err := wappalyzer.RunFingerprintTests(technologiesJSON, categoriesJSON)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if *formatFlag == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(report)
+	} else {
+		printTestReport(report, *verboseFlag)
+	}
+
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+// printTestReport prints the failing technologies with their failures, and
+// the passing ones when verbose
+func printTestReport(report *wappalyzer.FingerprintTestReport, verbose bool) {
+	failing := 0
+	for _, result := range report.Results {
+		if result.Passed() {
+			if verbose {
+				fmt.Printf("ok   %s (%s)\n", result.Technology, plural(result.Tests, "test"))
+			}
+			continue
+		}
+
+		failing++
+		fmt.Printf("FAIL %s\n", result.Technology)
+		if result.Error != "" {
+			fmt.Printf("    invalid tests: %s\n", result.Error)
+		}
+		for _, failure := range result.Failures {
+			fmt.Printf("    %s\n", failure)
+		}
+	}
+
+	if report.Technologies == 0 {
+		fmt.Println("No technologies with tests")
+		return
+	}
+	summary := fmt.Sprintf("%s of %s", plural(report.Tests, "test"), plural(report.Technologies, "technology"))
+	if failing == 0 {
+		fmt.Printf("%s, all passed\n", summary)
+		return
+	}
+	fmt.Printf("%s, %d failed in %s\n", summary, report.Failed, plural(failing, "technology"))
+}
+
+// plural formats a count with its noun, in the plural unless the count is one
+func plural(count int, noun string) string {
+	switch {
+	case count == 1:
+		return "1 " + noun
+	case strings.HasSuffix(noun, "y"):
+		return fmt.Sprintf("%d %sies", count, strings.TrimSuffix(noun, "y"))
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
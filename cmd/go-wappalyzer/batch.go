@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/output"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// batchResult is the report of a target in batch mode
+type batchResult struct {
+	output.Report
+
+	// resumed marks results taken from the state file rather than analyzed
+	resumed bool
+}
+
+// runBatch implements batch mode: it reads the targets listed in the
+// -targets file, expanding CIDR blocks and port sets, analyzes them with a
+// pool of workers sharing one instance and writes a report per target, in
+// JSON lines by default, as soon as it is analyzed, including the targets
+// that failed. With -state, the results are recorded so that -resume skips
+// the targets already analyzed. It returns the exit code.
+func runBatch(client *http.Client) int {
+	var input io.Reader = os.Stdin
+	if *targetsFlag != "-" {
+		file, err := os.Open(*targetsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	format := *formatFlag
+	if format == "" {
+		format = "jsonl"
+	}
+	var out io.Writer = os.Stdout
+	if *outputFlag != "" {
+		file, err := os.Create(*outputFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	writer := bufio.NewWriter(out)
+	formatter, err := output.New(format, writer, toolInfo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var ports []int
+	if *portsFlag != "" {
+		if ports, err = parsePorts(*portsFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if (*resumeFlag || *freshFlag) && *stateFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: -resume and -fresh require -state")
+		return 1
+	}
+	if *resumeFlag && *freshFlag {
+		fmt.Fprintln(os.Stderr, "Error: -resume and -fresh can't be combined")
+		return 1
+	}
+	var state *scanState
+	if *stateFlag != "" {
+		if state, err = openState(*stateFlag, *resumeFlag, *freshFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer state.Close()
+	}
+
+	w, err := newInstance()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating wappalyzer instance: %v\n", err)
+		return 1
+	}
+	warnStaleFingerprints(w)
+
+	// Fail before scanning rather than once per target
+	if *filterGroupFlag != "" {
+		if _, found := findGroupIDByName(w, *filterGroupFlag); !found {
+			fmt.Fprintf(os.Stderr, "Error: group '%s' not found\n", *filterGroupFlag)
+			return 1
+		}
+	}
+
+	// Stop feeding targets on interrupt, the ones being analyzed are dropped
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	concurrency := *concurrencyFlag
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limiter := newHostLimiter(*rateLimitFlag)
+
+	targets := make(chan string)
+	results := make(chan batchResult)
+
+	// The reader reports the lines it can't expand, it is done with results
+	// once targets is closed, before the workers are
+	var readErr error
+	go func() {
+		defer close(targets)
+		readErr = readTargets(ctx, input, ports, state, targets, results)
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for target := range targets {
+				result := scanTarget(ctx, client, limiter, w, target)
+				if ctx.Err() != nil {
+					continue
+				}
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	// Write the results as they complete, for the formats that stream them
+	scanned, failed, resumed := 0, 0, 0
+	var writeErr, stateErr error
+	for result := range results {
+		switch {
+		case result.resumed:
+			resumed++
+		case result.Error != "":
+			failed++
+			scanned++
+		default:
+			scanned++
+		}
+
+		if state != nil && !result.resumed && stateErr == nil {
+			stateErr = state.record(result)
+		}
+		if writeErr != nil {
+			continue
+		}
+		report := result.Report
+		if writeErr = formatter.Write(&report); writeErr == nil {
+			writeErr = writer.Flush()
+		}
+	}
+	if writeErr == nil {
+		if writeErr = formatter.Close(); writeErr == nil {
+			writeErr = writer.Flush()
+		}
+	}
+
+	if writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Error writing results: %v\n", writeErr)
+		return 1
+	}
+	if stateErr != nil {
+		fmt.Fprintf(os.Stderr, "Error writing state file: %v\n", stateErr)
+		return 1
+	}
+	if readErr != nil {
+		fmt.Fprintf(os.Stderr, "Error reading targets: %v\n", readErr)
+		return 1
+	}
+	if !*silentFlag {
+		if resumed > 0 {
+			fmt.Fprintf(os.Stderr, "Analyzed %d targets, %d failed, %d already analyzed\n", scanned, failed, resumed)
+		} else {
+			fmt.Fprintf(os.Stderr, "Analyzed %d targets, %d failed\n", scanned, failed)
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		return 130
+	}
+	return 0
+}
+
+// readTargets sends the targets listed one per line, skipping blank lines
+// and # comments, until the input ends or ctx is done. Lines are expanded
+// with expandTarget, and lines that can't be expanded are reported as failed
+// results. Targets the state holds a fresh result of are not sent, their
+// recorded result is.
+func readTargets(ctx context.Context, input io.Reader, ports []int, state *scanState, targets chan<- string, results chan<- batchResult) error {
+	started := time.Now()
+
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		err := expandTarget(entry, ports, func(target string) bool {
+			if state != nil {
+				if result, ok := state.completed(target, *freshnessFlag, started); ok {
+					result.resumed = true
+					select {
+					case results <- result:
+						return true
+					case <-ctx.Done():
+						return false
+					}
+				}
+			}
+
+			select {
+			case targets <- target:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil {
+			select {
+			case results <- failedResult(entry, err):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// scanTarget fetches and analyzes a single target, reporting failures in the
+// result rather than aborting the batch
+func scanTarget(ctx context.Context, client *http.Client, limiter *hostLimiter, w *wappalyzer.Wappalyze, target string) (result batchResult) {
+	result.Target = target
+	defer func() {
+		result.ScannedAt = time.Now().UTC()
+		result.Normalize()
+	}()
+
+	// Probe host:port targets for TLS to pick their scheme. The probe
+	// connects to the host too, so it waits for the host's slot first.
+	targetURL := target
+	waited := false
+	if !strings.Contains(target, "://") && hasPort(target) {
+		hostport := target
+		if index := strings.Index(target, "/"); index >= 0 {
+			hostport = target[:index]
+		}
+		host, _, _ := net.SplitHostPort(hostport)
+		if err := limiter.wait(ctx, host); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		waited = true
+
+		scheme, err := detectScheme(ctx, hostport, client.Timeout)
+		if err != nil {
+			result.Error = fmt.Sprintf("could not connect: %v", err)
+			return result
+		}
+		targetURL = scheme + "://" + target
+	}
+	targetURL = normalizeTarget(targetURL)
+
+	u, err := url.Parse(targetURL)
+	if err != nil || u.Host == "" {
+		result.Error = fmt.Sprintf("invalid target %q", target)
+		return result
+	}
+
+	if !waited {
+		if err := limiter.wait(ctx, u.Hostname()); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	resp, body, err := fetchTarget(ctx, client, targetURL)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Report = *buildReport(w, target, resp, body)
+	return result
+}
+
+// failedResult reports a target entry that could not be analyzed
+func failedResult(entry string, err error) batchResult {
+	report := output.Report{Target: entry, Error: err.Error(), ScannedAt: time.Now().UTC()}
+	report.Normalize()
+	return batchResult{Report: report}
+}
+
+// hostLimiter spaces the requests sent to each host
+type hostLimiter struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	next    map[string]time.Time
+	sweepAt int
+}
+
+// newHostLimiter creates a limiter allowing rate requests per second to each
+// host, or any number when rate is not positive
+func newHostLimiter(rate float64) *hostLimiter {
+	l := &hostLimiter{next: make(map[string]time.Time), sweepAt: 1024}
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return l
+}
+
+// wait blocks until a request can be sent to host, or ctx is done
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	// Reserve the next slot of the host
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+
+	// Forget the hosts whose slots have passed, so long scans don't grow the map
+	if len(l.next) >= l.sweepAt {
+		for name, next := range l.next {
+			if next.Before(now) {
+				delete(l.next, name)
+			}
+		}
+		l.sweepAt = 2*len(l.next) + 1024
+	}
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestScanTargetWaitsBeforeProbing checks that probing the scheme of a
+// host:port target counts against the host's rate limit, so a host is never
+// connected to before its slot
+func TestScanTargetWaitsBeforeProbing(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	hostport := server.Listener.Addr().String()
+	host, _, _ := net.SplitHostPort(hostport)
+
+	// Take the only slot of the host for the next hour
+	limiter := newHostLimiter(1.0 / 3600)
+	if err := limiter.wait(context.Background(), host); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result := scanTarget(ctx, &http.Client{Timeout: 5 * time.Second}, limiter, nil, hostport)
+
+	if !strings.Contains(result.Error, context.DeadlineExceeded.Error()) {
+		t.Errorf("scanTarget() error = %q, want the limiter's deadline", result.Error)
+	}
+	// Let the server notice a connection that would have been made
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&connections); n != 0 {
+		t.Errorf("%d connections made before the host's slot", n)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHostBits caps the size of CIDR blocks, 2^24 addresses, a /8 in IPv4
+const maxHostBits = 24
+
+// parsePorts parses a port set such as 80,443,8000-8010
+func parsePorts(set string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(set, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high := part, part
+		if index := strings.Index(part, "-"); index >= 0 {
+			low, high = part[:index], part[index+1:]
+		}
+		first, err := strconv.Atoi(low)
+		if err != nil || first < 1 || first > 65535 {
+			return nil, fmt.Errorf("invalid port %q", low)
+		}
+		last, err := strconv.Atoi(high)
+		if err != nil || last < first || last > 65535 {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+
+		for port := first; port <= last; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports, nil
+}
+
+// expandTarget expands a target entry into the targets to analyze, calling
+// emit for each until it returns false. URLs are kept as they are, and so are
+// host:port entries, whose scheme is detected when they are analyzed. A CIDR
+// block expands to its addresses and a bare host to itself, each combined
+// with every port when ports are given.
+func expandTarget(entry string, ports []int, emit func(target string) bool) error {
+	if strings.Contains(entry, "://") {
+		emit(entry)
+		return nil
+	}
+
+	if ip, network, err := net.ParseCIDR(entry); err == nil {
+		return expandCIDR(ip, network, ports, emit)
+	}
+
+	host, path := entry, ""
+	if index := strings.Index(entry, "/"); index >= 0 {
+		host, path = entry[:index], entry[index:]
+	}
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		if _, err := strconv.Atoi(port); err != nil {
+			return fmt.Errorf("invalid port %q", port)
+		}
+		emit(entry)
+		return nil
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if len(ports) == 0 {
+		emit(hostTarget(host) + path)
+		return nil
+	}
+	for _, port := range ports {
+		if !emit(net.JoinHostPort(host, strconv.Itoa(port)) + path) {
+			break
+		}
+	}
+	return nil
+}
+
+// expandCIDR emits the addresses of a CIDR block, combined with every port.
+// The network and broadcast addresses of IPv4 blocks larger than a /31 are
+// left out.
+func expandCIDR(ip net.IP, network *net.IPNet, ports []int, emit func(target string) bool) error {
+	ones, bits := network.Mask.Size()
+	hostBits := bits - ones
+	if hostBits > maxHostBits {
+		return fmt.Errorf("CIDR block %s has more than 2^%d addresses", network, maxHostBits)
+	}
+
+	base := network.IP
+	if ip.To4() != nil {
+		base = base.To4()
+	}
+
+	first, last := int64(0), int64(1)<<uint(hostBits)-1
+	if ip.To4() != nil && hostBits > 1 {
+		first, last = first+1, last-1
+	}
+
+	start := new(big.Int).SetBytes(base)
+	for offset := first; offset <= last; offset++ {
+		address := new(big.Int).Add(start, big.NewInt(offset)).FillBytes(make([]byte, len(base)))
+		host := net.IP(address).String()
+
+		if len(ports) == 0 {
+			if !emit(hostTarget(host)) {
+				return nil
+			}
+			continue
+		}
+		for _, port := range ports {
+			if !emit(net.JoinHostPort(host, strconv.Itoa(port))) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// hostTarget brackets IPv6 addresses so they can be used in URLs
+func hostTarget(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// hasPort reports whether a target without a scheme names its port
+func hasPort(target string) bool {
+	if index := strings.Index(target, "/"); index >= 0 {
+		target = target[:index]
+	}
+	_, _, err := net.SplitHostPort(target)
+	return err == nil
+}
+
+// detectScheme tells whether the server at a host:port speaks TLS, by
+// attempting a handshake, and returns https if it does and http otherwise
+func detectScheme(ctx context.Context, hostport string, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", hostport)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	config := &tls.Config{InsecureSkipVerify: true}
+	if host, _, err := net.SplitHostPort(hostport); err == nil && net.ParseIP(host) == nil {
+		config.ServerName = host
+	}
+	if err := tls.Client(conn, config).HandshakeContext(ctx); err != nil {
+		if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+			return "", ctx.Err()
+		}
+		return "http", nil
+	}
+	return "https", nil
+}
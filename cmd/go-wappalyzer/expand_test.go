@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		set     string
+		want    []int
+		wantErr bool
+	}{
+		{set: "", want: nil},
+		{set: "80", want: []int{80}},
+		{set: "80,443", want: []int{80, 443}},
+		{set: " 80 , ,443 ", want: []int{80, 443}},
+		{set: "8000-8003", want: []int{8000, 8001, 8002, 8003}},
+		{set: "1-1", want: []int{1}},
+		{set: "65535", want: []int{65535}},
+		{set: "80,80,79-81,443,80-80", want: []int{80, 79, 81, 443}},
+		{set: "0", wantErr: true},
+		{set: "65536", wantErr: true},
+		{set: "1-65536", wantErr: true},
+		{set: "http", wantErr: true},
+		{set: "90-80", wantErr: true},
+		{set: "80-", wantErr: true},
+		{set: "-80", wantErr: true},
+		{set: "80,x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.set, func(t *testing.T) {
+			got, err := parsePorts(tt.set)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePorts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandCIDR(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		ports   []int
+		want    []string
+		wantErr bool
+	}{
+		{cidr: "192.0.2.7/32", want: []string{"192.0.2.7"}},
+		{cidr: "192.0.2.1/31", want: []string{"192.0.2.0", "192.0.2.1"}},
+		{cidr: "192.0.2.0/30", want: []string{"192.0.2.1", "192.0.2.2"}},
+		{cidr: "192.0.2.9/29", want: []string{"192.0.2.9", "192.0.2.10", "192.0.2.11", "192.0.2.12", "192.0.2.13", "192.0.2.14"}},
+		{cidr: "192.0.2.0/31", ports: []int{80, 8443}, want: []string{"192.0.2.0:80", "192.0.2.0:8443", "192.0.2.1:80", "192.0.2.1:8443"}},
+		{cidr: "2001:db8::5/128", want: []string{"[2001:db8::5]"}},
+		{cidr: "2001:db8::/126", want: []string{"[2001:db8::]", "[2001:db8::1]", "[2001:db8::2]", "[2001:db8::3]"}},
+		{cidr: "2001:db8::/127", ports: []int{443}, want: []string{"[2001:db8::]:443", "[2001:db8::1]:443"}},
+		{cidr: "10.0.0.0/7", wantErr: true},
+		{cidr: "2001:db8::/103", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			ip, network, err := net.ParseCIDR(tt.cidr)
+			if err != nil {
+				t.Fatalf("ParseCIDR() error = %v", err)
+			}
+
+			var got []string
+			err = expandCIDR(ip, network, tt.ports, func(target string) bool {
+				got = append(got, target)
+				return true
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandCIDR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandCIDR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExpandCIDRLimit checks that blocks of exactly 2^maxHostBits addresses
+// are accepted, and that expansion stops as soon as emit returns false
+func TestExpandCIDRLimit(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/8", "2001:db8::/104"} {
+		ip, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("ParseCIDR() error = %v", err)
+		}
+
+		var got []string
+		err = expandCIDR(ip, network, []int{80, 443}, func(target string) bool {
+			got = append(got, target)
+			return len(got) < 3
+		})
+		if err != nil {
+			t.Errorf("expandCIDR(%s) error = %v", cidr, err)
+		}
+		if len(got) != 3 {
+			t.Errorf("expandCIDR(%s) emitted %v after emit returned false", cidr, got)
+		}
+	}
+}
+
+func TestDetectScheme(t *testing.T) {
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.NotFoundHandler())
+	defer secure.Close()
+
+	for _, server := range []struct {
+		server *httptest.Server
+		want   string
+	}{
+		{plain, "http"},
+		{secure, "https"},
+	} {
+		scheme, err := detectScheme(context.Background(), server.server.Listener.Addr().String(), 5*time.Second)
+		if err != nil || scheme != server.want {
+			t.Errorf("detectScheme(%s) = %q, %v, want %q", server.server.URL, scheme, err, server.want)
+		}
+	}
+
+	// Nothing listens on a closed port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+	if scheme, err := detectScheme(context.Background(), closed, 5*time.Second); err == nil {
+		t.Errorf("detectScheme(closed port) = %q, want an error", scheme)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if scheme, err := detectScheme(ctx, plain.Listener.Addr().String(), 5*time.Second); err == nil {
+		t.Errorf("detectScheme(canceled) = %q, want an error", scheme)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"flag"
@@ -14,7 +15,7 @@ import (
 	"time"
 
 	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
-	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/output"
 	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
 )
 
@@ -33,9 +34,18 @@ func (h *HeaderFlags) Set(value string) error {
 var (
 	// Command line flags
 	targetFlag         = flag.String("target", "", "Target URL to analyze")
+	targetsFlag        = flag.String("targets", "", "File listing the targets to analyze, one per line, or - for stdin; results are written as JSON lines")
+	portsFlag          = flag.String("ports", "", "Ports to analyze the hosts and CIDR blocks listed with -targets on, such as 80,443,8000-8010")
+	stateFlag          = flag.String("state", "", "File recording the results of -targets, to resume an interrupted scan from")
+	resumeFlag         = flag.Bool("resume", false, "Skip the targets the -state file holds a successful result of")
+	freshFlag          = flag.Bool("fresh", false, "Discard the results an existing -state file holds and start the scan over")
+	freshnessFlag      = flag.Duration("freshness", 0, "Only skip targets analyzed within this window when resuming, such as 24h (0 = any age)")
+	concurrencyFlag    = flag.Int("concurrency", 20, "Number of targets analyzed at once with -targets")
+	rateLimitFlag      = flag.Float64("rate-limit", 0, "Maximum requests per second to a single host with -targets (0 = no limit)")
 	outputFlag         = flag.String("output", "", "Output file path")
 	methodFlag         = flag.String("method", "GET", "HTTP method to use")
-	jsonFlag           = flag.Bool("json", false, "Output in JSON format")
+	jsonFlag           = flag.Bool("json", false, "Output in JSON format, shaped by the -category and -group flags")
+	formatFlag         = flag.String("format", "", "Output format: text, "+strings.Join(output.Formats(), ", ")+" (default text, jsonl with -targets)")
 	noColorFlag        = flag.Bool("no-color", false, "Disable colored output")
 	silentFlag         = flag.Bool("silent", false, "Don't display any output")
 	versionFlag        = flag.Bool("version", false, "Show version information")
@@ -49,9 +59,13 @@ var (
 	timeoutFlag        = flag.Int("timeout", 10, "Timeout in seconds for HTTP requests")
 	maxBodySizeFlag    = flag.Int("max-body-size", 0, "Maximum response body size to analyze (0 = no limit)")
 	fingerprintURLFlag = flag.String("fingerprint-url", "", "Custom URL to download fingerprints from")
+	fingerprintVerFlag = flag.String("fingerprint-version", "", "Release tag or cached version of the fingerprints to use, instead of the latest")
 	cacheTTLFlag       = flag.Int("cache-ttl", 24, "Cache TTL in hours (0 for no expiry)")
 	cacheDirFlag       = flag.String("cache-dir", "", "Custom cache directory for fingerprints")
 	forceDownloadFlag  = flag.Bool("force-download", false, "Force download of fingerprints even if cache is valid")
+	offlineFlag        = flag.Bool("offline", false, "Use cached fingerprints only, never downloading them")
+	allowStaleFlag     = flag.Bool("allow-stale", false, "Use expired cached fingerprints if refreshing them fails")
+	fingerprintsFlag   = flag.String("fingerprints", "", "Local fingerprints to use instead of downloading them (technologies file, directory or zip/tar archive)")
 	customHeaders      HeaderFlags
 )
 
@@ -83,8 +97,8 @@ func colorize(text, color string, enabled bool) string {
 }
 
 // listAvailableGroups prints all available groups
-func listAvailableGroups() {
-	groups := wappalyzer.GetGroupsMapping()
+func listAvailableGroups(w *wappalyzer.Wappalyze) {
+	groups := w.Groups()
 
 	// Sort group IDs
 	groupIDs := make([]int, 0, len(groups))
@@ -103,9 +117,9 @@ func listAvailableGroups() {
 }
 
 // listAvailableCategories prints all available categories
-func listAvailableCategories() {
-	categories := wappalyzer.GetCategoriesMapping()
-	groups := wappalyzer.GetGroupsMapping()
+func listAvailableCategories(w *wappalyzer.Wappalyze) {
+	categories := w.Categories()
+	groups := w.Groups()
 
 	// Sort category IDs
 	categoryIDs := make([]int, 0, len(categories))
@@ -139,8 +153,8 @@ func listAvailableCategories() {
 }
 
 // findGroupIDByName finds the group ID for a given group name
-func findGroupIDByName(name string) (int, bool) {
-	groups := wappalyzer.GetGroupsMapping()
+func findGroupIDByName(w *wappalyzer.Wappalyze, name string) (int, bool) {
+	groups := w.Groups()
 	nameLower := strings.ToLower(name)
 
 	for id, group := range groups {
@@ -153,6 +167,11 @@ func findGroupIDByName(name string) (int, bool) {
 }
 
 func main() {
+	// Subcommands have flags of their own
+	if len(os.Args) > 1 && os.Args[1] == "record" {
+		os.Exit(runRecord(os.Args[2:]))
+	}
+
 	// Set up flags
 	flag.Var(&customHeaders, "header", "HTTP headers to include (can be used multiple times)")
 	flag.Parse()
@@ -175,57 +194,167 @@ func main() {
 		downloaderConfig.CacheExpiry = time.Duration(*cacheTTLFlag) * time.Hour
 	}
 	downloaderConfig.ForceDownload = *forceDownloadFlag
+	downloaderConfig.Offline = *offlineFlag
+	downloaderConfig.AllowStale = *allowStaleFlag
+	downloaderConfig.Version = *fingerprintVerFlag
+
+	// Local fingerprints, such as a checkout of a fingerprints repository
+	if *fingerprintsFlag != "" {
+		source := downloader.Source{File: *fingerprintsFlag}
+		if info, err := os.Stat(*fingerprintsFlag); err == nil && info.IsDir() {
+			source = downloader.Source{Dir: *fingerprintsFlag}
+		}
+		downloaderConfig.Sources = []downloader.Source{source}
+	}
 
 	// Set the downloader config
 	wappalyzer.SetDownloaderConfig(downloaderConfig)
 
-	// List groups if requested
-	if *listGroupsFlag {
-		listAvailableGroups()
-		os.Exit(0)
-	}
+	// List groups or categories if requested
+	if *listGroupsFlag || *listCategoriesFlag {
+		w, err := wappalyzer.New()
+		if err != nil {
+			log.Fatalf("Error loading fingerprints: %v", err)
+		}
+		warnStaleFingerprints(w)
 
-	// List categories if requested
-	if *listCategoriesFlag {
-		listAvailableCategories()
+		if *listGroupsFlag {
+			listAvailableGroups(w)
+		} else {
+			listAvailableCategories(w)
+		}
 		os.Exit(0)
 	}
 
 	// Validate required flags
-	if *targetFlag == "" {
+	if *targetFlag == "" && *targetsFlag == "" {
 		flag.Usage()
 		fmt.Println("\nError: target URL is required")
 		os.Exit(1)
 	}
+	if *targetFlag != "" && *targetsFlag != "" {
+		fmt.Fprintln(os.Stderr, "Error: -target and -targets can't be used together")
+		os.Exit(1)
+	}
 
-	// Create HTTP transport with SSL verification option
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: *disableSSLFlag,
-		},
+	if *jsonFlag && *formatFlag != "" {
+		fmt.Fprintln(os.Stderr, "Error: -json and -format can't be used together")
+		os.Exit(1)
+	}
+	if *formatFlag != "" && *formatFlag != "text" {
+		if _, err := output.New(*formatFlag, io.Discard, toolInfo); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	client := newHTTPClient()
+
+	// Scan a list of targets, one result per line
+	if *targetsFlag != "" {
+		os.Exit(runBatch(client))
+	}
+
+	target := normalizeTarget(*targetFlag)
+
+	// Make the request
+	if !*silentFlag && (*formatFlag == "" || *formatFlag == "text") {
+		fmt.Printf("Analyzing %s...\n", target)
+	}
+
+	resp, body, err := fetchTarget(context.Background(), client, target)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	w, err := newInstance()
+	if err != nil {
+		log.Fatalf("Error creating wappalyzer instance: %v", err)
+	}
+	warnStaleFingerprints(w)
+
+	if *filterGroupFlag != "" {
+		if _, found := findGroupIDByName(w, *filterGroupFlag); !found {
+			log.Fatalf("Error: group '%s' not found", *filterGroupFlag)
+		}
+	}
+
+	// Write a report in one of the output formats
+	if *formatFlag != "" && *formatFlag != "text" {
+		if err := writeReport(buildReport(w, *targetFlag, resp, body)); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if *outputFlag != "" && !*silentFlag {
+			fmt.Printf("Results written to %s\n", *outputFlag)
+		}
+		return
+	}
+
+	// Analyze, keeping only the technologies of the -filter-group group
+	result := w.Analyze(resp.Header, body)
+	if *filterGroupFlag != "" {
+		result = result.Filter(func(t wappalyzer.Technology) bool { return t.InGroup(*filterGroupFlag) })
 	}
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(*timeoutFlag) * time.Second,
+	// Format the results
+	var formatted []byte
+	if *jsonFlag {
+		formatted, err = json.MarshalIndent(jsonView(result), "", "  ")
+		if err != nil {
+			log.Fatalf("Error formatting JSON output: %v", err)
+		}
+		formatted = append(formatted, '\n')
+	} else {
+		formatted = []byte(formatText(result, !*noColorFlag && *outputFlag == ""))
+	}
+
+	if *outputFlag != "" {
+		// Write to file
+		if err := os.WriteFile(*outputFlag, formatted, 0644); err != nil {
+			log.Fatalf("Error writing output to file: %v", err)
+		}
+		if !*silentFlag {
+			fmt.Printf("Results written to %s\n", *outputFlag)
+		}
+	} else if !*silentFlag {
+		// Write to stdout
+		os.Stdout.Write(formatted)
+		if !*jsonFlag {
+			fmt.Printf("Found %d technologies\n", len(result.Technologies))
+		}
 	}
+}
 
-	// Ensure target has a scheme
-	target := *targetFlag
+// newHTTPClient creates the HTTP client targets are fetched with
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: *disableSSLFlag,
+			},
+		},
+		Timeout: time.Duration(*timeoutFlag) * time.Second,
+	}
+}
+
+// normalizeTarget adds the http scheme to targets without one
+func normalizeTarget(target string) string {
 	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
-		target = "http://" + target
+		return "http://" + target
 	}
+	return target
+}
 
-	// Create request
-	req, err := http.NewRequest(*methodFlag, target, nil)
+// fetchTarget requests a target with the configured method and headers and
+// reads its body, up to the maximum body size
+func fetchTarget(ctx context.Context, client *http.Client, target string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, *methodFlag, target, nil)
 	if err != nil {
-		log.Fatalf("Error creating request: %v", err)
+		return nil, nil, fmt.Errorf("could not create request: %v", err)
 	}
 
 	// Add headers
-	headers := parseHeaders(customHeaders)
-	for name, value := range headers {
+	for name, value := range parseHeaders(customHeaders) {
 		req.Header.Set(name, value)
 	}
 
@@ -234,14 +363,9 @@ func main() {
 		req.Header.Set("User-Agent", fmt.Sprintf("go-wappalyzer/%s", Version))
 	}
 
-	// Make the request
-	if !*silentFlag {
-		fmt.Printf("Analyzing %s...\n", target)
-	}
-
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Fatalf("Error making request: %v", err)
+		return nil, nil, fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
@@ -251,13 +375,15 @@ func main() {
 		reader = io.LimitReader(resp.Body, int64(*maxBodySizeFlag))
 	}
 
-	// Read the response body
 	body, err := io.ReadAll(reader)
 	if err != nil {
-		log.Fatalf("Error reading response body: %v", err)
+		return nil, nil, fmt.Errorf("could not read response body: %v", err)
 	}
+	return resp, body, nil
+}
 
-	// Create wappalyzer instance
+// newInstance creates the wappalyzer instance targets are analyzed with
+func newInstance() (*wappalyzer.Wappalyze, error) {
 	options := []wappalyzer.Option{
 		wappalyzer.WithAllDetections(),
 	}
@@ -266,355 +392,34 @@ func main() {
 		options = append(options, wappalyzer.WithMaxBodySize(*maxBodySizeFlag))
 	}
 
-	w, err := wappalyzer.New(options...)
-	if err != nil {
-		log.Fatalf("Error creating wappalyzer instance: %v", err)
-	}
-
-	// Detect technologies
-	var results interface{}
-
-	// If filtering by group
-	if *filterGroupFlag != "" {
-		groupID, found := findGroupIDByName(*filterGroupFlag)
-		if !found {
-			log.Fatalf("Error: Group '%s' not found", *filterGroupFlag)
-		}
-
-		// Get technologies by group
-		techs := w.GetTechByGroup(groupID)
-
-		// Create a map for output
-		techMap := make(map[string]struct{})
-		for _, tech := range techs {
-			techMap[tech] = struct{}{}
-		}
+	return wappalyzer.New(options...)
+}
 
-		// Get additional information if needed
-		if *categoryFlag || *groupFlag {
-			techInfo := make(map[string]interface{})
-
-			if *categoryFlag {
-				catInfo := w.FingerprintWithCats(resp.Header, body)
-				for tech := range techMap {
-					if info, ok := catInfo[tech]; ok {
-						techInfo[tech] = info
-					}
-				}
-				results = techInfo
-			} else if *groupFlag {
-				groupInfo := w.FingerprintWithGroups(resp.Header, body)
-				for tech := range techMap {
-					if info, ok := groupInfo[tech]; ok {
-						techInfo[tech] = info
-					}
-				}
-				results = techInfo
-			} else {
-				results = techMap
-			}
-		} else {
-			results = techMap
-		}
-	} else if *byGroupFlag {
-		// Organize results by group
-		results = w.FingerprintWithGroups(resp.Header, body)
-	} else if *categoryFlag && *groupFlag {
-		// If both category and group info are requested, use comprehensive info
-		results = w.FingerprintWithTechInfo(resp.Header, body)
-	} else if *categoryFlag {
-		// Just category information
-		results = w.FingerprintWithCats(resp.Header, body)
-	} else if *groupFlag {
-		// Just group information
-		results = w.FingerprintWithGroups(resp.Header, body)
-	} else {
-		// Basic information
-		results = w.FingerprintWithInfo(resp.Header, body)
+// jsonView returns the view of a result the -json output is shaped as, which
+// depends on the -category, -group, -by-group and -filter-group flags
+func jsonView(result *wappalyzer.Result) interface{} {
+	switch {
+	case *byGroupFlag:
+		return result.GroupNames()
+	case *categoryFlag && *groupFlag:
+		return result.TechInfo()
+	case *categoryFlag:
+		return result.CatsInfo()
+	case *groupFlag:
+		return result.GroupNames()
+	case *filterGroupFlag != "":
+		return result.Set()
 	}
+	return result.AppInfo()
+}
 
-	// Format and output results
-	if *jsonFlag {
-		// JSON output
-		output, err := json.MarshalIndent(results, "", "  ")
-		if err != nil {
-			log.Fatalf("Error formatting JSON output: %v", err)
-		}
-
-		if *outputFlag != "" {
-			// Write to file
-			err = os.WriteFile(*outputFlag, output, 0644)
-			if err != nil {
-				log.Fatalf("Error writing output to file: %v", err)
-			}
-			if !*silentFlag {
-				fmt.Printf("Results written to %s\n", *outputFlag)
-			}
-		} else if !*silentFlag {
-			// Write to stdout
-			fmt.Println(string(output))
-		}
-	} else {
-		// Text output
-		var output strings.Builder
-
-		// Colorization
-		useColors := !*noColorFlag && *outputFlag == ""
-		colors := map[string]string{
-			"tech":      "\033[1;36m", // Cyan
-			"desc":      "\033[0;37m", // Light gray
-			"website":   "\033[0;33m", // Yellow
-			"cat":       "\033[0;32m", // Green
-			"group":     "\033[0;35m", // Purple
-			"header":    "\033[1;35m", // Magenta
-			"subheader": "\033[1;33m", // Yellow bold
-		}
-
-		// Different output formats based on results type
-		switch typedResults := results.(type) {
-		case map[string]wappalyzer.TechInfo:
-			// Comprehensive tech info
-			techNames := make([]string, 0, len(typedResults))
-			for tech := range typedResults {
-				techNames = append(techNames, tech)
-			}
-			sort.Strings(techNames)
-
-			// If organizing by group
-			if *byGroupFlag {
-				// Create a map of group to technologies
-				groupToTechs := make(map[string][]string)
-
-				for _, tech := range techNames {
-					info := typedResults[tech]
-
-					if len(info.Groups) == 0 {
-						groupToTechs["Uncategorized"] = append(groupToTechs["Uncategorized"], tech)
-					} else {
-						for _, group := range info.Groups {
-							groupToTechs[group] = append(groupToTechs[group], tech)
-						}
-					}
-				}
-
-				// Get sorted group names
-				groupNames := make([]string, 0, len(groupToTechs))
-				for group := range groupToTechs {
-					groupNames = append(groupNames, group)
-				}
-				sort.Strings(groupNames)
-
-				// Output by group
-				output.WriteString(colorize(fmt.Sprintf("Detected %d technologies in %d groups:\n", len(techNames), len(groupNames)), colors["header"], useColors))
-				output.WriteString("=======================================\n\n")
-
-				for _, group := range groupNames {
-					techs := groupToTechs[group]
-					sort.Strings(techs)
-
-					output.WriteString(colorize(fmt.Sprintf("Group: %s (%d technologies)\n", group, len(techs)), colors["subheader"], useColors))
-					output.WriteString("---------------------------\n")
-
-					for _, tech := range techs {
-						info := typedResults[tech]
-
-						output.WriteString(colorize(tech+":", colors["tech"], useColors) + "\n")
-
-						if info.Description != "" {
-							output.WriteString(colorize("  Description: ", colors["desc"], useColors) + info.Description + "\n")
-						}
-						if info.Website != "" {
-							output.WriteString(colorize("  Website: ", colors["website"], useColors) + info.Website + "\n")
-						}
-						if len(info.Categories) > 0 {
-							output.WriteString(colorize("  Categories: ", colors["cat"], useColors) + strings.Join(info.Categories, ", ") + "\n")
-						}
-
-						output.WriteString("\n")
-					}
-
-					output.WriteString("\n")
-				}
-			} else {
-				// Regular output by technology
-				output.WriteString(colorize(fmt.Sprintf("Detected %d technologies:\n", len(techNames)), colors["header"], useColors))
-				output.WriteString("=======================\n\n")
-
-				for _, tech := range techNames {
-					info := typedResults[tech]
-
-					output.WriteString(colorize(tech+":", colors["tech"], useColors) + "\n")
-
-					if info.Description != "" {
-						output.WriteString(colorize("  Description: ", colors["desc"], useColors) + info.Description + "\n")
-					}
-					if info.Website != "" {
-						output.WriteString(colorize("  Website: ", colors["website"], useColors) + info.Website + "\n")
-					}
-					if len(info.Categories) > 0 {
-						output.WriteString(colorize("  Categories: ", colors["cat"], useColors) + strings.Join(info.Categories, ", ") + "\n")
-					}
-					if len(info.Groups) > 0 {
-						output.WriteString(colorize("  Groups: ", colors["group"], useColors) + strings.Join(info.Groups, ", ") + "\n")
-					}
-
-					output.WriteString("\n")
-				}
-			}
-
-		case map[string]models.AppInfo:
-			techNames := make([]string, 0, len(typedResults))
-			for tech := range typedResults {
-				techNames = append(techNames, tech)
-			}
-			sort.Strings(techNames)
-
-			for _, tech := range techNames {
-				info := typedResults[tech]
-				output.WriteString(colorize(tech+":", colors["tech"], useColors) + "\n")
-
-				if info.Description != "" {
-					output.WriteString(colorize("  Description: ", colors["desc"], useColors) + info.Description + "\n")
-				}
-				if info.Website != "" {
-					output.WriteString(colorize("  Website: ", colors["website"], useColors) + info.Website + "\n")
-				}
-				output.WriteString("\n")
-			}
-
-		case map[string]models.CatsInfo:
-			techNames := make([]string, 0, len(typedResults))
-			for tech := range typedResults {
-				techNames = append(techNames, tech)
-			}
-			sort.Strings(techNames)
-
-			for _, tech := range techNames {
-				info := typedResults[tech]
-				output.WriteString(colorize(tech+":", colors["tech"], useColors) + "\n")
-
-				if len(info.Cats) > 0 {
-					categoryNames := make([]string, 0, len(info.Cats))
-					categories := wappalyzer.GetCategoriesMapping()
-
-					for _, catID := range info.Cats {
-						if category, ok := categories[catID]; ok {
-							categoryNames = append(categoryNames, category.Name)
-						} else {
-							categoryNames = append(categoryNames, fmt.Sprintf("Category %d", catID))
-						}
-					}
-					output.WriteString(colorize("  Categories: ", colors["cat"], useColors) + strings.Join(categoryNames, ", ") + "\n")
-				}
-				output.WriteString("\n")
-			}
-
-		case map[string][]string: // FingerprintWithGroups or FingerprintWithCategories
-			techNames := make([]string, 0, len(typedResults))
-			for tech := range typedResults {
-				techNames = append(techNames, tech)
-			}
-			sort.Strings(techNames)
-
-			if *byGroupFlag {
-				// Organize by group
-				groupToTechs := make(map[string][]string)
-
-				for tech, groups := range typedResults {
-					if len(groups) == 0 {
-						groupToTechs["Uncategorized"] = append(groupToTechs["Uncategorized"], tech)
-					} else {
-						for _, group := range groups {
-							groupToTechs[group] = append(groupToTechs[group], tech)
-						}
-					}
-				}
-
-				groupNames := make([]string, 0, len(groupToTechs))
-				for group := range groupToTechs {
-					groupNames = append(groupNames, group)
-				}
-				sort.Strings(groupNames)
-
-				output.WriteString(colorize(fmt.Sprintf("Detected %d technologies in %d groups:\n", len(techNames), len(groupNames)), colors["header"], useColors))
-				output.WriteString("=======================================\n\n")
-
-				for _, group := range groupNames {
-					techs := groupToTechs[group]
-					sort.Strings(techs)
-
-					output.WriteString(colorize(fmt.Sprintf("Group: %s (%d technologies)\n", group, len(techs)), colors["subheader"], useColors))
-					output.WriteString("---------------------------\n")
-
-					for _, tech := range techs {
-						output.WriteString(colorize("• "+tech, colors["tech"], useColors) + "\n")
-					}
-
-					output.WriteString("\n")
-				}
-			} else {
-				// Regular output
-				for _, tech := range techNames {
-					values := typedResults[tech]
-					output.WriteString(colorize(tech+":", colors["tech"], useColors) + "\n")
-
-					if len(values) > 0 {
-						if *categoryFlag {
-							output.WriteString(colorize("  Categories: ", colors["cat"], useColors) + strings.Join(values, ", ") + "\n")
-						} else if *groupFlag {
-							output.WriteString(colorize("  Groups: ", colors["group"], useColors) + strings.Join(values, ", ") + "\n")
-						}
-					} else {
-						if *categoryFlag {
-							output.WriteString(colorize("  Categories: ", colors["cat"], useColors) + "None\n")
-						} else if *groupFlag {
-							output.WriteString(colorize("  Groups: ", colors["group"], useColors) + "None\n")
-						}
-					}
-					output.WriteString("\n")
-				}
-			}
-
-		case map[string]struct{}:
-			techNames := make([]string, 0, len(typedResults))
-			for tech := range typedResults {
-				techNames = append(techNames, tech)
-			}
-			sort.Strings(techNames)
-
-			for _, tech := range techNames {
-				output.WriteString(colorize(tech, colors["tech"], useColors) + "\n")
-			}
-		}
-
-		if *outputFlag != "" {
-			// Write to file
-			err = os.WriteFile(*outputFlag, []byte(output.String()), 0644)
-			if err != nil {
-				log.Fatalf("Error writing output to file: %v", err)
-			}
-			if !*silentFlag {
-				fmt.Printf("Results written to %s\n", *outputFlag)
-			}
-		} else if !*silentFlag {
-			// Write to stdout
-			fmt.Print(output.String())
-
-			// Show result count
-			var count int
-			switch typedResults := results.(type) {
-			case map[string]wappalyzer.TechInfo:
-				count = len(typedResults)
-			case map[string]models.AppInfo:
-				count = len(typedResults)
-			case map[string]models.CatsInfo:
-				count = len(typedResults)
-			case map[string][]string:
-				count = len(typedResults)
-			case map[string]struct{}:
-				count = len(typedResults)
-			}
-			fmt.Printf("Found %d technologies\n", count)
-		}
+// warnStaleFingerprints warns on stderr when expired cached fingerprints are
+// used because refreshing them failed
+func warnStaleFingerprints(w *wappalyzer.Wappalyze) {
+	info := w.DataInfo()
+	if !info.Stale || *silentFlag {
+		return
 	}
+	fmt.Fprintf(os.Stderr, "Warning: using fingerprints from %s (%s old), refreshing them failed: %v\n",
+		info.UpdatedAt.Format(time.RFC3339), info.Age().Round(time.Minute), info.RefreshError)
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// runRecord implements the record subcommand, which captures a response into
+// a regression corpus along with the technologies currently detected on it,
+// and returns the exit code
+func runRecord(args []string) int {
+	var recordHeaders HeaderFlags
+
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	corpusFlag := flags.String("corpus", "", "Corpus directory to write the response to (required)")
+	nameFlag := flags.String("name", "", "Name of the response in the corpus, derived from the URL by default")
+	methodFlag := flags.String("method", "GET", "HTTP method to use")
+	timeoutFlag := flags.Int("timeout", 10, "Timeout in seconds for HTTP requests")
+	disableSSLFlag := flags.Bool("disable-ssl", false, "Don't verify SSL certificates")
+	maxBodySizeFlag := flags.Int("max-body-size", 0, "Maximum response body size to record (0 = no limit)")
+	fingerprintsFlag := flags.String("fingerprints", "", "Local fingerprints to compute the expectations with (technologies file, directory or zip/tar archive)")
+	fingerprintURLFlag := flags.String("fingerprint-url", "", "Custom URL to download fingerprints from")
+	fingerprintVerFlag := flags.String("fingerprint-version", "", "Release tag or cached version of the fingerprints to use, instead of the latest")
+	cacheDirFlag := flags.String("cache-dir", "", "Custom cache directory for fingerprints")
+	offlineFlag := flags.Bool("offline", false, "Use cached fingerprints only, never downloading them")
+	flags.Var(&recordHeaders, "header", "HTTP headers to include (can be used multiple times)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-wappalyzer record -corpus <dir> [flags] <url>\n\n")
+		fmt.Fprintf(flags.Output(), "Records the response of a URL into a regression corpus, as a .http file,\nwith the technologies and versions detected on it as its expectations.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *corpusFlag == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	target := flags.Arg(0)
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "http://" + target
+	}
+	name := *nameFlag
+	if name == "" {
+		var err error
+		if name, err = responseName(target); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	// Load the fingerprints the expectations are computed with
+	config := downloader.DefaultConfig()
+	if *fingerprintURLFlag != "" {
+		config.ReleaseURL = *fingerprintURLFlag
+	}
+	if *cacheDirFlag != "" {
+		config.CacheDir = *cacheDirFlag
+	}
+	config.Offline = *offlineFlag
+	config.Version = *fingerprintVerFlag
+	if *fingerprintsFlag != "" {
+		source := downloader.Source{File: *fingerprintsFlag}
+		if info, err := os.Stat(*fingerprintsFlag); err == nil && info.IsDir() {
+			source = downloader.Source{Dir: *fingerprintsFlag}
+		}
+		config.Sources = []downloader.Source{source}
+	}
+	wappalyzer.SetDownloaderConfig(config)
+
+	options := []wappalyzer.Option{wappalyzer.WithAllDetections()}
+	if *maxBodySizeFlag > 0 {
+		options = append(options, wappalyzer.WithMaxBodySize(*maxBodySizeFlag))
+	}
+	w, err := wappalyzer.New(options...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading fingerprints: %v\n", err)
+		return 1
+	}
+
+	// Fetch and record the response
+	req, err := http.NewRequest(*methodFlag, target, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
+		return 1
+	}
+	for header, value := range parseHeaders(recordHeaders) {
+		req.Header.Set(header, value)
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", fmt.Sprintf("go-wappalyzer/%s", Version))
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: *disableSSLFlag}},
+		Timeout:   time.Duration(*timeoutFlag) * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error making request: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	response, err := w.RecordResponse(resp, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording response: %v\n", err)
+		return 1
+	}
+	if err := wappalyzer.SaveRecordedResponse(*corpusFlag, response); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Recorded %s (%d %s, %d bytes)\n", filepath.Join(*corpusFlag, filepath.FromSlash(name)),
+		response.StatusCode, http.StatusText(response.StatusCode), len(response.Body))
+	technologies := make([]string, 0, len(response.Expected))
+	for technology := range response.Expected {
+		technologies = append(technologies, technology)
+	}
+	sort.Strings(technologies)
+	for _, technology := range technologies {
+		if version := response.Expected[technology]; version != "" {
+			fmt.Printf("  %s %s\n", technology, version)
+		} else {
+			fmt.Printf("  %s\n", technology)
+		}
+	}
+	return 0
+}
+
+// responseName derives the name of a recorded response from its URL: the
+// host, then the path, with anything but letters, digits, dots and dashes
+// replaced by underscores
+func responseName(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %v", target, err)
+	}
+
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+				return r
+			}
+			return '_'
+		}, s)
+	}
+
+	parts := []string{clean(u.Host)}
+	for _, segment := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+		if segment != "" && segment != "." && segment != ".." {
+			parts = append(parts, clean(segment))
+		}
+	}
+	if len(parts) == 1 {
+		parts = append(parts, "index")
+	}
+	return strings.Join(parts, "/"), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/output"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// toolInfo describes go-wappalyzer in the output formats that record it
+var toolInfo = output.ToolInfo{
+	Name:    "go-wappalyzer",
+	Version: Version,
+	URL:     "https://github.com/mamamialezatoz/go-wappalyzer",
+}
+
+// buildReport analyzes a response into a report of the stable output
+// schema. With -filter-group, only the technologies of the group are kept.
+func buildReport(w *wappalyzer.Wappalyze, target string, resp *http.Response, body []byte) *output.Report {
+	report := &output.Report{
+		Target:     target,
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		ScannedAt:  time.Now().UTC(),
+	}
+
+	result := w.Analyze(resp.Header, body)
+	for _, technology := range result.Technologies {
+		if *filterGroupFlag != "" && !technology.InGroup(*filterGroupFlag) {
+			continue
+		}
+		report.Technologies = append(report.Technologies, output.Technology{
+			Name:        technology.Name,
+			Version:     technology.Version,
+			Confidence:  technology.Confidence,
+			Categories:  technology.CategoryNames(),
+			Groups:      technology.GroupNames(),
+			Description: technology.Description,
+			Website:     technology.Website,
+			CPE:         technology.CPE,
+		})
+	}
+
+	report.Normalize()
+	return report
+}
+
+// writeReport writes a report in the -format output format, to the -output
+// file or stdout
+func writeReport(report *output.Report) error {
+	var out io.Writer = os.Stdout
+	if *outputFlag != "" {
+		file, err := os.Create(*outputFlag)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	} else if *silentFlag {
+		out = io.Discard
+	}
+
+	formatter, err := output.New(*formatFlag, out, toolInfo)
+	if err != nil {
+		return err
+	}
+	if err := formatter.Write(report); err != nil {
+		return err
+	}
+	return formatter.Close()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// maxStateLine is the longest result line read back from a state file
+const maxStateLine = 16 << 20
+
+// scanState records the results of a batch scan in a JSON lines file, one
+// line per analyzed target, so an interrupted scan can be resumed. Lines are
+// only appended, a line cut short by a crash is ignored when resuming.
+type scanState struct {
+	file   *os.File
+	writer *bufio.Writer
+
+	// done holds the latest successful result of each target when resuming
+	done map[string]batchResult
+}
+
+// openState opens the state file at path. When resuming, the results it
+// holds are loaded and new ones appended. Otherwise it starts out empty, and
+// a file already holding results is only emptied when fresh is set, so that
+// forgetting -resume doesn't lose them.
+func openState(path string, resume, fresh bool) (*scanState, error) {
+	state := &scanState{done: make(map[string]batchResult)}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if resume {
+		flags = os.O_CREATE | os.O_RDWR | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open state file: %v", err)
+	}
+	state.file = file
+	state.writer = bufio.NewWriter(file)
+
+	if resume {
+		if err := state.load(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("could not read state file %s: %v", path, err)
+		}
+		return state, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not read state file %s: %v", path, err)
+	}
+	if info.Size() > 0 {
+		if !fresh {
+			file.Close()
+			return nil, fmt.Errorf("state file %s already holds results, use -resume to continue the scan or -fresh to start over", path)
+		}
+		if err := file.Truncate(0); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("could not empty state file %s: %v", path, err)
+		}
+	}
+	return state, nil
+}
+
+// load reads the results recorded in the state file, and terminates a line
+// cut short so the next result starts on a line of its own
+func (s *scanState) load() error {
+	scanner := bufio.NewScanner(s.file)
+	scanner.Buffer(make([]byte, 64*1024), maxStateLine)
+
+	for scanner.Scan() {
+		var result batchResult
+		if json.Unmarshal(scanner.Bytes(), &result) != nil || result.Target == "" || result.Error != "" {
+			continue
+		}
+		if previous, ok := s.done[result.Target]; !ok || result.ScannedAt.After(previous.ScannedAt) {
+			s.done[result.Target] = result
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// The scanner drops the newline, check whether the file ends with one
+	info, err := s.file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	end := make([]byte, 1)
+	if _, err := s.file.ReadAt(end, info.Size()-1); err != nil {
+		return err
+	}
+	if end[0] != '\n' {
+		return s.writer.WriteByte('\n')
+	}
+	return nil
+}
+
+// completed returns the recorded result of a target analyzed successfully
+// within freshness of now, or at any time when freshness is zero
+func (s *scanState) completed(target string, freshness time.Duration, now time.Time) (batchResult, bool) {
+	result, ok := s.done[target]
+	if !ok || (freshness > 0 && now.Sub(result.ScannedAt) > freshness) {
+		return batchResult{}, false
+	}
+	return result, true
+}
+
+// record appends a result to the state file
+func (s *scanState) record(result batchResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	s.writer.Write(data)
+	s.writer.WriteByte('\n')
+	return s.writer.Flush()
+}
+
+// Close flushes and closes the state file
+func (s *scanState) Close() error {
+	if err := s.writer.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/output"
+)
+
+// stateLine encodes a result as the state file records it
+func stateLine(t *testing.T, target, version, errorMessage string, scannedAt time.Time) string {
+	t.Helper()
+	result := batchResult{Report: output.Report{
+		Target:       target,
+		Technologies: []output.Technology{{Name: "Nginx", Version: version, Confidence: 100}},
+		Error:        errorMessage,
+		ScannedAt:    scannedAt,
+	}}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return string(data)
+}
+
+func TestOpenState(t *testing.T) {
+	recorded := `{"target":"example.com","technologies":[],"scanned_at":"2024-01-02T03:04:05Z"}` + "\n"
+
+	tests := []struct {
+		name     string
+		existing *string
+		resume   bool
+		fresh    bool
+		wantErr  bool
+		want     string
+	}{
+		{name: "new file", want: ""},
+		{name: "empty file", existing: new(string), want: ""},
+		{name: "file with results", existing: &recorded, wantErr: true, want: recorded},
+		{name: "file with results, fresh", existing: &recorded, fresh: true, want: ""},
+		{name: "file with results, resume", existing: &recorded, resume: true, want: recorded},
+		{name: "new file, fresh", fresh: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scan.state")
+			if tt.existing != nil {
+				if err := os.WriteFile(path, []byte(*tt.existing), 0644); err != nil {
+					t.Fatalf("could not write state file: %v", err)
+				}
+			}
+
+			state, err := openState(path, tt.resume, tt.fresh)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("openState() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if state != nil {
+				if err := state.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("could not read state file: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("state file = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+// TestStateLoad checks that resuming keeps the newest successful result of
+// each target, skips failures and unreadable lines, and terminates a last
+// line cut short before appending
+func TestStateLoad(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cut := stateLine(t, "d.example", "1.0", "", base)
+	lines := []string{
+		stateLine(t, "a.example", "1.1", "", base.Add(time.Hour)),
+		stateLine(t, "a.example", "1.2", "", base.Add(2*time.Hour)),
+		stateLine(t, "a.example", "1.0", "", base),
+		stateLine(t, "b.example", "", "request failed: timeout", base),
+		"not json",
+		stateLine(t, "c.example", "2.0", "", base),
+		stateLine(t, "c.example", "", "request failed: refused", base.Add(time.Hour)),
+		cut[:len(cut)/2],
+	}
+
+	path := filepath.Join(t.TempDir(), "scan.state")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatalf("could not write state file: %v", err)
+	}
+
+	state, err := openState(path, true, false)
+	if err != nil {
+		t.Fatalf("openState() error = %v", err)
+	}
+
+	want := map[string]string{"a.example": "1.2", "c.example": "2.0"}
+	if len(state.done) != len(want) {
+		t.Errorf("loaded %d targets, want %d", len(state.done), len(want))
+	}
+	for target, version := range want {
+		result, ok := state.done[target]
+		if !ok || result.Technologies[0].Version != version {
+			t.Errorf("%s: loaded %+v, want version %s", target, result, version)
+		}
+	}
+
+	appended := stateLine(t, "e.example", "3.0", "", base)
+	var result batchResult
+	if err := json.Unmarshal([]byte(appended), &result); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if err := state.record(result); err != nil {
+		t.Fatalf("record() error = %v", err)
+	}
+	if err := state.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The appended result is on a line of its own, and loads back
+	reopened, err := openState(path, true, false)
+	if err != nil {
+		t.Fatalf("openState() error = %v", err)
+	}
+	defer reopened.Close()
+	if _, ok := reopened.done["e.example"]; !ok {
+		t.Errorf("the result appended after a cut line was not loaded back")
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(data), "\n"+appended+"\n") {
+		t.Errorf("state file ends with %q", data[len(data)-len(appended)-2:])
+	}
+}
+
+func TestStateCompleted(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	state := &scanState{done: map[string]batchResult{
+		"recent.example": {Report: output.Report{Target: "recent.example", ScannedAt: now.Add(-30 * time.Minute)}},
+		"old.example":    {Report: output.Report{Target: "old.example", ScannedAt: now.Add(-2 * time.Hour)}},
+		"edge.example":   {Report: output.Report{Target: "edge.example", ScannedAt: now.Add(-time.Hour)}},
+	}}
+
+	tests := []struct {
+		target    string
+		freshness time.Duration
+		want      bool
+	}{
+		{"recent.example", 0, true},
+		{"old.example", 0, true},
+		{"recent.example", time.Hour, true},
+		{"edge.example", time.Hour, true},
+		{"old.example", time.Hour, false},
+		{"old.example", 3 * time.Hour, true},
+		{"unknown.example", 0, false},
+		{"unknown.example", time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		result, ok := state.completed(tt.target, tt.freshness, now)
+		if ok != tt.want {
+			t.Errorf("completed(%s, %v) = %v, want %v", tt.target, tt.freshness, ok, tt.want)
+		}
+		if ok && result.Target != tt.target {
+			t.Errorf("completed(%s, %v) returned the result of %s", tt.target, tt.freshness, result.Target)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// textColors are the colors of the parts of the text output
+var textColors = map[string]string{
+	"tech":      "\033[1;36m", // Cyan
+	"desc":      "\033[0;37m", // Light gray
+	"website":   "\033[0;33m", // Yellow
+	"cat":       "\033[0;32m", // Green
+	"group":     "\033[0;35m", // Purple
+	"header":    "\033[1;35m", // Magenta
+	"subheader": "\033[1;33m", // Yellow bold
+}
+
+// textFields selects what the text output shows of each technology
+type textFields struct {
+	info       bool
+	categories bool
+	groups     bool
+}
+
+// formatText formats a result as text: the technologies, with the details
+// selected by the -category and -group flags, listed under their groups
+// with -by-group
+func formatText(result *wappalyzer.Result, useColors bool) string {
+	fields := textFields{
+		info:       (!*categoryFlag && !*groupFlag && !*byGroupFlag && *filterGroupFlag == "") || (*categoryFlag && *groupFlag),
+		categories: *categoryFlag,
+		groups:     *groupFlag && !*byGroupFlag,
+	}
+
+	var out strings.Builder
+	if !*byGroupFlag {
+		for _, technology := range result.Technologies {
+			writeTechnology(&out, technology, fields, useColors)
+		}
+		return out.String()
+	}
+
+	// Organize the technologies by group
+	groupToTechs := make(map[string][]wappalyzer.Technology)
+	for _, technology := range result.Technologies {
+		if len(technology.Groups) == 0 {
+			groupToTechs["Uncategorized"] = append(groupToTechs["Uncategorized"], technology)
+		}
+		for _, group := range technology.Groups {
+			groupToTechs[group.Name] = append(groupToTechs[group.Name], technology)
+		}
+	}
+
+	groupNames := make([]string, 0, len(groupToTechs))
+	for group := range groupToTechs {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+
+	out.WriteString(colorize(fmt.Sprintf("Detected %d technologies in %d groups:\n", len(result.Technologies), len(groupNames)), textColors["header"], useColors))
+	out.WriteString("=======================================\n\n")
+
+	for _, group := range groupNames {
+		techs := groupToTechs[group]
+
+		out.WriteString(colorize(fmt.Sprintf("Group: %s (%d technologies)\n", group, len(techs)), textColors["subheader"], useColors))
+		out.WriteString("---------------------------\n")
+
+		for _, technology := range techs {
+			writeTechnology(&out, technology, fields, useColors)
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// writeTechnology writes a technology with the selected details, or its
+// name alone when none are
+func writeTechnology(out *strings.Builder, technology wappalyzer.Technology, fields textFields, useColors bool) {
+	if !fields.info && !fields.categories && !fields.groups {
+		prefix := ""
+		if *byGroupFlag {
+			prefix = "• "
+		}
+		out.WriteString(colorize(prefix+technology.Name, textColors["tech"], useColors) + "\n")
+		return
+	}
+
+	name := technology.Name
+	if technology.Version != "" {
+		name += " " + technology.Version
+	}
+	out.WriteString(colorize(name+":", textColors["tech"], useColors) + "\n")
+
+	if fields.info {
+		if technology.Description != "" {
+			out.WriteString(colorize("  Description: ", textColors["desc"], useColors) + technology.Description + "\n")
+		}
+		if technology.Website != "" {
+			out.WriteString(colorize("  Website: ", textColors["website"], useColors) + technology.Website + "\n")
+		}
+	}
+
+	if fields.categories {
+		categoryNames := make([]string, 0, len(technology.Categories))
+		for _, category := range technology.Categories {
+			if category.Name == "" {
+				categoryNames = append(categoryNames, fmt.Sprintf("Category %d", category.ID))
+			} else {
+				categoryNames = append(categoryNames, category.Name)
+			}
+		}
+		out.WriteString(colorize("  Categories: ", textColors["cat"], useColors) + joinOrNone(categoryNames) + "\n")
+	}
+
+	if fields.groups {
+		out.WriteString(colorize("  Groups: ", textColors["group"], useColors) + joinOrNone(technology.GroupNames()) + "\n")
+	}
+
+	out.WriteString("\n")
+}
+
+// joinOrNone joins values with commas, or returns None when there are none
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "None"
+	}
+	return strings.Join(values, ", ")
+}
@@ -5,7 +5,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 
@@ -43,34 +42,34 @@ func main() {
 		log.Fatalf("Error creating wappalyzer instance: %v", err)
 	}
 
-	// Detect technologies with basic information
-	technologies := w.FingerprintWithInfo(resp.Header, body)
+	// Detect technologies, with their versions, categories and groups
+	result := w.Analyze(resp.Header, body)
 
-	// Sort technology names for consistent output
-	names := make([]string, 0, len(technologies))
-	for name := range technologies {
-		names = append(names, name)
-	}
-	sort.Strings(names)
-
-	// Print detected technologies
+	// Print detected technologies, sorted by name
 	fmt.Printf("Detected technologies on %s:\n", targetURL)
 	fmt.Println(strings.Repeat("-", 40))
 
-	for _, name := range names {
-		info := technologies[name]
-		fmt.Printf("- %s\n", name)
+	for _, technology := range result.Technologies {
+		if technology.Version != "" {
+			fmt.Printf("- %s %s\n", technology.Name, technology.Version)
+		} else {
+			fmt.Printf("- %s\n", technology.Name)
+		}
+
+		if technology.Description != "" {
+			fmt.Printf("  Description: %s\n", technology.Description)
+		}
 
-		if info.Description != "" {
-			fmt.Printf("  Description: %s\n", info.Description)
+		if technology.Website != "" {
+			fmt.Printf("  Website: %s\n", technology.Website)
 		}
 
-		if info.Website != "" {
-			fmt.Printf("  Website: %s\n", info.Website)
+		if categories := technology.CategoryNames(); len(categories) > 0 {
+			fmt.Printf("  Categories: %s\n", strings.Join(categories, ", "))
 		}
 
 		fmt.Println()
 	}
 
-	fmt.Printf("Total: %d technologies detected\n", len(technologies))
+	fmt.Printf("Total: %d technologies detected\n", len(result.Technologies))
 }
@@ -1,3 +1,12 @@
 module github.com/mamamialezatoz/go-wappalyzer
 
 go 1.18
+
+require (
+	github.com/andybalholm/brotli v1.0.5
+	github.com/dlclark/regexp2 v1.11.4
+	golang.org/x/crypto v0.16.0
+	golang.org/x/net v0.19.0
+	golang.org/x/sys v0.15.0
+	golang.org/x/text v0.14.0
+)
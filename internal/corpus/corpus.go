@@ -0,0 +1,199 @@
+// Package corpus reads and writes recorded HTTP responses, used to replay
+// real traffic through fingerprints. A corpus is a directory tree of raw
+// HTTP/1.x responses, status line, headers and body, in files with the .http
+// extension. A response may come with the technologies it is expected to be
+// detected as, in a file of the same name with the .expected.json extension:
+//
+//	{
+//	  "technologies": {
+//	    "Nginx": "1.25.3",
+//	    "PHP": ""
+//	  }
+//	}
+//
+// mapping each technology to its expected version, empty when none is.
+package corpus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Extension is the file extension of recorded responses
+	Extension = ".http"
+
+	// ExpectedExtension is the file extension of the expected detections
+	ExpectedExtension = ".expected.json"
+)
+
+// Response is a recorded HTTP response
+type Response struct {
+	// Name identifies the response: its path in the corpus, without extension
+	Name       string
+	StatusCode int
+	Headers    map[string][]string
+	Body       []byte
+
+	// Expected maps the technologies the response should be detected as to
+	// their versions. It is nil when the corpus holds no expectations for it.
+	Expected map[string]string
+}
+
+// expectations is the format of the expected detections file
+type expectations struct {
+	Technologies map[string]string `json:"technologies"`
+}
+
+// Load reads every recorded response below dir, sorted by name
+func Load(dir string) ([]*Response, error) {
+	var responses []*Response
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != Extension {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		response, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		response.Name = strings.TrimSuffix(filepath.ToSlash(rel), Extension)
+
+		if response.Expected, err = loadExpected(strings.TrimSuffix(path, Extension) + ExpectedExtension); err != nil {
+			return err
+		}
+		responses = append(responses, response)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load corpus: %v", err)
+	}
+
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Name < responses[j].Name
+	})
+	return responses, nil
+}
+
+// Parse parses a raw HTTP response. The body is kept as received, any
+// Content-Encoding is left to the detection to decode.
+func Parse(data []byte) (*Response, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Headers: resp.Header, Body: body}, nil
+}
+
+// FromHTTP records a received response, reading its body up to maxBodySize
+// bytes, or entirely if it is 0. The body is kept as the client returned it.
+func FromHTTP(resp *http.Response, maxBodySize int64) (*Response, error) {
+	var reader io.Reader = resp.Body
+	if maxBodySize > 0 {
+		reader = io.LimitReader(resp.Body, maxBodySize)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Headers: resp.Header.Clone(), Body: body}, nil
+}
+
+// Save writes a response below dir, under its name, along with its
+// expectations if it has any. Content-Length is rewritten to the recorded
+// body, which the client may have decompressed or truncated.
+func Save(dir string, response *Response) error {
+	name := filepath.Clean(filepath.FromSlash(response.Name))
+	if response.Name == "" || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid response name %q", response.Name)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	headers := http.Header(response.Headers).Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Del("Transfer-Encoding")
+	headers.Set("Content-Length", strconv.Itoa(len(response.Body)))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", statusCode, http.StatusText(statusCode))
+	headers.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.Write(response.Body)
+
+	if err := os.WriteFile(path+Extension, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write response: %v", err)
+	}
+
+	if response.Expected == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(expectations{Technologies: response.Expected}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode expectations: %v", err)
+	}
+	if err := os.WriteFile(path+ExpectedExtension, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write expectations: %v", err)
+	}
+	return nil
+}
+
+// loadExpected reads an expected detections file, if there is one
+func loadExpected(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var expected expectations
+	if err := json.Unmarshal(data, &expected); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if expected.Technologies == nil {
+		expected.Technologies = make(map[string]string)
+	}
+	return expected.Technologies, nil
+}
@@ -0,0 +1,150 @@
+package corpus_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/corpus"
+)
+
+// TestSaveLoad checks that saved responses load back under their names, with
+// Content-Length matching the recorded body and their expectations
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	saved := []*corpus.Response{
+		{
+			Name:       "site/home",
+			StatusCode: http.StatusNotFound,
+			Headers: map[string][]string{
+				"Server":            {"nginx/1.25.3"},
+				"Set-Cookie":        {"a=1", "b=2"},
+				"Content-Length":    {"99999"},
+				"Transfer-Encoding": {"chunked"},
+			},
+			Body:     []byte("<html>Not found</html>"),
+			Expected: map[string]string{"Nginx": "1.25.3"},
+		},
+		{Name: "bare", Body: []byte("plain"), Expected: map[string]string{}},
+		{Name: "unexpected", Headers: map[string][]string{"Server": {"Apache"}}},
+	}
+	for _, response := range saved {
+		if err := corpus.Save(dir, response); err != nil {
+			t.Fatalf("Save(%s) error = %v", response.Name, err)
+		}
+	}
+
+	responses, err := corpus.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var names []string
+	for _, response := range responses {
+		names = append(names, response.Name)
+	}
+	if want := []string{"bare", "site/home", "unexpected"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Load() names = %v, want %v", names, want)
+	}
+
+	bare, home, unexpected := responses[0], responses[1], responses[2]
+	if home.StatusCode != http.StatusNotFound || string(home.Body) != "<html>Not found</html>" {
+		t.Errorf("site/home = %d %q", home.StatusCode, home.Body)
+	}
+	headers := http.Header(home.Headers)
+	if headers.Get("Content-Length") != "22" || headers.Get("Transfer-Encoding") != "" {
+		t.Errorf("site/home headers = %v, want Content-Length 22 and no Transfer-Encoding", headers)
+	}
+	if !reflect.DeepEqual(headers["Set-Cookie"], []string{"a=1", "b=2"}) {
+		t.Errorf("site/home cookies = %v", headers["Set-Cookie"])
+	}
+	if !reflect.DeepEqual(home.Expected, map[string]string{"Nginx": "1.25.3"}) {
+		t.Errorf("site/home expected = %v", home.Expected)
+	}
+
+	if bare.StatusCode != http.StatusOK || bare.Expected == nil || len(bare.Expected) != 0 {
+		t.Errorf("bare = %d, expected %#v, want 200 and no technologies", bare.StatusCode, bare.Expected)
+	}
+	if unexpected.Expected != nil {
+		t.Errorf("unexpected expected = %#v, want nil without an expectations file", unexpected.Expected)
+	}
+}
+
+func TestSaveInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", "..", "../escape", "a/../../escape", "/absolute"} {
+		if err := corpus.Save(dir, &corpus.Response{Name: name}); err == nil {
+			t.Errorf("Save(%q) saved the response", name)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"malformed response", map[string]string{"bad.http": "not an HTTP response"}},
+		{"malformed expectations", map[string]string{
+			"page.http":          "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n",
+			"page.expected.json": "{",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("could not write %s: %v", name, err)
+				}
+			}
+			if responses, err := corpus.Load(dir); err == nil {
+				t.Errorf("Load() = %d responses, want an error", len(responses))
+			}
+		})
+	}
+
+	// Files without the extension are not responses
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a response"), 0644); err != nil {
+		t.Fatalf("could not write notes.txt: %v", err)
+	}
+	if responses, err := corpus.Load(dir); err != nil || len(responses) != 0 {
+		t.Errorf("Load() = %d responses, %v, want none", len(responses), err)
+	}
+}
+
+func TestFromHTTP(t *testing.T) {
+	for _, tt := range []struct {
+		limit int64
+		want  string
+	}{
+		{0, "0123456789"},
+		{4, "0123"},
+		{20, "0123456789"},
+	} {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Server": {"nginx"}},
+			Body:       io.NopCloser(strings.NewReader("0123456789")),
+		}
+		response, err := corpus.FromHTTP(resp, tt.limit)
+		if err != nil {
+			t.Fatalf("FromHTTP() error = %v", err)
+		}
+		if string(response.Body) != tt.want || response.StatusCode != http.StatusOK {
+			t.Errorf("FromHTTP(%d) = %d %q, want %q", tt.limit, response.StatusCode, response.Body, tt.want)
+		}
+
+		// The recorded headers are a copy
+		resp.Header.Set("Server", "changed")
+		if response.Headers["Server"][0] != "nginx" {
+			t.Errorf("FromHTTP() headers share the response's")
+		}
+	}
+}
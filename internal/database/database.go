@@ -0,0 +1,197 @@
+// Package database implements the compiled fingerprint database, a versioned
+// and checksummed binary snapshot of compiled fingerprints that loads much
+// faster than decoding and compiling the JSON sources.
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+// FormatVersion is the version of the binary layout written by Encode. It is
+// also increased when the meaning of the encoded patterns changes: version 2
+// strips the directives from literal patterns.
+const FormatVersion uint32 = 2
+
+// magic identifies a compiled database file
+var magic = [8]byte{'G', 'O', 'W', 'A', 'P', 'D', 'B', 0}
+
+var (
+	// ErrInvalidDatabase is returned when the data is not a compiled database
+	ErrInvalidDatabase = errors.New("not a compiled fingerprint database")
+
+	// ErrUnsupportedVersion is returned for databases written by an incompatible release
+	ErrUnsupportedVersion = errors.New("unsupported compiled database version")
+
+	// ErrChecksumMismatch is returned when the payload is corrupted
+	ErrChecksumMismatch = errors.New("compiled database checksum mismatch")
+)
+
+// header precedes the payload of a compiled database
+type header struct {
+	Magic    [8]byte
+	Version  uint32
+	Length   uint64
+	Checksum [sha256.Size]byte
+}
+
+// Database is a compiled fingerprint database
+type Database struct {
+	// CreatedAt is when the database was compiled
+	CreatedAt time.Time
+	// Source describes where the fingerprints were loaded from
+	Source string
+	// Technologies contains the compiled technologies by name
+	Technologies map[string]*Technology
+	// Categories contains the technology categories by ID
+	Categories map[int]Category
+	// Groups contains the category groups by ID
+	Groups map[int]Group
+	// HTMLIndex is the prefilter index of the HTML patterns
+	HTMLIndex *parser.MatcherIndex
+	// ScriptIndex is the prefilter index of the script patterns
+	ScriptIndex *parser.MatcherIndex
+}
+
+// Technology contains the compiled fingerprint of a single technology
+type Technology struct {
+	Cats              []int
+	Description       string
+	Website           string
+	CPE               string
+	Icon              string
+	Implies           []string
+	HeaderPatterns    map[string]*models.ParsedPattern
+	CookiePatterns    map[string]*models.ParsedPattern
+	HTMLPatterns      []*models.ParsedPattern
+	ScriptPatterns    []*models.ParsedPattern
+	ScriptSrcPatterns []*models.ParsedPattern
+	MetaPatterns      map[string][]*models.ParsedPattern
+	JSPatterns        map[string]*models.ParsedPattern
+}
+
+// Category contains information about a technology category
+type Category struct {
+	Name     string
+	Priority int
+	Groups   []int
+}
+
+// Group contains information about a category group
+type Group struct {
+	Name string
+}
+
+// FromCompiled converts compiled fingerprints into database technologies
+func FromCompiled(compiled *models.CompiledFingerprints) map[string]*Technology {
+	technologies := make(map[string]*Technology, len(compiled.Apps))
+	for name, app := range compiled.Apps {
+		technologies[name] = &Technology{
+			Cats:              app.Cats,
+			Description:       app.Description,
+			Website:           app.Website,
+			CPE:               app.CPE,
+			Icon:              app.Icon,
+			Implies:           app.ImpliedTechs,
+			HeaderPatterns:    app.HeaderPatterns,
+			CookiePatterns:    app.CookiePatterns,
+			HTMLPatterns:      app.HTMLPatterns,
+			ScriptPatterns:    app.ScriptPatterns,
+			ScriptSrcPatterns: app.ScriptSrcPatterns,
+			MetaPatterns:      app.MetaPatterns,
+			JSPatterns:        app.JSPatterns,
+		}
+	}
+	return technologies
+}
+
+// Compiled converts the database technologies back into compiled fingerprints.
+// Regular expressions are compiled lazily on first use.
+func (db *Database) Compiled() *models.CompiledFingerprints {
+	compiled := &models.CompiledFingerprints{
+		Apps: make(map[string]*models.CompiledFingerprint, len(db.Technologies)),
+	}
+	for name, tech := range db.Technologies {
+		compiled.Apps[name] = &models.CompiledFingerprint{
+			Cats:              tech.Cats,
+			Description:       tech.Description,
+			Website:           tech.Website,
+			CPE:               tech.CPE,
+			Icon:              tech.Icon,
+			ImpliedTechs:      tech.Implies,
+			HeaderPatterns:    tech.HeaderPatterns,
+			CookiePatterns:    tech.CookiePatterns,
+			HTMLPatterns:      tech.HTMLPatterns,
+			ScriptPatterns:    tech.ScriptPatterns,
+			ScriptSrcPatterns: tech.ScriptSrcPatterns,
+			MetaPatterns:      tech.MetaPatterns,
+			JSPatterns:        tech.JSPatterns,
+		}
+	}
+	return compiled
+}
+
+// Encode writes the database in the compiled binary format
+func Encode(w io.Writer, db *Database) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(db); err != nil {
+		return fmt.Errorf("failed to encode database: %v", err)
+	}
+
+	hdr := header{
+		Magic:    magic,
+		Version:  FormatVersion,
+		Length:   uint64(payload.Len()),
+		Checksum: sha256.Sum256(payload.Bytes()),
+	}
+	if err := binary.Write(w, binary.BigEndian, &hdr); err != nil {
+		return fmt.Errorf("failed to write database header: %v", err)
+	}
+	if _, err := w.Write(payload.Bytes()); err != nil {
+		return fmt.Errorf("failed to write database payload: %v", err)
+	}
+
+	return nil
+}
+
+// Decode reads a database in the compiled binary format, verifying its
+// version and checksum
+func Decode(r io.Reader) (*Database, error) {
+	var hdr header
+	if err := binary.Read(r, binary.BigEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	if hdr.Magic != magic {
+		return nil, ErrInvalidDatabase
+	}
+	if hdr.Version != FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, hdr.Version)
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(r, int64(hdr.Length)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database payload: %v", err)
+	}
+	if uint64(len(payload)) != hdr.Length {
+		return nil, fmt.Errorf("%w: truncated payload", ErrInvalidDatabase)
+	}
+	if sha256.Sum256(payload) != hdr.Checksum {
+		return nil, ErrChecksumMismatch
+	}
+
+	var db Database
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&db); err != nil {
+		return nil, fmt.Errorf("failed to decode database: %v", err)
+	}
+
+	return &db, nil
+}
@@ -0,0 +1,190 @@
+package database_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/database"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+// Offsets in the header written by Encode: an 8 byte magic, the 4 byte
+// version, the 8 byte payload length and the 32 byte checksum
+const (
+	versionOffset = 8
+	payloadOffset = 8 + 4 + 8 + 32
+)
+
+// mustParse parses a pattern the way fingerprints are compiled
+func mustParse(t *testing.T, raw string) *models.ParsedPattern {
+	t.Helper()
+	pattern, err := parser.ParsePattern(raw)
+	if err != nil {
+		t.Fatalf("ParsePattern(%q) error = %v", raw, err)
+	}
+	return pattern
+}
+
+// testDatabase builds a small database using every kind of pattern
+func testDatabase(t *testing.T) *database.Database {
+	t.Helper()
+	htmlPatterns := map[string][]*models.ParsedPattern{
+		"WordPress": {mustParse(t, `<link rel=["']stylesheet["'] [^>]+/wp-(?:content|includes)/`)},
+		"Example":   {mustParse(t, `(?<=/)example-([\d.]+)(?=\.js)\;version:\1`), mustParse(t, `Powered by Example`)},
+	}
+	scriptPatterns := map[string][]*models.ParsedPattern{
+		"Example": {mustParse(t, `exampleInit\(`)},
+	}
+
+	compiled := &models.CompiledFingerprints{Apps: map[string]*models.CompiledFingerprint{
+		"WordPress": {
+			Cats:         []int{1, 11},
+			Description:  "WordPress is a content management system.",
+			Website:      "https://wordpress.org",
+			CPE:          "cpe:2.3:a:wordpress:wordpress:*:*:*:*:*:*:*:*",
+			Icon:         "WordPress.svg",
+			ImpliedTechs: []string{"PHP", "MySQL"},
+			HTMLPatterns: htmlPatterns["WordPress"],
+			MetaPatterns: map[string][]*models.ParsedPattern{
+				"generator": {mustParse(t, `^WordPress(?: ([\d.]+))?\;version:\1`)},
+			},
+		},
+		"Example": {
+			Cats:              []int{59},
+			Website:           "https://example.com",
+			HeaderPatterns:    map[string]*models.ParsedPattern{"X-Powered-By": mustParse(t, `Example\;confidence:50`)},
+			CookiePatterns:    map[string]*models.ParsedPattern{"example_session": mustParse(t, ``)},
+			HTMLPatterns:      htmlPatterns["Example"],
+			ScriptPatterns:    scriptPatterns["Example"],
+			ScriptSrcPatterns: []*models.ParsedPattern{mustParse(t, `example[.-]([\d.]*\d)\.js\;version:\1`)},
+			JSPatterns:        map[string]*models.ParsedPattern{"Example.version": mustParse(t, `([\d.]+)\;version:\1`)},
+		},
+	}}
+
+	return &database.Database{
+		CreatedAt:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:       "testdata",
+		Technologies: database.FromCompiled(compiled),
+		Categories: map[int]database.Category{
+			1:  {Name: "CMS", Priority: 1, Groups: []int{3}},
+			11: {Name: "Blogs", Priority: 1, Groups: []int{3}},
+			59: {Name: "JavaScript libraries", Priority: 9, Groups: []int{9}},
+		},
+		Groups:      map[int]database.Group{3: {Name: "Content"}, 9: {Name: "Web development"}},
+		HTMLIndex:   parser.CompileBodyMatcher(htmlPatterns).Index(),
+		ScriptIndex: parser.CompileBodyMatcher(scriptPatterns).Index(),
+	}
+}
+
+// encoded returns the database in the compiled binary format
+func encoded(t *testing.T, db *database.Database) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := database.Encode(&buf, db); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	db := testDatabase(t)
+	data := encoded(t, db)
+
+	decoded, err := database.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, db) {
+		t.Errorf("Decode() = %+v, want %+v", decoded, db)
+	}
+	if !reflect.DeepEqual(decoded.Compiled(), db.Compiled()) {
+		t.Errorf("Compiled() of the decoded database differs")
+	}
+
+	// The decoded index restores matchers that behave as the compiled ones
+	body := []byte(`<script src="/js/example-1.2.3.js"></script>`)
+	for name, index := range map[string]*parser.MatcherIndex{"HTML": decoded.HTMLIndex, "script": decoded.ScriptIndex} {
+		patterns := make(map[string][]*models.ParsedPattern)
+		for tech, technology := range decoded.Technologies {
+			if name == "HTML" && technology.HTMLPatterns != nil {
+				patterns[tech] = technology.HTMLPatterns
+			}
+			if name == "script" && technology.ScriptPatterns != nil {
+				patterns[tech] = technology.ScriptPatterns
+			}
+		}
+		restored, err := parser.NewBodyMatcherFromIndex(patterns, index)
+		if err != nil {
+			t.Fatalf("NewBodyMatcherFromIndex(%s) error = %v", name, err)
+		}
+
+		got, want := make(map[string]struct{}), make(map[string]struct{})
+		restored.Match(body, got)
+		parser.CompileBodyMatcher(patterns).Match(body, want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s matcher restored from the index = %v, want %v", name, got, want)
+		}
+	}
+
+	// Trailing data after the payload is not read
+	if _, err := database.Decode(bytes.NewReader(append(data, "trailing"...))); err != nil {
+		t.Errorf("Decode() with trailing data error = %v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data := encoded(t, testDatabase(t))
+
+	// corrupt returns a copy of the encoded database changed by change
+	corrupt := func(change func(data []byte) []byte) []byte {
+		return change(append([]byte(nil), data...))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, database.ErrInvalidDatabase},
+		{"short header", data[:payloadOffset-1], database.ErrInvalidDatabase},
+		{"bad magic", corrupt(func(data []byte) []byte {
+			data[0] = 'X'
+			return data
+		}), database.ErrInvalidDatabase},
+		{"JSON", []byte(`{"technologies": {}, "padding": "` + string(bytes.Repeat([]byte("x"), payloadOffset)) + `"}`), database.ErrInvalidDatabase},
+		{"older version", corrupt(func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[versionOffset:], database.FormatVersion-1)
+			return data
+		}), database.ErrUnsupportedVersion},
+		{"newer version", corrupt(func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[versionOffset:], database.FormatVersion+1)
+			return data
+		}), database.ErrUnsupportedVersion},
+		{"truncated payload", data[:len(data)-1], database.ErrInvalidDatabase},
+		{"corrupted payload", corrupt(func(data []byte) []byte {
+			data[len(data)-1] ^= 0xff
+			return data
+		}), database.ErrChecksumMismatch},
+		{"corrupted checksum", corrupt(func(data []byte) []byte {
+			data[payloadOffset-1] ^= 0xff
+			return data
+		}), database.ErrChecksumMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.Decode(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.want)
+			}
+			if db != nil {
+				t.Errorf("Decode() = %+v, want nil", db)
+			}
+		})
+	}
+}
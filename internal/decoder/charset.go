@@ -0,0 +1,61 @@
+package decoder
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// textualTypes lists the media type fragments worth converting to UTF-8
+var textualTypes = []string{"text/", "html", "xml", "javascript", "json"}
+
+// ToUTF8 converts a body to UTF-8. The encoding is taken from the BOM, the
+// charset parameter of the Content-Type header, or a <meta charset> tag.
+// Bodies that are already valid UTF-8 and carry no explicit charset are
+// returned unchanged.
+func ToUTF8(body []byte, contentType string) ([]byte, error) {
+	if len(body) == 0 || !isTextual(contentType) {
+		return body, nil
+	}
+
+	encoding, name, certain := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" {
+		return body, nil
+	}
+
+	// Without a BOM or header the guess is only a hint, trust valid UTF-8 over it
+	if !certain && utf8.Valid(body) {
+		return body, nil
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, fmt.Errorf("failed to convert body from %s: %v", name, err)
+	}
+
+	return decoded, nil
+}
+
+// isTextual reports whether a Content-Type describes a textual document.
+// An empty or unparsable Content-Type is treated as textual.
+func isTextual(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+
+	for _, textual := range textualTypes {
+		if strings.Contains(mediaType, textual) {
+			return true
+		}
+	}
+
+	return false
+}
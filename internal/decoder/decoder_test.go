@@ -0,0 +1,234 @@
+package decoder_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/decoder"
+)
+
+// plain is the body the encoded bodies of the tests decode to
+var plain = []byte(strings.Repeat(`<html><head><meta name="generator" content="WordPress 6.4.2"></head></html>`, 20))
+
+// compress applies a content coding to data
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch coding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "zlib":
+		writer = zlib.NewWriter(&buf)
+	case "flate":
+		writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("could not compress with %s: %v", coding, err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("could not compress with %s: %v", coding, err)
+	}
+	return buf.Bytes()
+}
+
+// readAll decodes a body with NewContentReader
+func readAll(body []byte, contentEncoding string) ([]byte, error) {
+	reader, err := decoder.NewContentReader(bytes.NewReader(body), contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestDecodeContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		maxSize  int64
+		want     []byte
+	}{
+		{"identity", plain, "identity", 0, plain},
+		{"no encoding", plain, "", 0, plain},
+		{"gzip", compress(t, "gzip", plain), "gzip", 0, plain},
+		{"x-gzip", compress(t, "gzip", plain), "X-Gzip", 0, plain},
+		{"gzip already decompressed", plain, "gzip", 0, plain},
+		{"zlib deflate", compress(t, "zlib", plain), "deflate", 0, plain},
+		{"raw deflate", compress(t, "flate", plain), "deflate", 0, plain},
+		{"brotli", compress(t, "br", plain), "br", 0, plain},
+		{"gzip then brotli", compress(t, "br", compress(t, "gzip", plain)), "gzip, br", 0, plain},
+		{"brotli then deflate", compress(t, "zlib", compress(t, "br", plain)), " BR ,deflate", 0, plain},
+		{"truncated to the maximum size", compress(t, "gzip", plain), "gzip", 100, plain[:100]},
+		{"maximum size larger than the body", compress(t, "br", plain), "br", int64(len(plain)) + 1, plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decoder.DecodeContent(tt.body, tt.encoding, tt.maxSize)
+			if err != nil {
+				t.Fatalf("DecodeContent() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("DecodeContent() = %q, want %q", got, tt.want)
+			}
+
+			// The streaming decoder gives the same body, up to the maximum size
+			streamed, err := readAll(tt.body, tt.encoding)
+			if err != nil {
+				t.Fatalf("NewContentReader() error = %v", err)
+			}
+			if tt.maxSize > 0 && int64(len(streamed)) > tt.maxSize {
+				streamed = streamed[:tt.maxSize]
+			}
+			if !bytes.Equal(streamed, tt.want) {
+				t.Errorf("NewContentReader() = %q, want %q", streamed, tt.want)
+			}
+		})
+	}
+}
+
+// encode converts UTF-8 text to another encoding
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	encoded, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("could not encode %q: %v", text, err)
+	}
+	return encoded
+}
+
+func TestToUTF8(t *testing.T) {
+	latin1 := `<html><body>Café crème</body></html>`
+	japaneseText := `<html><head><meta charset="shift_jis"><title>日本語のページ</title></head></html>`
+	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"charset header", encode(t, charmap.ISO8859_1, latin1), "text/html; charset=ISO-8859-1", latin1},
+		{"windows-1252 header", encode(t, charmap.Windows1252, "Price: 5€"), "text/plain; charset=windows-1252", "Price: 5€"},
+		{"meta charset", encode(t, japanese.ShiftJIS, japaneseText), "text/html", japaneseText},
+		// The BOM picks the encoding and is kept, as U+FEFF
+		{"UTF-16 BOM", encode(t, utf16, latin1), "", "\ufeff" + latin1},
+		{"UTF-8 BOM", append([]byte("\xef\xbb\xbf"), latin1...), "text/html; charset=ISO-8859-1", "\ufeff" + latin1},
+		{"valid UTF-8 without charset", []byte(latin1), "text/html", latin1},
+		{"non-textual content", encode(t, charmap.ISO8859_1, latin1), "image/png", string(encode(t, charmap.ISO8859_1, latin1))},
+		{"unparsable content type", encode(t, charmap.ISO8859_1, latin1), "text/html; charset=ISO-8859-1; =", latin1},
+		{"empty body", nil, "text/html; charset=ISO-8859-1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decoder.ToUTF8(tt.body, tt.contentType)
+			if err != nil {
+				t.Fatalf("ToUTF8() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ToUTF8() = %q, want %q", got, tt.want)
+			}
+
+			reader, err := decoder.NewUTF8Reader(bytes.NewReader(tt.body), tt.contentType)
+			if err != nil {
+				t.Fatalf("NewUTF8Reader() error = %v", err)
+			}
+			streamed, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("NewUTF8Reader() read error = %v", err)
+			}
+			if string(streamed) != tt.want {
+				t.Errorf("NewUTF8Reader() = %q, want %q", streamed, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	text := `<html><body>Café crème</body></html>`
+	body := compress(t, "gzip", encode(t, charmap.ISO8859_1, text))
+	headers := map[string][]string{
+		"content-encoding": {"gzip"},
+		"Content-Type":     {"text/html; charset=ISO-8859-1"},
+	}
+
+	got, err := decoder.DecodeBody(headers, body, 0)
+	if err != nil || string(got) != text {
+		t.Errorf("DecodeBody() = %q, %v, want %q", got, err, text)
+	}
+
+	reader, err := decoder.NewReader(headers, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if streamed, err := io.ReadAll(reader); err != nil || string(streamed) != text {
+		t.Errorf("NewReader() = %q, %v, want %q", streamed, err, text)
+	}
+}
+
+// TestCorruptBodies checks that corrupt, truncated and unsupported bodies are
+// reported as errors without panicking, and that DecodeBody falls back to
+// the body it was given
+func TestCorruptBodies(t *testing.T) {
+	gzipped := compress(t, "gzip", plain)
+	zlibbed := compress(t, "zlib", plain)
+	brotlied := compress(t, "br", plain)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+	}{
+		{"truncated gzip", gzipped[:len(gzipped)/2], "gzip"},
+		{"gzip header only", gzipped[:10], "gzip"},
+		{"gzip magic only", gzipped[:2], "gzip"},
+		{"corrupt gzip", append(append([]byte{}, gzipped[:10]...), bytes.Repeat([]byte{0xff}, 64)...), "gzip"},
+		{"truncated zlib", zlibbed[:len(zlibbed)/2], "deflate"},
+		{"zlib header only", zlibbed[:2], "deflate"},
+		{"not deflate", []byte("\xff\xff\xff\xff plain text"), "deflate"},
+		{"truncated brotli", brotlied[:len(brotlied)/2], "br"},
+		{"not brotli", []byte("<html>plain text</html>"), "br"},
+		{"unsupported coding", plain, "compress"},
+		{"unsupported outer coding", gzipped, "gzip, zstd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string][]string{"Content-Encoding": {tt.encoding}, "Content-Type": {"text/html"}}
+
+			got, err := decoder.DecodeBody(headers, tt.body, 0)
+			if err == nil {
+				t.Errorf("DecodeBody() succeeded")
+			}
+			if !bytes.Equal(got, tt.body) {
+				t.Errorf("DecodeBody() = %q, want the original body", got)
+			}
+
+			// The stream either can't be opened or fails while reading
+			reader, err := decoder.NewReader(headers, bytes.NewReader(tt.body))
+			if err == nil {
+				_, err = io.ReadAll(reader)
+			}
+			if err == nil {
+				t.Errorf("NewReader() read the body without an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Package decoder converts raw HTTP response bodies into the plain UTF-8
+// text the detection matchers expect.
+package decoder
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// gzipMagic is the two byte header every gzip stream starts with
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// DecodeBody decodes the Content-Encoding of a response body and converts it
+// to UTF-8 using the charset declared in the headers, BOM or meta tags.
+//
+// It always returns the best body available: if content decoding fails the
+// original body is returned, and if charset conversion fails the decompressed
+// body is returned, together with the error that occurred.
+func DecodeBody(headers map[string][]string, body []byte, maxSize int64) ([]byte, error) {
+	decoded, err := DecodeContent(body, HeaderValue(headers, "Content-Encoding"), maxSize)
+	if err != nil {
+		return body, err
+	}
+
+	return ToUTF8(decoded, HeaderValue(headers, "Content-Type"))
+}
+
+// DecodeContent reverses the content codings listed in a Content-Encoding
+// header value (e.g. "gzip", "br", "deflate" or "gzip, br").
+// If maxSize is greater than zero the decoded body is truncated to that size.
+func DecodeContent(body []byte, contentEncoding string, maxSize int64) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+
+	// Codings are listed in the order they were applied, so undo them in reverse
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		var reader io.Reader
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			// Some clients decompress transparently but keep the header around
+			if !bytes.HasPrefix(body, gzipMagic) {
+				continue
+			}
+			gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+			}
+			defer gzipReader.Close()
+			reader = gzipReader
+		case "deflate":
+			// "deflate" should be zlib-wrapped, but raw deflate is common in the wild
+			zlibReader, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				flateReader := flate.NewReader(bytes.NewReader(body))
+				defer flateReader.Close()
+				reader = flateReader
+			} else {
+				defer zlibReader.Close()
+				reader = zlibReader
+			}
+		case "br":
+			reader = brotli.NewReader(bytes.NewReader(body))
+		default:
+			return nil, fmt.Errorf("unsupported content encoding: %s", coding)
+		}
+
+		if maxSize > 0 {
+			reader = io.LimitReader(reader, maxSize)
+		}
+
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s body: %v", coding, err)
+		}
+		body = decoded
+	}
+
+	return body, nil
+}
+
+// HeaderValue returns the first value of a header, matching the name
+// case-insensitively since callers do not always canonicalize their maps
+func HeaderValue(headers map[string][]string, name string) string {
+	if values, ok := headers[name]; ok && len(values) > 0 {
+		return values[0]
+	}
+
+	for header, values := range headers {
+		if strings.EqualFold(header, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
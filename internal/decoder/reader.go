@@ -0,0 +1,128 @@
+package decoder
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// charsetPreviewSize is how much of a stream is inspected to find its charset
+const charsetPreviewSize = 1024
+
+// NewReader wraps a body stream so that it yields decoded UTF-8 text.
+// It is the streaming counterpart of DecodeBody.
+func NewReader(headers map[string][]string, r io.Reader) (io.Reader, error) {
+	r, err := NewContentReader(r, HeaderValue(headers, "Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUTF8Reader(r, HeaderValue(headers, "Content-Type"))
+}
+
+// NewContentReader wraps r so that the content codings listed in a
+// Content-Encoding header value are undone while reading
+func NewContentReader(r io.Reader, contentEncoding string) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+
+	// Codings are listed in the order they were applied, so undo them in reverse
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			buffered := bufio.NewReader(r)
+			r = buffered
+
+			// Some clients decompress transparently but keep the header around
+			if magic, _ := buffered.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+				continue
+			}
+			gzipReader, err := gzip.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+			}
+			r = gzipReader
+		case "deflate":
+			buffered := bufio.NewReader(r)
+
+			// "deflate" should be zlib-wrapped, but raw deflate is common in the wild
+			if header, _ := buffered.Peek(2); isZlibHeader(header) {
+				zlibReader, err := zlib.NewReader(buffered)
+				if err != nil {
+					return nil, fmt.Errorf("failed to open zlib stream: %v", err)
+				}
+				r = zlibReader
+			} else {
+				r = flate.NewReader(buffered)
+			}
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, fmt.Errorf("unsupported content encoding: %s", coding)
+		}
+	}
+
+	return r, nil
+}
+
+// NewUTF8Reader wraps r so that it yields UTF-8, using the same rules as ToUTF8
+// applied to the first kilobyte of the stream
+func NewUTF8Reader(r io.Reader, contentType string) (io.Reader, error) {
+	if !isTextual(contentType) {
+		return r, nil
+	}
+
+	buffered := bufio.NewReaderSize(r, charsetPreviewSize)
+	preview, err := buffered.Peek(charsetPreviewSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to read body preview: %v", err)
+	}
+	if len(preview) == 0 {
+		return buffered, nil
+	}
+
+	encoding, name, certain := charset.DetermineEncoding(preview, contentType)
+	if name == "utf-8" {
+		return buffered, nil
+	}
+
+	// Without a BOM or header the guess is only a hint, trust valid UTF-8 over it
+	if !certain && validUTF8Prefix(preview) {
+		return buffered, nil
+	}
+
+	return transform.NewReader(buffered, encoding.NewDecoder()), nil
+}
+
+// isZlibHeader reports whether the two bytes form a valid zlib stream header
+func isZlibHeader(header []byte) bool {
+	if len(header) < 2 {
+		return false
+	}
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// validUTF8Prefix reports whether p is valid UTF-8, ignoring a rune that
+// may have been cut off at the end of the preview
+func validUTF8Prefix(p []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(p) > 0; i++ {
+		if utf8.Valid(p) {
+			return true
+		}
+		p = p[:len(p)-1]
+	}
+	return utf8.Valid(p)
+}
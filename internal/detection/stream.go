@@ -0,0 +1,78 @@
+package detection
+
+import (
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+// StreamMatcher matches body patterns (HTML and script) against a body that
+// arrives in pieces. It keeps at most window bytes of already scanned data so
+// that matches spanning two chunks are still found, which bounds memory use to
+// roughly window+chunkSize regardless of the body size. Matches longer than the
+// window may be missed. Patterns anchored to the start of the body are only
+// evaluated against its first window+chunkSize bytes, and those anchored to
+// its end against its last window bytes or more, when flushing.
+type StreamMatcher struct {
+	matchers     []*parser.BodyMatcher
+	technologies map[string]struct{}
+	window       int
+	chunkSize    int
+	buf          []byte
+	// slid is set once the buffer no longer starts at the start of the body
+	slid bool
+}
+
+// NewStreamMatcher creates a StreamMatcher that records matches in technologies
+func NewStreamMatcher(matchers []*parser.BodyMatcher, window, chunkSize int, technologies map[string]struct{}) *StreamMatcher {
+	return &StreamMatcher{
+		matchers:     matchers,
+		technologies: technologies,
+		window:       window,
+		chunkSize:    chunkSize,
+		buf:          make([]byte, 0, window+chunkSize),
+	}
+}
+
+// Write buffers p and scans the buffer once a full chunk is available.
+// It never fails, so a StreamMatcher can be used with io.TeeReader.
+func (m *StreamMatcher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		free := m.window + m.chunkSize - len(m.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		m.buf = append(m.buf, p[:free]...)
+		p = p[free:]
+
+		if len(m.buf) == m.window+m.chunkSize {
+			m.scan(false)
+			m.slide()
+		}
+	}
+	return n, nil
+}
+
+// Flush scans any data that has not been scanned yet, along with the end of
+// the body for the patterns anchored to it
+func (m *StreamMatcher) Flush() {
+	if len(m.buf) > 0 {
+		m.scan(true)
+		m.slide()
+	}
+}
+
+// scan evaluates the patterns of every undetected technology against the
+// buffer, last being set when the buffer ends the body
+func (m *StreamMatcher) scan(last bool) {
+	for _, matcher := range m.matchers {
+		matcher.MatchPart(m.buf, !m.slid, last, m.technologies)
+	}
+}
+
+// slide keeps only the last window bytes of the buffer as overlap
+func (m *StreamMatcher) slide() {
+	if len(m.buf) > m.window {
+		m.buf = append(m.buf[:0], m.buf[len(m.buf)-m.window:]...)
+		m.slid = true
+	}
+}
@@ -0,0 +1,132 @@
+package detection_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/detection"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+const (
+	testWindow    = 32
+	testChunkSize = 64
+)
+
+// newTestMatcher compiles one body pattern per technology
+func newTestMatcher(t *testing.T, raw map[string]string) *parser.BodyMatcher {
+	t.Helper()
+	patterns := make(map[string][]*models.ParsedPattern)
+	for technology, pattern := range raw {
+		parsed, err := parser.ParsePattern(pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q) error = %v", pattern, err)
+		}
+		patterns[technology] = []*models.ParsedPattern{parsed}
+	}
+	return parser.CompileBodyMatcher(patterns)
+}
+
+// streamBody writes body to a StreamMatcher in pieces of the given size
+func streamBody(matcher *parser.BodyMatcher, body string, piece int) map[string]struct{} {
+	technologies := make(map[string]struct{})
+	stream := detection.NewStreamMatcher([]*parser.BodyMatcher{matcher}, testWindow, testChunkSize, technologies)
+	for len(body) > 0 {
+		n := piece
+		if n > len(body) {
+			n = len(body)
+		}
+		stream.Write([]byte(body[:n]))
+		body = body[n:]
+	}
+	stream.Flush()
+	return technologies
+}
+
+// TestStreamMatcherWindow checks that matches no longer than the window are
+// found wherever they fall, including across the chunk boundaries
+func TestStreamMatcherWindow(t *testing.T) {
+	matcher := newTestMatcher(t, map[string]string{
+		"Literal": "Powered by Example",
+		"Regex":   `jquery-([\d.]+)\.min\.js`,
+	})
+
+	needles := map[string]string{
+		"Literal": "Powered by Example",
+		"Regex":   "jquery-3.7.1.min.js",
+	}
+	for technology, needle := range needles {
+		// The first scan happens after window+chunk bytes, later ones every chunk
+		for offset := 0; offset < 3*(testWindow+testChunkSize); offset++ {
+			body := strings.Repeat("x", offset) + needle + strings.Repeat("y", testChunkSize)
+			for _, piece := range []int{1, 7, testChunkSize, len(body)} {
+				got := streamBody(matcher, body, piece)
+				if want := map[string]struct{}{technology: {}}; !reflect.DeepEqual(got, want) {
+					t.Fatalf("%s at offset %d in pieces of %d: got %v", technology, offset, piece, got)
+				}
+			}
+		}
+	}
+}
+
+// TestStreamMatcherFlush checks that a body shorter than a chunk, or the tail
+// of a longer one, is scanned by Flush
+func TestStreamMatcherFlush(t *testing.T) {
+	matcher := newTestMatcher(t, map[string]string{"Example": "Powered by Example"})
+
+	for _, size := range []int{0, testChunkSize, testWindow + testChunkSize, 5 * testChunkSize} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			body := strings.Repeat("x", size) + "Powered by Example"
+
+			technologies := make(map[string]struct{})
+			stream := detection.NewStreamMatcher([]*parser.BodyMatcher{matcher}, testWindow, testChunkSize, technologies)
+			stream.Write([]byte(body))
+			if size == 0 && len(technologies) != 0 {
+				t.Errorf("matched before Flush: %v", technologies)
+			}
+			stream.Flush()
+			if _, ok := technologies["Example"]; !ok {
+				t.Errorf("not matched after Flush")
+			}
+		})
+	}
+}
+
+// TestStreamMatcherNoMatch checks that a pattern split by other data is not
+// assembled from the overlap
+func TestStreamMatcherNoMatch(t *testing.T) {
+	matcher := newTestMatcher(t, map[string]string{"Example": "Powered by Example"})
+
+	body := strings.Repeat("Powered by ", 20) + strings.Repeat("z", 2*testChunkSize) + "Example"
+	if got := streamBody(matcher, body, 13); len(got) != 0 {
+		t.Errorf("got %v, want no match", got)
+	}
+}
+
+// TestStreamMatcherAnchors checks that patterns anchored to the start or the
+// end of the body only match there, not at the edges of the buffer
+func TestStreamMatcherAnchors(t *testing.T) {
+	matcher := newTestMatcher(t, map[string]string{
+		"Start": `^<!-- start -->`,
+		"End":   `</html>\s*$`,
+	})
+
+	for offset := 0; offset < 3*(testWindow+testChunkSize); offset++ {
+		for _, piece := range []int{1, 7, testChunkSize} {
+			// Both needles stay in the body, away from its start and its end
+			body := strings.Repeat("x", offset+1) + "<!-- start --></html>" + strings.Repeat("y", offset%testWindow+1)
+			if got := streamBody(matcher, body, piece); len(got) != 0 {
+				t.Fatalf("anchors away from the edges at offset %d in pieces of %d: got %v", offset, piece, got)
+			}
+
+			body = "<!-- start -->" + strings.Repeat("x", offset) + "</html>\n"
+			want := map[string]struct{}{"Start": {}, "End": {}}
+			if got := streamBody(matcher, body, piece); !reflect.DeepEqual(got, want) {
+				t.Fatalf("anchors at the edges with %d bytes between in pieces of %d: got %v", offset, piece, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package diff compares two fingerprint sets, technology by technology and
+// field by field, so a new release can be reviewed before it is rolled out.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Report lists the differences between two fingerprint sets
+type Report struct {
+	Added   []string           `json:"added"`
+	Removed []string           `json:"removed"`
+	Changed []TechnologyChange `json:"changed"`
+}
+
+// TechnologyChange lists the changed fields of a technology defined in both sets
+type TechnologyChange struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange lists the values added to and removed from a field. List
+// fields, such as patterns, categories and implied technologies, are compared
+// per item, object fields, such as headers and meta, per "key: value" pair.
+type FieldChange struct {
+	Field   string   `json:"field"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Empty reports whether the sets define the same technologies
+func (r *Report) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+// Compare compares two sets of technologies, keyed by name
+func Compare(oldTechnologies, newTechnologies map[string]json.RawMessage) (*Report, error) {
+	report := &Report{Added: []string{}, Removed: []string{}, Changed: []TechnologyChange{}}
+
+	for name := range newTechnologies {
+		if _, ok := oldTechnologies[name]; !ok {
+			report.Added = append(report.Added, name)
+		}
+	}
+	for name, oldData := range oldTechnologies {
+		newData, ok := newTechnologies[name]
+		if !ok {
+			report.Removed = append(report.Removed, name)
+			continue
+		}
+		if bytes.Equal(oldData, newData) {
+			continue
+		}
+
+		fields, err := compareTechnology(oldData, newData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s: %v", name, err)
+		}
+		if len(fields) > 0 {
+			report.Changed = append(report.Changed, TechnologyChange{Name: name, Fields: fields})
+		}
+	}
+
+	sort.Strings(report.Added)
+	sort.Strings(report.Removed)
+	sort.Slice(report.Changed, func(i, j int) bool {
+		return report.Changed[i].Name < report.Changed[j].Name
+	})
+	return report, nil
+}
+
+// compareTechnology compares the fields of two definitions of a technology
+func compareTechnology(oldData, newData json.RawMessage) ([]FieldChange, error) {
+	var oldFields, newFields map[string]json.RawMessage
+	if err := json.Unmarshal(oldData, &oldFields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(newData, &newFields); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{})
+	for name := range oldFields {
+		names[name] = struct{}{}
+	}
+	for name := range newFields {
+		names[name] = struct{}{}
+	}
+	// Technologies in the array format carry their name, which is the key
+	delete(names, "name")
+
+	var changes []FieldChange
+	for name := range names {
+		oldValues, newValues := fieldValues(oldFields[name]), fieldValues(newFields[name])
+		change := FieldChange{
+			Field:   name,
+			Added:   missing(newValues, oldValues),
+			Removed: missing(oldValues, newValues),
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// fieldValues flattens a field into the values it is compared by: the items
+// of a list, the "key: value" pairs of an object, or the value itself
+func fieldValues(data json.RawMessage) map[string]struct{} {
+	values := make(map[string]struct{})
+	if len(data) == 0 {
+		return values
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		values[string(data)] = struct{}{}
+		return values
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			values[formatValue(item)] = struct{}{}
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			// Lists of patterns under a key, as in meta, are compared per pattern
+			if list, ok := item.([]interface{}); ok {
+				for _, element := range list {
+					values[key+": "+formatValue(element)] = struct{}{}
+				}
+				continue
+			}
+			values[key+": "+formatValue(item)] = struct{}{}
+		}
+	default:
+		values[formatValue(v)] = struct{}{}
+	}
+	return values
+}
+
+// formatValue formats a decoded JSON value, strings as they are
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// missing returns the sorted values of a that b lacks
+func missing(a, b map[string]struct{}) []string {
+	var values []string
+	for value := range a {
+		if _, ok := b[value]; !ok {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,113 @@
+package diff_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/diff"
+)
+
+// technologies decodes the technologies of a test, keyed by name
+func technologies(t *testing.T, data string) map[string]json.RawMessage {
+	t.Helper()
+	var technologies map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &technologies); err != nil {
+		t.Fatalf("invalid test technologies: %v", err)
+	}
+	return technologies
+}
+
+func TestCompare(t *testing.T) {
+	oldTechnologies := technologies(t, `{
+		"Kept": {"cats": [1], "website": "https://kept.example"},
+		"Reformatted": {"cats":[1],"website":"https://reformatted.example"},
+		"Reordered": {"html": ["a", "b"], "cats": [1, 2]},
+		"Removed": {"cats": [1]},
+		"Changed": {
+			"cats": [1, 22],
+			"website": "http://changed.example",
+			"html": ["<div id=\"old\">", "kept"],
+			"headers": {"Server": "old", "X-Kept": ""},
+			"meta": {"generator": ["WordPress", "old"]},
+			"implies": "PHP",
+			"oss": true
+		}
+	}`)
+	newTechnologies := technologies(t, `{
+		"Kept": {"cats": [1], "website": "https://kept.example"},
+		"Reformatted": {
+			"website": "https://reformatted.example",
+			"cats": [ 1 ]
+		},
+		"Reordered": {"cats": [2, 1], "html": ["b", "a"]},
+		"Added": {"cats": [1]},
+		"Changed": {
+			"cats": [22, 27],
+			"website": "https://changed.example",
+			"html": "kept",
+			"headers": {"Server": "new", "X-Kept": ""},
+			"meta": {"generator": ["WordPress", "new"]},
+			"implies": ["PHP", "MySQL"],
+			"scriptSrc": "changed\\.js"
+		}
+	}`)
+
+	report, err := diff.Compare(oldTechnologies, newTechnologies)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+
+	want := &diff.Report{
+		Added:   []string{"Added"},
+		Removed: []string{"Removed"},
+		Changed: []diff.TechnologyChange{{
+			Name: "Changed",
+			Fields: []diff.FieldChange{
+				{Field: "cats", Added: []string{"27"}, Removed: []string{"1"}},
+				{Field: "headers", Added: []string{"Server: new"}, Removed: []string{"Server: old"}},
+				{Field: "html", Removed: []string{`<div id="old">`}},
+				{Field: "implies", Added: []string{"MySQL"}},
+				{Field: "meta", Added: []string{"generator: new"}, Removed: []string{"generator: old"}},
+				{Field: "oss", Removed: []string{"true"}},
+				{Field: "scriptSrc", Added: []string{`changed\.js`}},
+				{Field: "website", Added: []string{"https://changed.example"}, Removed: []string{"http://changed.example"}},
+			},
+		}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		got, _ := json.MarshalIndent(report, "", "  ")
+		expected, _ := json.MarshalIndent(want, "", "  ")
+		t.Errorf("Compare() =\n%s\nwant\n%s", got, expected)
+	}
+	if report.Empty() {
+		t.Error("Empty() = true")
+	}
+}
+
+func TestCompareIdentical(t *testing.T) {
+	set := technologies(t, `{"Nginx": {"cats": [22], "headers": {"Server": "nginx"}}}`)
+
+	report, err := diff.Compare(set, set)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if !report.Empty() {
+		t.Errorf("Compare() = %+v, want no differences", report)
+	}
+
+	// Lists are encoded as [] rather than null
+	encoded, err := json.Marshal(report)
+	if err != nil || string(encoded) != `{"added":[],"removed":[],"changed":[]}` {
+		t.Errorf("Marshal() = %s, %v", encoded, err)
+	}
+}
+
+func TestCompareInvalid(t *testing.T) {
+	oldTechnologies := map[string]json.RawMessage{"Broken": json.RawMessage(`{"cats": [1]}`)}
+	newTechnologies := map[string]json.RawMessage{"Broken": json.RawMessage(`["not", "an", "object"]`)}
+
+	if report, err := diff.Compare(oldTechnologies, newTechnologies); err == nil {
+		t.Errorf("Compare() = %+v, want an error", report)
+	}
+}
@@ -0,0 +1,461 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The cache directory holds every downloaded fingerprint set in its own
+// version directory, named after the archive digest, plus a pointer file
+// naming the current one:
+//
+//	CacheDir/
+//	├── .lock
+//	├── current
+//	├── pinned
+//	└── versions/
+//	    └── <digest>/
+//	        ├── technologies.json
+//	        ├── categories.json
+//	        ├── groups.json
+//	        └── metadata.json
+//
+// The optional pinned file names the release, by tag or version name, used
+// instead of the latest one, see Config.Version.
+//
+// Earlier versions kept the fingerprint files directly in CacheDir. Such a
+// cache is read as is until the cache is first locked, which migrates it to
+// the legacyVersion version.
+//
+// A version is only renamed into place once fully written and validated, and
+// never modified afterwards apart from its timestamps, so readers need no
+// locking. Downloads are serialized across processes by the lock file.
+const (
+	// MetadataFile is the file, next to the cached fingerprints, holding
+	// the release tag and HTTP validators of the download they came from
+	MetadataFile = "metadata.json"
+
+	// currentFile names the current version
+	currentFile = "current"
+
+	// pinnedFile names the pinned release
+	pinnedFile = "pinned"
+
+	// lockFile is the advisory lock serializing cache updates
+	lockFile = ".lock"
+
+	// legacyVersion names the version the flat layout is migrated to
+	legacyVersion = "legacy"
+)
+
+// ErrNoCache is returned when the cache holds no fingerprints
+var ErrNoCache = errors.New("no cached fingerprints")
+
+// DataInfo describes how old loaded fingerprints are
+type DataInfo struct {
+	// UpdatedAt is when the fingerprints were last downloaded or revalidated,
+	// or built for a compiled database or snapshot. It is zero when unknown.
+	UpdatedAt time.Time
+
+	// Stale is set when an expired cache was served because refreshing it failed
+	Stale bool
+
+	// RefreshError is the error refreshing the cache failed with, if Stale
+	RefreshError error
+}
+
+// Age returns how long ago the fingerprints were updated, or zero when unknown
+func (i *DataInfo) Age() time.Duration {
+	if i == nil || i.UpdatedAt.IsZero() {
+		return 0
+	}
+	return time.Since(i.UpdatedAt)
+}
+
+// merge combines the info of another source into i, keeping the oldest update
+// time and the first refresh error
+func (i *DataInfo) merge(other *DataInfo) {
+	if i.UpdatedAt.IsZero() || (!other.UpdatedAt.IsZero() && other.UpdatedAt.Before(i.UpdatedAt)) {
+		i.UpdatedAt = other.UpdatedAt
+	}
+	if other.Stale && !i.Stale {
+		i.Stale = true
+		i.RefreshError = other.RefreshError
+	}
+}
+
+// cacheFiles are the fingerprint files kept in a cache version
+var cacheFiles = []string{"technologies.json", "categories.json", "groups.json"}
+
+// cacheMetadata holds the release tag of a cache version and the HTTP
+// validators used to revalidate it
+type cacheMetadata struct {
+	URL          string `json:"url,omitempty"`
+	Tag          string `json:"tag,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// versionsDir returns the directory holding the cache versions
+func versionsDir(cacheDir string) string {
+	return filepath.Join(cacheDir, "versions")
+}
+
+// versionName names a cache version after the digest of its archive
+func versionName(zipData []byte) string {
+	sum := sha256.Sum256(zipData)
+	return hex.EncodeToString(sum[:8])
+}
+
+// CurrentCacheDir returns the directory of the current cached fingerprints
+func CurrentCacheDir(config *Config) (string, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	name, err := os.ReadFile(filepath.Join(config.CacheDir, currentFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// A flat cache not migrated yet
+			if cacheComplete(config.CacheDir) {
+				return config.CacheDir, nil
+			}
+			return "", ErrNoCache
+		}
+		return "", fmt.Errorf("failed to read current cache version: %v", err)
+	}
+
+	dir := filepath.Join(versionsDir(config.CacheDir), strings.TrimSpace(string(name)))
+	if !cacheComplete(dir) {
+		return "", ErrNoCache
+	}
+	return dir, nil
+}
+
+// currentVersion returns the directory of the current cached fingerprints, or
+// an empty string if there are none, and whether they are still fresh
+func currentVersion(config *Config) (string, bool) {
+	dir, err := CurrentCacheDir(config)
+	if err != nil {
+		return "", false
+	}
+	if config.ForceDownload {
+		return dir, false
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "technologies.json"))
+	return dir, err == nil && time.Since(info.ModTime()) < config.CacheExpiry
+}
+
+// installVersion moves an extracted version into place and, if makeCurrent
+// is set, makes it the current one. Must be called with the cache locked.
+func installVersion(config *Config, tmpDir, name string, makeCurrent bool) (string, error) {
+	cacheDir := config.CacheDir
+	dir := filepath.Join(versionsDir(cacheDir), name)
+
+	if cacheComplete(dir) {
+		// The same archive was downloaded before, only its metadata may have changed
+		data, err := os.ReadFile(filepath.Join(tmpDir, MetadataFile))
+		if err == nil {
+			err = writeFileAtomic(filepath.Join(dir, MetadataFile), data)
+		} else if os.IsNotExist(err) {
+			err = nil
+		}
+		if err == nil {
+			err = touchCache(dir)
+		}
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// Clear out anything left by an interrupted install
+		if err := os.RemoveAll(dir); err != nil {
+			return "", fmt.Errorf("failed to replace cache version: %v", err)
+		}
+		if err := os.Rename(tmpDir, dir); err != nil {
+			return "", fmt.Errorf("failed to install cache version: %v", err)
+		}
+		syncDir(versionsDir(cacheDir))
+	}
+
+	if makeCurrent {
+		if err := setCurrentVersion(cacheDir, name); err != nil {
+			return "", err
+		}
+	}
+
+	pruneVersions(config, name)
+	return dir, nil
+}
+
+// migrateLegacyCache moves the fingerprints of the flat layout into their
+// own version, made the current one if there is none. The files are copied
+// before being removed, so readers not taking the lock always find a complete
+// cache. Must be called with the cache locked.
+func migrateLegacyCache(cacheDir string) error {
+	if !cacheComplete(cacheDir) {
+		return nil
+	}
+
+	if err := os.MkdirAll(versionsDir(cacheDir), 0755); err != nil {
+		return fmt.Errorf("failed to migrate cache: %v", err)
+	}
+	tmpDir, err := os.MkdirTemp(versionsDir(cacheDir), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to migrate cache: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files := append(append([]string{}, cacheFiles...), MetadataFile)
+	for _, name := range files {
+		if err := copyFile(filepath.Join(cacheDir, name), filepath.Join(tmpDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to migrate cache: %v", err)
+		}
+	}
+
+	dir := filepath.Join(versionsDir(cacheDir), legacyVersion)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to migrate cache: %v", err)
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return fmt.Errorf("failed to migrate cache: %v", err)
+	}
+	syncDir(versionsDir(cacheDir))
+
+	if _, err := os.Stat(filepath.Join(cacheDir, currentFile)); os.IsNotExist(err) {
+		if err := setCurrentVersion(cacheDir, legacyVersion); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range files {
+		if err := os.Remove(filepath.Join(cacheDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to migrate cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies a file, keeping its modification time so the age of a
+// copied cache is unchanged
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// setCurrentVersion makes the named version the current one. Must be called
+// with the cache locked.
+func setCurrentVersion(cacheDir, name string) error {
+	if err := writeFileAtomic(filepath.Join(cacheDir, currentFile), []byte(name+"\n")); err != nil {
+		return fmt.Errorf("failed to update current cache version: %v", err)
+	}
+	return nil
+}
+
+// pruneVersions removes all but the most recently used versions, and the
+// leftovers of interrupted downloads. The current and pinned versions, and
+// the one just installed, are always kept. Must be called with the cache locked.
+func pruneVersions(config *Config, installed string) {
+	cacheDir := config.CacheDir
+	entries, err := os.ReadDir(versionsDir(cacheDir))
+	if err != nil {
+		return
+	}
+
+	kept := map[string]bool{installed: true}
+	if dir, err := CurrentCacheDir(config); err == nil {
+		kept[filepath.Base(dir)] = true
+	}
+	for _, version := range []string{config.Version, PinnedRelease(config)} {
+		if version == "" {
+			continue
+		}
+		if dir := findVersion(cacheDir, version); dir != "" {
+			kept[filepath.Base(dir)] = true
+		}
+	}
+
+	type version struct {
+		name    string
+		modTime time.Time
+	}
+	var versions []version
+
+	for _, entry := range entries {
+		path := filepath.Join(versionsDir(cacheDir), entry.Name())
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			os.RemoveAll(path)
+			continue
+		}
+		if kept[entry.Name()] {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(path, "technologies.json"))
+		if err != nil {
+			os.RemoveAll(path)
+			continue
+		}
+		versions = append(versions, version{name: entry.Name(), modTime: info.ModTime()})
+	}
+
+	// Newest first, the kept versions take their slots
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].modTime.After(versions[j].modTime)
+	})
+	keep := config.KeepVersions
+	if keep <= 0 {
+		keep = DefaultKeepVersions
+	}
+	if keep -= len(kept); keep < 0 {
+		keep = 0
+	}
+	for i := keep; i < len(versions); i++ {
+		os.RemoveAll(filepath.Join(versionsDir(cacheDir), versions[i].name))
+	}
+}
+
+// ClearCache removes all cached fingerprints, waiting for any download in
+// progress to finish first. A flat cache is migrated by the lock, then removed
+// with the other versions.
+func ClearCache(config *Config) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	unlock, err := lockCache(context.Background(), config.CacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, name := range []string{currentFile, pinnedFile} {
+		if err := os.Remove(filepath.Join(config.CacheDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear cache: %v", err)
+		}
+	}
+	if err := os.RemoveAll(versionsDir(config.CacheDir)); err != nil {
+		return fmt.Errorf("failed to clear cache: %v", err)
+	}
+
+	return nil
+}
+
+// cacheComplete reports whether all the fingerprint files are cached
+func cacheComplete(cacheDir string) bool {
+	for _, name := range cacheFiles {
+		if !fileExists(filepath.Join(cacheDir, name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadCacheMetadata returns the validators of the cached download, or nil
+// if there are none for the given URL
+func loadCacheMetadata(cacheDir, url string) *cacheMetadata {
+	metadata := readCacheMetadata(cacheDir)
+	if metadata.URL != url || (metadata.ETag == "" && metadata.LastModified == "") {
+		return nil
+	}
+	return metadata
+}
+
+// readCacheMetadata returns the metadata of a cache version, empty if it has none
+func readCacheMetadata(cacheDir string) *cacheMetadata {
+	data, err := os.ReadFile(filepath.Join(cacheDir, MetadataFile))
+	if err != nil {
+		return &cacheMetadata{}
+	}
+
+	var metadata cacheMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return &cacheMetadata{}
+	}
+	return &metadata
+}
+
+// saveCacheMetadata stores the release tag and validators of a download, if
+// there are any
+func saveCacheMetadata(cacheDir string, metadata *cacheMetadata) error {
+	if metadata.Tag == "" && metadata.ETag == "" && metadata.LastModified == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache metadata: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(cacheDir, MetadataFile), data); err != nil {
+		return fmt.Errorf("failed to write cache metadata: %v", err)
+	}
+	return nil
+}
+
+// touchCache restarts the expiry of the cached files without rewriting them
+func touchCache(cacheDir string) error {
+	now := time.Now()
+	for _, name := range cacheFiles {
+		if err := os.Chtimes(filepath.Join(cacheDir, name), now, now); err != nil {
+			return fmt.Errorf("failed to refresh cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes a file through a synced temporary file renamed over
+// it, so readers see either the old or the new contents
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir flushes a directory entry to disk after a rename, where supported
+func syncDir(dir string) {
+	if f, err := os.Open(dir); err == nil {
+		f.Sync()
+		f.Close()
+	}
+}
@@ -0,0 +1,199 @@
+package downloader_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// archiveVersion returns the name of the cache version of an archive
+func archiveVersion(archive []byte) string {
+	sum := sha256.Sum256(archive)
+	return hex.EncodeToString(sum[:8])
+}
+
+// numberedArchive returns an archive defining a single technology, named
+// after n so that every archive is distinct
+func numberedArchive(t *testing.T, n int) []byte {
+	t.Helper()
+
+	entries := validEntries()
+	entries[0].data = []byte(`{"Tech` + string(rune('A'+n)) + `":{"cats":[1],"html":"tech"}}`)
+	return buildArchive(t, entries...)
+}
+
+func TestLegacyCache(t *testing.T) {
+	server := serveRevalidating(t, buildArchive(t, validEntries()...), `"v1"`)
+	config := newConfig(t, server.Server)
+	config.CacheExpiry = time.Hour
+
+	// The flat layout of earlier versions
+	for _, entry := range validEntries() {
+		if err := os.WriteFile(filepath.Join(config.CacheDir, entry.name), entry.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadata := `{"url":"` + config.ReleaseURL + `","etag":"\"v1\""}`
+	if err := os.WriteFile(filepath.Join(config.CacheDir, downloader.MetadataFile), []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read as is offline
+	offline := *config
+	offline.Offline = true
+	technologies, _, _, err := downloader.GetFingerprints(&offline)
+	if err != nil {
+		t.Fatalf("GetFingerprints() offline error = %v", err)
+	}
+	if _, ok := technologies["Example"]; !ok {
+		t.Fatalf("GetFingerprints() technologies = %v, want Example", technologies)
+	}
+	if dir, err := downloader.CurrentCacheDir(config); err != nil || dir != config.CacheDir {
+		t.Fatalf("CurrentCacheDir() = %q, %v, want %q", dir, err, config.CacheDir)
+	}
+
+	// Migrated when refreshed, and revalidated with its validators
+	expireCache(t, config.CacheDir)
+	if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	if got := server.lastRequest().Get("If-None-Match"); got != `"v1"` {
+		t.Errorf("If-None-Match = %q, want %q", got, `"v1"`)
+	}
+	dir, err := downloader.CurrentCacheDir(config)
+	if err != nil || dir != filepath.Join(config.CacheDir, "versions", "legacy") {
+		t.Fatalf("CurrentCacheDir() = %q, %v, want the migrated version", dir, err)
+	}
+	for _, name := range []string{"technologies.json", "categories.json", "groups.json", downloader.MetadataFile} {
+		if _, err := os.Stat(filepath.Join(config.CacheDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left in the cache directory: %v", name, err)
+		}
+	}
+	if technologies, _, _, err := downloader.GetFingerprints(&offline); err != nil || technologies["Example"] == nil {
+		t.Fatalf("GetFingerprints() offline = %v, %v after migration", technologies, err)
+	}
+}
+
+func TestInstallReplacesLeftovers(t *testing.T) {
+	archive := buildArchive(t, validEntries()...)
+	server, _ := serveArchive(t, archive)
+	config := newConfig(t, server)
+
+	// Leftovers of a crashed download and of an interrupted install
+	versions := filepath.Join(config.CacheDir, "versions")
+	interrupted := filepath.Join(versions, archiveVersion(archive))
+	for _, dir := range []string{filepath.Join(versions, ".tmp-crashed"), interrupted} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "technologies.json"), []byte(`{"Partial`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	technologies, _, _, err := downloader.GetFingerprints(config)
+	if err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	if _, ok := technologies["Example"]; !ok {
+		t.Fatalf("GetFingerprints() technologies = %v, want Example", technologies)
+	}
+	if dir, err := downloader.CurrentCacheDir(config); err != nil || dir != interrupted {
+		t.Fatalf("CurrentCacheDir() = %q, %v, want %q", dir, err, interrupted)
+	}
+	if _, err := os.Stat(filepath.Join(versions, ".tmp-crashed")); !os.IsNotExist(err) {
+		t.Errorf("temporary directory not removed: %v", err)
+	}
+}
+
+func TestConcurrentReadersSeeCompleteVersions(t *testing.T) {
+	archives := [][]byte{numberedArchive(t, 0), numberedArchive(t, 1)}
+	server, current := serveArchive(t, archives[0])
+	config := newConfig(t, server)
+	if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader := *config
+			reader.Offline = true
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				technologies, _, _, err := downloader.GetFingerprints(&reader)
+				if err != nil {
+					t.Errorf("GetFingerprints() error = %v", err)
+					return
+				}
+				if technologies["TechA"] == nil && technologies["TechB"] == nil {
+					t.Errorf("GetFingerprints() technologies = %v, want TechA or TechB", technologies)
+					return
+				}
+			}
+		}()
+	}
+
+	writer := *config
+	writer.ForceDownload = true
+	for i := 0; i < 20; i++ {
+		current.Store(archives[i%2])
+		if _, _, _, err := downloader.GetFingerprints(&writer); err != nil {
+			t.Errorf("GetFingerprints() error = %v", err)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestKeepVersions(t *testing.T) {
+	server, current := serveArchive(t, nil)
+	config := newConfig(t, server)
+	config.KeepVersions = 2
+	config.ForceDownload = true
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		archive := numberedArchive(t, i)
+		current.Store(archive)
+		if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+			t.Fatalf("GetFingerprints() error = %v", err)
+		}
+		names = append(names, archiveVersion(archive))
+
+		// Order the versions by download, whatever the timestamp resolution
+		dir, err := downloader.CurrentCacheDir(config)
+		if err != nil {
+			t.Fatalf("CurrentCacheDir() error = %v", err)
+		}
+		updated := time.Now().Add(time.Duration(i-10) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, "technologies.json"), updated, updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	releases, err := downloader.ListReleases(config)
+	if err != nil {
+		t.Fatalf("ListReleases() error = %v", err)
+	}
+	if len(releases) != 2 || releases[0].Name != names[3] || releases[1].Name != names[2] {
+		t.Fatalf("ListReleases() = %+v, want %v", releases, names[2:])
+	}
+	if !releases[0].Current {
+		t.Errorf("latest release is not the current one: %+v", releases[0])
+	}
+}
@@ -3,12 +3,16 @@ package downloader
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -21,6 +25,32 @@ const (
 
 	// DefaultCacheExpiry is the default expiry time for cached fingerprints
 	DefaultCacheExpiry = 24 * time.Hour
+
+	// DefaultMaxDownloadSize is the default limit on the size of the downloaded archive
+	DefaultMaxDownloadSize = 64 << 20
+
+	// DefaultMaxEntrySize is the default limit on the uncompressed size of an archive entry
+	DefaultMaxEntrySize = 64 << 20
+
+	// DefaultMaxExtractedSize is the default limit on the total size extracted from the archive
+	DefaultMaxExtractedSize = 128 << 20
+
+	// DefaultKeepVersions is how many downloaded releases the cache keeps by default
+	DefaultKeepVersions = 3
+)
+
+var (
+	// ErrDownloadTooLarge is returned when the archive exceeds MaxDownloadSize
+	ErrDownloadTooLarge = errors.New("fingerprints archive too large")
+
+	// ErrEntryTooLarge is returned when an archive entry exceeds MaxEntrySize
+	ErrEntryTooLarge = errors.New("fingerprints archive entry too large")
+
+	// ErrExtractedTooLarge is returned when the extracted files exceed MaxExtractedSize
+	ErrExtractedTooLarge = errors.New("fingerprints archive extracts too much data")
+
+	// ErrUnexpectedEntry is returned when the archive holds anything but the fingerprint files
+	ErrUnexpectedEntry = errors.New("unexpected entry in fingerprints archive")
 )
 
 // Config contains configuration for the fingerprints downloader
@@ -40,8 +70,54 @@ type Config struct {
 	// DisableCache disables caching altogether
 	DisableCache bool
 
+	// Offline never touches the network, the cache is used regardless of its age
+	Offline bool
+
+	// AllowStale serves an expired cache when refreshing it fails, instead of
+	// failing. The returned DataInfo is then marked as stale.
+	AllowStale bool
+
+	// Version pins the fingerprints to a release, by tag or by the name of a
+	// cached version, instead of following ReleaseURL. A tag is downloaded
+	// from the matching release URL if it isn't cached yet, and is never
+	// refreshed. Without it, the release pinned in the cache is used, if any.
+	Version string
+
+	// KeepVersions is how many downloaded releases the cache keeps, the
+	// current one included, 0 uses DefaultKeepVersions. Pinned releases are
+	// always kept.
+	KeepVersions int
+
 	// Client is the HTTP client to use for downloads
 	Client *http.Client
+
+	// SHA256 is the hex encoded SHA-256 digest the downloaded archive must match
+	SHA256 string
+
+	// ChecksumURL is the URL of a checksums file, in the sha256sum format,
+	// listing the digest of the archive. Ignored if SHA256 is set.
+	ChecksumURL string
+
+	// SignatureURL is the URL of a detached signature of the archive,
+	// checked with PublicKey
+	SignatureURL string
+
+	// PublicKey is the ed25519 key signatures are checked with, either a
+	// minisign public key or a base64 encoded raw key
+	PublicKey string
+
+	// MaxDownloadSize limits the size of the downloaded archive, 0 uses DefaultMaxDownloadSize
+	MaxDownloadSize int64
+
+	// MaxEntrySize limits the uncompressed size of each archive entry, 0 uses DefaultMaxEntrySize
+	MaxEntrySize int64
+
+	// MaxExtractedSize limits the total size extracted from the archive, 0 uses DefaultMaxExtractedSize
+	MaxExtractedSize int64
+
+	// Sources are the fingerprint sources merged by GetLayeredFingerprints,
+	// in increasing order of precedence
+	Sources []Source
 }
 
 // DefaultConfig returns the default configuration
@@ -53,9 +129,22 @@ func DefaultConfig() *Config {
 		ForceDownload: false,
 		DisableCache:  false,
 		Client:        http.DefaultClient,
+
+		MaxDownloadSize:  DefaultMaxDownloadSize,
+		MaxEntrySize:     DefaultMaxEntrySize,
+		MaxExtractedSize: DefaultMaxExtractedSize,
+		KeepVersions:     DefaultKeepVersions,
 	}
 }
 
+// limit returns the configured limit, or its default when unset
+func limit(configured, fallback int64) int64 {
+	if configured > 0 {
+		return configured
+	}
+	return fallback
+}
+
 // userCacheDir returns the user's cache directory
 func userCacheDir() string {
 	// Try to use the OS-specific cache directory
@@ -81,6 +170,11 @@ func LoadTechnologiesFile(path string) (map[string]json.RawMessage, error) {
 		return nil, fmt.Errorf("failed to read file: %v", err)
 	}
 
+	return ParseTechnologies(data)
+}
+
+// ParseTechnologies parses technologies data, handling both array and object formats
+func ParseTechnologies(data []byte) (map[string]json.RawMessage, error) {
 	// First try to parse as an object (original format)
 	var objResult map[string]json.RawMessage
 	if err := json.Unmarshal(data, &objResult); err == nil {
@@ -124,53 +218,140 @@ func loadJSONFile(path string) (map[string]json.RawMessage, error) {
 
 // GetFingerprints downloads and returns the fingerprints data
 func GetFingerprints(config *Config) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	return GetFingerprintsContext(context.Background(), config)
+}
+
+// GetFingerprintsContext is like GetFingerprints, with the download bound to ctx
+func GetFingerprintsContext(ctx context.Context, config *Config) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	technologies, categories, groups, _, err := GetFingerprintsWithInfo(ctx, config)
+	return technologies, categories, groups, err
+}
+
+// GetFingerprintsWithInfo is like GetFingerprintsContext, and also describes
+// how old the returned fingerprints are
+func GetFingerprintsWithInfo(ctx context.Context, config *Config) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, *DataInfo, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	// Create cache directory if it doesn't exist and caching is enabled
-	if !config.DisableCache {
-		if err := os.MkdirAll(config.CacheDir, 0755); err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to create cache directory: %v", err)
+	// Without a cache the download only lives as long as it is being loaded
+	if config.DisableCache {
+		if config.Offline {
+			return nil, nil, nil, nil, fmt.Errorf("offline mode requires the cache: %w", ErrNoCache)
+		}
+		if config.Version != "" {
+			releaseConfig, err := releaseConfig(config, config.Version)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			config = releaseConfig
+		}
+		technologies, categories, groups, err := getUncachedFingerprints(ctx, config)
+		return technologies, categories, groups, &DataInfo{UpdatedAt: time.Now()}, err
+	}
+
+	// A pinned release is used whatever its age
+	if version := pinnedVersion(config); version != "" {
+		return loadRelease(ctx, config, version)
+	}
+
+	// Offline, whatever is cached is used regardless of its age
+	if config.Offline {
+		dir, err := CurrentCacheDir(config)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("offline mode: %w", err)
 		}
+		return loadVersion(dir, nil)
+	}
+
+	// Create cache directory if it doesn't exist
+	if err := os.MkdirAll(config.CacheDir, 0755); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to create cache directory: %v", err)
 	}
 
 	// Check if we need to download
-	needsDownload := true
-	if !config.DisableCache && !config.ForceDownload {
-		// Check if we have valid cached files
-		technologiesPath := filepath.Join(config.CacheDir, "technologies.json")
-		catsPath := filepath.Join(config.CacheDir, "categories.json")
-		groupsPath := filepath.Join(config.CacheDir, "groups.json")
-
-		if fileExists(technologiesPath) && fileExists(catsPath) && fileExists(groupsPath) {
-			// Check if files are recent enough
-			technologiesInfo, err := os.Stat(technologiesPath)
-			if err == nil && time.Since(technologiesInfo.ModTime()) < config.CacheExpiry {
-				needsDownload = false
+	dir, fresh := currentVersion(config)
+	if !fresh {
+		refreshed, err := refreshCache(ctx, config)
+		if err != nil {
+			// An expired cache beats no fingerprints at all, if allowed
+			if config.AllowStale && dir != "" {
+				return loadVersion(dir, err)
 			}
+			return nil, nil, nil, nil, fmt.Errorf("failed to download fingerprints: %w", err)
 		}
+		dir = refreshed
 	}
 
-	// Download if needed
-	if needsDownload {
-		if err := downloadAndExtractFingerprints(config); err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to download fingerprints: %v", err)
-		}
+	return loadVersion(dir, nil)
+}
+
+// refreshCache downloads the fingerprints into the cache and returns the
+// directory of the current version
+func refreshCache(ctx context.Context, config *Config) (string, error) {
+	unlock, err := lockCache(ctx, config.CacheDir)
+	if err != nil {
+		return "", err
 	}
+	defer unlock()
+
+	// Another process may have refreshed the cache while we waited for the lock
+	dir, fresh := currentVersion(config)
+	if fresh {
+		return dir, nil
+	}
+	return downloadAndExtractFingerprints(ctx, config, dir, true)
+}
+
+// loadVersion loads a cache version, which is stale if refreshing it failed
+// with refreshErr
+func loadVersion(dir string, refreshErr error) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, *DataInfo, error) {
+	technologies, categories, groups, err := loadFingerprints(dir)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	info := &DataInfo{Stale: refreshErr != nil, RefreshError: refreshErr}
+	if stat, err := os.Stat(filepath.Join(dir, "technologies.json")); err == nil {
+		info.UpdatedAt = stat.ModTime()
+	}
+
+	return technologies, categories, groups, info, nil
+}
 
-	// Load the fingerprints
-	technologies, err := LoadTechnologiesFile(filepath.Join(config.CacheDir, "technologies.json"))
+// getUncachedFingerprints downloads the fingerprints to a temporary directory
+// and loads them from there
+func getUncachedFingerprints(ctx context.Context, config *Config) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	zipData, _, err := downloadFingerprints(ctx, config, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to download fingerprints: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "go-wappalyzer-")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := extractFingerprints(config, zipData, dir); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to download fingerprints: %w", err)
+	}
+	return loadFingerprints(dir)
+}
+
+// loadFingerprints loads the fingerprint files of a directory
+func loadFingerprints(dir string) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	technologies, err := LoadTechnologiesFile(filepath.Join(dir, "technologies.json"))
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to load technologies data: %v", err)
 	}
 
-	categories, err := loadJSONFile(filepath.Join(config.CacheDir, "categories.json"))
+	categories, err := loadJSONFile(filepath.Join(dir, "categories.json"))
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to load categories data: %v", err)
 	}
 
-	groups, err := loadJSONFile(filepath.Join(config.CacheDir, "groups.json"))
+	groups, err := loadJSONFile(filepath.Join(dir, "groups.json"))
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to load groups data: %v", err)
 	}
@@ -178,40 +359,147 @@ func GetFingerprints(config *Config) (map[string]json.RawMessage, map[string]jso
 	return technologies, categories, groups, nil
 }
 
-// downloadAndExtractFingerprints downloads and extracts the fingerprints
-func downloadAndExtractFingerprints(config *Config) error {
+// downloadAndExtractFingerprints downloads the fingerprints into a new cache
+// version, made the current one if makeCurrent is set, and returns its
+// directory. The cached version, if any, is revalidated with a conditional
+// request and kept if the server reports it as not modified. Must be called
+// with the cache locked.
+func downloadAndExtractFingerprints(ctx context.Context, config *Config, cached string, makeCurrent bool) (string, error) {
+	var metadata *cacheMetadata
+	if cached != "" && !config.ForceDownload {
+		metadata = loadCacheMetadata(cached, config.ReleaseURL)
+	}
+
+	zipData, resp, err := downloadFingerprints(ctx, config, metadata)
+	if err != nil {
+		return "", err
+	}
+	if zipData == nil {
+		return cached, touchCache(cached)
+	}
+
+	// Extract next to the versions, so the finished version can be renamed in
+	if err := os.MkdirAll(versionsDir(config.CacheDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	tmpDir, err := os.MkdirTemp(versionsDir(config.CacheDir), ".tmp-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := extractFingerprints(config, zipData, tmpDir); err != nil {
+		return "", err
+	}
+
+	// Keep the validators for the next conditional request, and the release
+	// tag the version can be pinned by
+	if err := saveCacheMetadata(tmpDir, &cacheMetadata{
+		URL:          config.ReleaseURL,
+		Tag:          responseReleaseTag(resp),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}); err != nil {
+		return "", err
+	}
+
+	return installVersion(config, tmpDir, versionName(zipData), makeCurrent)
+}
+
+// downloadFingerprints downloads and verifies the fingerprints archive. If
+// metadata is given the request is conditional, and a nil archive is
+// returned when the server reports it as not modified.
+func downloadFingerprints(ctx context.Context, config *Config, metadata *cacheMetadata) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.ReleaseURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	if metadata != nil {
+		if metadata.ETag != "" {
+			req.Header.Set("If-None-Match", metadata.ETag)
+		}
+		if metadata.LastModified != "" {
+			req.Header.Set("If-Modified-Since", metadata.LastModified)
+		}
+	}
+
 	// Download the zip file
-	resp, err := config.Client.Get(config.ReleaseURL)
+	resp, err := config.Client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download fingerprints: %v", err)
+		return nil, nil, fmt.Errorf("failed to download fingerprints: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && metadata != nil {
+		return nil, resp, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	// Read the zip data
-	zipData, err := io.ReadAll(resp.Body)
+	// Read the zip data, without trusting Content-Length to bound it
+	maxSize := limit(config.MaxDownloadSize, DefaultMaxDownloadSize)
+	if resp.ContentLength > maxSize {
+		return nil, nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrDownloadTooLarge, resp.ContentLength, maxSize)
+	}
+	zipData, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		return nil, nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if int64(len(zipData)) > maxSize {
+		return nil, nil, fmt.Errorf("%w: more than %d bytes", ErrDownloadTooLarge, maxSize)
 	}
 
+	// Verify the archive before anything is extracted, so a rejected
+	// download leaves the previous cache untouched
+	if verificationEnabled(config) {
+		if err := verifyDownload(ctx, config, zipData); err != nil {
+			return nil, nil, fmt.Errorf("verification failed: %w", err)
+		}
+	}
+
+	return zipData, resp, nil
+}
+
+// extractFingerprints extracts the fingerprint files of a zip archive into
+// dir, and checks that they can be loaded. Archives holding anything else,
+// or extracting to more than the configured limits, are rejected.
+func extractFingerprints(config *Config, zipData []byte, dir string) error {
 	// Open the zip archive
 	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
 	if err != nil {
 		return fmt.Errorf("failed to open zip archive: %v", err)
 	}
 
-	// Extract required files
+	maxEntrySize := limit(config.MaxEntrySize, DefaultMaxEntrySize)
+	maxExtractedSize := limit(config.MaxExtractedSize, DefaultMaxExtractedSize)
+
+	// Check every entry before extracting any
+	extracted := make(map[string]*zip.File)
 	for _, file := range zipReader.File {
-		var destPath string
-		switch filepath.Base(file.Name) {
-		case "technologies.json", "categories.json", "groups.json":
-			destPath = filepath.Join(config.CacheDir, filepath.Base(file.Name))
-		default:
-			continue // Skip other files
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		name := path.Base(file.Name)
+		if !file.Mode().IsRegular() || !isCacheFile(name) || !isLocalPath(file.Name) {
+			return fmt.Errorf("%w: %s", ErrUnexpectedEntry, file.Name)
+		}
+		if _, ok := extracted[name]; ok {
+			return fmt.Errorf("%w: duplicate %s", ErrUnexpectedEntry, file.Name)
+		}
+		if file.UncompressedSize64 > uint64(maxEntrySize) {
+			return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrEntryTooLarge, file.Name, file.UncompressedSize64, maxEntrySize)
 		}
+		extracted[name] = file
+	}
+
+	// Extract required files, counting the bytes actually written as the
+	// sizes in the archive headers can't be trusted
+	var total int64
+	for name, file := range extracted {
+		destPath := filepath.Join(dir, name)
 
 		// Open the file in the zip
 		rc, err := file.Open()
@@ -226,18 +514,126 @@ func downloadAndExtractFingerprints(config *Config) error {
 			return fmt.Errorf("failed to create file %s: %v", destPath, err)
 		}
 
-		// Copy the contents
-		_, err = io.Copy(destFile, rc)
+		// Copy the contents, and make sure they reach the disk before the
+		// version is renamed in
+		written, err := io.Copy(destFile, io.LimitReader(rc, maxEntrySize+1))
+		total += written
+		switch {
+		case err != nil:
+		case written > maxEntrySize:
+			err = fmt.Errorf("%w: %s is more than %d bytes", ErrEntryTooLarge, file.Name, maxEntrySize)
+		case total > maxExtractedSize:
+			err = fmt.Errorf("%w: more than %d bytes", ErrExtractedTooLarge, maxExtractedSize)
+		default:
+			err = destFile.Sync()
+		}
 		rc.Close()
 		destFile.Close()
+		if errors.Is(err, ErrEntryTooLarge) || errors.Is(err, ErrExtractedTooLarge) {
+			return err
+		}
 		if err != nil {
-			return fmt.Errorf("failed to extract file %s: %v", file.Name, err)
+			// archive/zip itself rejects entries larger than their header claims
+			return fmt.Errorf("failed to extract file %s: %w", file.Name, err)
 		}
 	}
 
+	// Never install a version that can't be loaded
+	technologies, _, _, err := loadFingerprints(dir)
+	if err != nil {
+		return fmt.Errorf("invalid fingerprints archive: %v", err)
+	}
+	if len(technologies) == 0 {
+		return fmt.Errorf("invalid fingerprints archive: no technologies")
+	}
+
 	return nil
 }
 
+// ResolveReleaseTag returns the release tag the configured ReleaseURL points
+// to. A "latest" URL is resolved by following GitHub's redirect to the tagged
+// download URL.
+func ResolveReleaseTag(config *Config) (string, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	if tag := releaseTagFromURL(config.ReleaseURL); tag != "" {
+		return tag, nil
+	}
+
+	// Stop at the first redirect, its location names the tag
+	client := *config.Client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Head(config.ReleaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve release: %v", err)
+	}
+	resp.Body.Close()
+
+	if tag := releaseTagFromURL(resp.Header.Get("Location")); tag != "" {
+		return tag, nil
+	}
+
+	return "", fmt.Errorf("could not determine the release tag of %s", config.ReleaseURL)
+}
+
+// responseReleaseTag returns the release tag named by the URL of a download
+// or of any of the redirects leading to it, or an empty string
+func responseReleaseTag(resp *http.Response) string {
+	for req := resp.Request; req != nil; req = req.Response.Request {
+		if tag := releaseTagFromURL(req.URL.String()); tag != "" {
+			return tag
+		}
+		if req.Response == nil {
+			break
+		}
+	}
+	return ""
+}
+
+// releaseTagFromURL extracts the tag from a .../releases/download/<tag>/<file> URL
+func releaseTagFromURL(url string) string {
+	const marker = "/releases/download/"
+
+	index := strings.Index(url, marker)
+	if index < 0 {
+		return ""
+	}
+
+	tag := url[index+len(marker):]
+	if slash := strings.Index(tag, "/"); slash > 0 {
+		return tag[:slash]
+	}
+	return ""
+}
+
+// isCacheFile reports whether name is one of the fingerprint files
+func isCacheFile(name string) bool {
+	for _, cacheFile := range cacheFiles {
+		if name == cacheFile {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalPath reports whether an archive entry name stays within the archive
+func isLocalPath(name string) bool {
+	if strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
 // fileExists checks if a file exists
 func fileExists(path string) bool {
 	info, err := os.Stat(path)
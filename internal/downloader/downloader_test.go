@@ -0,0 +1,402 @@
+package downloader_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// archiveEntry is a file of a synthetic fingerprints archive
+type archiveEntry struct {
+	name string
+	data []byte
+}
+
+// validEntries are the files of a minimal, well-formed fingerprints archive
+func validEntries() []archiveEntry {
+	return []archiveEntry{
+		{"technologies.json", []byte(`{"Example":{"cats":[1],"html":"example"}}`)},
+		{"categories.json", []byte(`{"1":{"name":"CMS","priority":1,"groups":[1]}}`)},
+		{"groups.json", []byte(`{"1":{"name":"Content"}}`)},
+	}
+}
+
+// buildArchive creates a zip archive holding the given entries
+func buildArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			t.Fatalf("could not create %s: %v", entry.name, err)
+		}
+		if _, err := w.Write(entry.data); err != nil {
+			t.Fatalf("could not write %s: %v", entry.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("could not close archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// lyingArchive creates a valid archive, except that technologies.json
+// claims to be far smaller than it is once decompressed
+func lyingArchive(t *testing.T, size int) []byte {
+	t.Helper()
+
+	data := bytes.Repeat([]byte("a"), size)
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(data)
+	fw.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "technologies.json",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: 16,
+	})
+	if err != nil {
+		t.Fatalf("could not create raw entry: %v", err)
+	}
+	w.Write(compressed.Bytes())
+	zw.Close()
+	return buf.Bytes()
+}
+
+// serveArchive serves the archive held by the returned pointer
+func serveArchive(t *testing.T, archive []byte) (*httptest.Server, *atomic.Value) {
+	t.Helper()
+
+	var current atomic.Value
+	current.Store(archive)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(current.Load().([]byte))
+	}))
+	t.Cleanup(server.Close)
+	return server, &current
+}
+
+// newConfig returns a downloader configuration using the server and a fresh cache
+func newConfig(t *testing.T, server *httptest.Server) *downloader.Config {
+	config := downloader.DefaultConfig()
+	config.ReleaseURL = server.URL + "/wappalyzer-fingerprints.zip"
+	config.CacheDir = t.TempDir()
+	config.Client = server.Client()
+	return config
+}
+
+func TestExtractionLimits(t *testing.T) {
+	random := make([]byte, 32*1024)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) []byte
+		config  func(c *downloader.Config)
+		wantErr error
+	}{
+		{
+			name:    "valid archive",
+			archive: func(t *testing.T) []byte { return buildArchive(t, validEntries()...) },
+		},
+		{
+			name: "files in a directory",
+			archive: func(t *testing.T) []byte {
+				var entries []archiveEntry
+				for _, entry := range validEntries() {
+					entries = append(entries, archiveEntry{"fingerprints/" + entry.name, entry.data})
+				}
+				return buildArchive(t, entries...)
+			},
+		},
+		{
+			name: "download too large",
+			archive: func(t *testing.T) []byte {
+				return buildArchive(t, append(validEntries(), archiveEntry{"padding.bin", random})...)
+			},
+			config:  func(c *downloader.Config) { c.MaxDownloadSize = 16 * 1024 },
+			wantErr: downloader.ErrDownloadTooLarge,
+		},
+		{
+			name: "entry too large",
+			archive: func(t *testing.T) []byte {
+				entries := validEntries()
+				entries[0].data = append([]byte(`{"Example":{"html":"`), bytes.Repeat([]byte("a"), 1<<20)...)
+				entries[0].data = append(entries[0].data, `"}}`...)
+				return buildArchive(t, entries...)
+			},
+			config:  func(c *downloader.Config) { c.MaxEntrySize = 64 * 1024 },
+			wantErr: downloader.ErrEntryTooLarge,
+		},
+		{
+			name:    "entry larger than its header claims",
+			archive: func(t *testing.T) []byte { return lyingArchive(t, 1<<20) },
+			wantErr: zip.ErrFormat,
+		},
+		{
+			name: "total too large",
+			archive: func(t *testing.T) []byte {
+				entries := validEntries()
+				entries[1].data = append([]byte(`{"1":{"name":"`), bytes.Repeat([]byte("a"), 48*1024)...)
+				entries[1].data = append(entries[1].data, `"}}`...)
+				entries[2].data = append([]byte(`{"1":{"name":"`), bytes.Repeat([]byte("a"), 48*1024)...)
+				entries[2].data = append(entries[2].data, `"}}`...)
+				return buildArchive(t, entries...)
+			},
+			config: func(c *downloader.Config) {
+				c.MaxEntrySize = 64 * 1024
+				c.MaxExtractedSize = 80 * 1024
+			},
+			wantErr: downloader.ErrExtractedTooLarge,
+		},
+		{
+			name: "unexpected file",
+			archive: func(t *testing.T) []byte {
+				return buildArchive(t, append(validEntries(), archiveEntry{"install.sh", []byte("#!/bin/sh")})...)
+			},
+			wantErr: downloader.ErrUnexpectedEntry,
+		},
+		{
+			name: "path traversal",
+			archive: func(t *testing.T) []byte {
+				entries := validEntries()
+				entries[2].name = "../groups.json"
+				return buildArchive(t, entries...)
+			},
+			wantErr: downloader.ErrUnexpectedEntry,
+		},
+		{
+			name: "duplicate file",
+			archive: func(t *testing.T) []byte {
+				entries := validEntries()
+				return buildArchive(t, append(entries, archiveEntry{"other/technologies.json", entries[0].data})...)
+			},
+			wantErr: downloader.ErrUnexpectedEntry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := serveArchive(t, tt.archive(t))
+			config := newConfig(t, server)
+			if tt.config != nil {
+				tt.config(config)
+			}
+
+			technologies, _, _, err := downloader.GetFingerprints(config)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("GetFingerprints() error = %v", err)
+				}
+				if _, ok := technologies["Example"]; !ok {
+					t.Fatalf("GetFingerprints() technologies = %v, want Example", technologies)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetFingerprints() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := downloader.CurrentCacheDir(config); !errors.Is(err, downloader.ErrNoCache) {
+				t.Fatalf("CurrentCacheDir() error = %v, want %v", err, downloader.ErrNoCache)
+			}
+		})
+	}
+}
+
+func TestRejectedDownloadKeepsCache(t *testing.T) {
+	server, archive := serveArchive(t, buildArchive(t, validEntries()...))
+	config := newConfig(t, server)
+
+	if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	cached, err := downloader.CurrentCacheDir(config)
+	if err != nil {
+		t.Fatalf("CurrentCacheDir() error = %v", err)
+	}
+
+	archive.Store(buildArchive(t, append(validEntries(), archiveEntry{"extra.json", []byte("{}")})...))
+	config.ForceDownload = true
+	if _, _, _, err := downloader.GetFingerprints(config); !errors.Is(err, downloader.ErrUnexpectedEntry) {
+		t.Fatalf("GetFingerprints() error = %v, want %v", err, downloader.ErrUnexpectedEntry)
+	}
+
+	current, err := downloader.CurrentCacheDir(config)
+	if err != nil || current != cached {
+		t.Fatalf("CurrentCacheDir() = %q, %v, want %q", current, err, cached)
+	}
+
+	config.ForceDownload = false
+	technologies, _, _, err := downloader.GetFingerprints(config)
+	if err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	if _, ok := technologies["Example"]; !ok {
+		t.Fatalf("GetFingerprints() technologies = %v, want Example", technologies)
+	}
+}
+
+// revalidatingServer serves an archive with validators, answering matching
+// conditional requests with 304 Not Modified
+type revalidatingServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	archive      []byte
+	etag         string
+	lastModified string
+	requests     []http.Header
+}
+
+// serveRevalidating starts a server for the archive with the given ETag
+func serveRevalidating(t *testing.T, archive []byte, etag string) *revalidatingServer {
+	t.Helper()
+
+	s := &revalidatingServer{archive: archive, etag: etag, lastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.requests = append(s.requests, r.Header.Clone())
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+		w.Header().Set("Last-Modified", s.lastModified)
+		w.Write(s.archive)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// update replaces the served archive and its ETag
+func (s *revalidatingServer) update(archive []byte, etag string) {
+	s.mu.Lock()
+	s.archive, s.etag = archive, etag
+	s.mu.Unlock()
+}
+
+// lastRequest returns the headers of the last request received
+func (s *revalidatingServer) lastRequest() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+// expireCache backdates the cached files past the cache expiry
+func expireCache(t *testing.T, dir string) {
+	t.Helper()
+
+	past := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"technologies.json", "categories.json", "groups.json"} {
+		if err := os.Chtimes(filepath.Join(dir, name), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	server := serveRevalidating(t, buildArchive(t, validEntries()...), `"v1"`)
+	config := newConfig(t, server.Server)
+	config.CacheExpiry = time.Hour
+
+	if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	if header := server.lastRequest(); header.Get("If-None-Match") != "" || header.Get("If-Modified-Since") != "" {
+		t.Fatalf("first request is conditional: %v", header)
+	}
+	cached, err := downloader.CurrentCacheDir(config)
+	if err != nil {
+		t.Fatalf("CurrentCacheDir() error = %v", err)
+	}
+	before, err := os.Stat(filepath.Join(cached, "technologies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Not modified: the cache is revalidated in place
+	expireCache(t, cached)
+	_, _, _, info, err := downloader.GetFingerprintsWithInfo(context.Background(), config)
+	if err != nil {
+		t.Fatalf("GetFingerprintsWithInfo() error = %v", err)
+	}
+	header := server.lastRequest()
+	if got := header.Get("If-None-Match"); got != `"v1"` {
+		t.Errorf("If-None-Match = %q, want %q", got, `"v1"`)
+	}
+	if got := header.Get("If-Modified-Since"); got != server.lastModified {
+		t.Errorf("If-Modified-Since = %q, want %q", got, server.lastModified)
+	}
+	if current, err := downloader.CurrentCacheDir(config); err != nil || current != cached {
+		t.Fatalf("CurrentCacheDir() = %q, %v, want %q", current, err, cached)
+	}
+	after, err := os.Stat(filepath.Join(cached, "technologies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("technologies.json was rewritten on 304 Not Modified")
+	}
+	if info.Age() > time.Minute || time.Since(after.ModTime()) > time.Minute {
+		t.Errorf("cache not refreshed: age %v, modified at %v", info.Age(), after.ModTime())
+	}
+
+	// Modified: the new archive replaces the cached one
+	entries := validEntries()
+	entries[0].data = []byte(`{"Updated":{"cats":[1],"html":"updated"}}`)
+	server.update(buildArchive(t, entries...), `"v2"`)
+	expireCache(t, cached)
+
+	technologies, _, _, err := downloader.GetFingerprints(config)
+	if err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	if got := server.lastRequest().Get("If-None-Match"); got != `"v1"` {
+		t.Errorf("If-None-Match = %q, want %q", got, `"v1"`)
+	}
+	if _, ok := technologies["Updated"]; !ok {
+		t.Fatalf("GetFingerprints() technologies = %v, want Updated", technologies)
+	}
+	current, err := downloader.CurrentCacheDir(config)
+	if err != nil || current == cached {
+		t.Fatalf("CurrentCacheDir() = %q, %v, want a new version", current, err)
+	}
+	metadata, err := os.ReadFile(filepath.Join(current, downloader.MetadataFile))
+	if err != nil || !bytes.Contains(metadata, []byte(`\"v2\"`)) {
+		t.Errorf("metadata = %s, %v, want the new ETag", metadata, err)
+	}
+
+	// The new ETag is sent from then on
+	expireCache(t, current)
+	if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	if got := server.lastRequest().Get("If-None-Match"); got != `"v2"` {
+		t.Errorf("If-None-Match = %q, want %q", got, `"v2"`)
+	}
+}
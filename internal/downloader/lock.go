@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPollInterval is how often a held cache lock is retried
+const lockPollInterval = 100 * time.Millisecond
+
+// lockCache takes the advisory lock of a cache directory, waiting until it is
+// released by other processes or ctx is done, and migrates a flat cache left
+// by earlier versions. The returned function releases the lock.
+func lockCache(ctx context.Context, cacheDir string) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(cacheDir, lockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache lock: %v", err)
+	}
+
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock cache: %v", err)
+		}
+		if locked {
+			unlock := func() {
+				unlockFile(f)
+				f.Close()
+			}
+			if err := migrateLegacyCache(cacheDir); err != nil {
+				unlock()
+				return nil, err
+			}
+			return unlock, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("failed to lock cache: %v", ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package downloader
+
+import "os"
+
+// tryLockFile always succeeds where file locking is not supported, cache
+// updates are then only atomic, not serialized between processes
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlockFile releases the lock on f
+func unlockFile(f *os.File) {}
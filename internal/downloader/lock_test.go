@@ -0,0 +1,130 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows
+
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lockHelperEnv makes the test binary hold the lock of a cache directory, as
+// another process would
+const lockHelperEnv = "GO_WAPPALYZER_LOCK_HELPER"
+
+func TestLockCacheGoroutines(t *testing.T) {
+	dir := t.TempDir()
+
+	var holders, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := lockCache(context.Background(), dir)
+			if err != nil {
+				t.Errorf("lockCache() error = %v", err)
+				return
+			}
+			if atomic.AddInt32(&holders, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if overlaps != 0 {
+		t.Errorf("goroutines held the lock at once %d times", overlaps)
+	}
+}
+
+func TestLockCacheContext(t *testing.T) {
+	dir := t.TempDir()
+	unlock, err := lockCache(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("lockCache() error = %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*lockPollInterval)
+	defer cancel()
+	if _, err := lockCache(ctx, dir); err == nil {
+		t.Fatal("lockCache() succeeded while the lock is held")
+	}
+}
+
+func TestLockCacheProcesses(t *testing.T) {
+	if dir := os.Getenv(lockHelperEnv); dir != "" {
+		holdLock(t, dir)
+		return
+	}
+
+	dir := t.TempDir()
+	var cmds []*exec.Cmd
+	for i := 0; i < 3; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestLockCacheProcesses$")
+		cmd.Env = append(os.Environ(), lockHelperEnv+"="+dir)
+		var output bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &output, &output
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("could not start helper: %v", err)
+		}
+		t.Cleanup(func() {
+			if t.Failed() {
+				t.Log(output.String())
+			}
+		})
+		cmds = append(cmds, cmd)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper failed: %v", err)
+		}
+	}
+
+	// Each process logs when it takes and releases the lock, which must
+	// never interleave
+	data, err := os.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(string(data))
+	if len(lines) != 3*5*2 {
+		t.Fatalf("got %d log lines, want %d", len(lines), 3*5*2)
+	}
+	for i := 0; i < len(lines); i += 2 {
+		if lines[i] != "lock" || lines[i+1] != "unlock" {
+			t.Fatalf("processes held the lock at once:\n%s", data)
+		}
+	}
+}
+
+// holdLock takes and releases the lock of dir a few times, logging both
+func holdLock(t *testing.T, dir string) {
+	log, err := os.OpenFile(filepath.Join(dir, "log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	for i := 0; i < 5; i++ {
+		unlock, err := lockCache(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("lockCache() error = %v", err)
+		}
+		log.WriteString("lock\n")
+		time.Sleep(10 * time.Millisecond)
+		log.WriteString("unlock\n")
+		unlock()
+	}
+}
@@ -0,0 +1,320 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Release is a fingerprints release kept in the cache
+type Release struct {
+	// Name is the name of the cache version, after the digest of the archive
+	Name string `json:"name"`
+
+	// Tag is the release tag, empty if the archive didn't come from a tagged release
+	Tag string `json:"tag,omitempty"`
+
+	// URL is the URL the archive was downloaded from
+	URL string `json:"url,omitempty"`
+
+	// UpdatedAt is when the release was last downloaded or revalidated
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Current is set for the release used when none is pinned
+	Current bool `json:"current"`
+
+	// Pinned is set for the pinned release
+	Pinned bool `json:"pinned"`
+
+	// Dir is the directory holding the fingerprint files
+	Dir string `json:"-"`
+}
+
+// ReleaseURLForVersion returns the URL of the archive of a tagged release,
+// given the URL of the archive of the latest or of any other tagged release
+func ReleaseURLForVersion(releaseURL, version string) (string, error) {
+	const (
+		latestMarker   = "/releases/latest/download/"
+		downloadMarker = "/releases/download/"
+	)
+	tag := url.PathEscape(version)
+
+	if index := strings.Index(releaseURL, latestMarker); index >= 0 {
+		return releaseURL[:index] + downloadMarker + tag + "/" + releaseURL[index+len(latestMarker):], nil
+	}
+	if index := strings.Index(releaseURL, downloadMarker); index >= 0 {
+		rest := releaseURL[index+len(downloadMarker):]
+		if slash := strings.Index(rest, "/"); slash > 0 {
+			return releaseURL[:index] + downloadMarker + tag + rest[slash:], nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot pin release %s: %s is not a release download URL", version, releaseURL)
+}
+
+// ListReleases returns the releases kept in the cache, most recently updated first
+func ListReleases(config *Config) ([]Release, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	releases, err := cachedReleases(config.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	current, _ := CurrentCacheDir(config)
+	pinned := ""
+	if version := PinnedRelease(config); version != "" {
+		pinned = findVersion(config.CacheDir, version)
+	}
+	for i := range releases {
+		releases[i].Current = releases[i].Dir == current
+		releases[i].Pinned = releases[i].Dir == pinned
+	}
+
+	return releases, nil
+}
+
+// PinnedRelease returns the release pinned in the cache, by tag or version
+// name, or an empty string if none is
+func PinnedRelease(config *Config) string {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.CacheDir, pinnedFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// PinRelease pins the cache to a release, by tag or version name, and makes
+// it the current one. A tag that isn't cached yet is downloaded first, unless
+// the configuration is offline. Fingerprints loaded from the cache then come
+// from the pinned release, whatever its age, until UnpinRelease is called.
+func PinRelease(ctx context.Context, config *Config, version string) (*Release, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if version == "" {
+		return nil, fmt.Errorf("no release to pin")
+	}
+
+	if err := os.MkdirAll(config.CacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	unlock, err := lockCache(ctx, config.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	dir, err := fetchRelease(ctx, config, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(filepath.Join(config.CacheDir, pinnedFile), []byte(version+"\n")); err != nil {
+		return nil, fmt.Errorf("failed to pin release: %v", err)
+	}
+	if err := setCurrentVersion(config.CacheDir, filepath.Base(dir)); err != nil {
+		return nil, err
+	}
+
+	return cachedRelease(dir, true, true), nil
+}
+
+// RollbackRelease pins the cache to the release updated before the current
+// one, see PinRelease. Repeated rollbacks go further back.
+func RollbackRelease(ctx context.Context, config *Config) (*Release, error) {
+	releases, err := ListReleases(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, release := range releases {
+		if release.Current {
+			if i+1 == len(releases) {
+				break
+			}
+			// Pinned by name, tags may be shared by several downloads
+			return PinRelease(ctx, config, releases[i+1].Name)
+		}
+	}
+
+	return nil, fmt.Errorf("no release cached before the current one: %w", ErrNoCache)
+}
+
+// UnpinRelease removes the pin of the cache. The previously pinned release
+// stays the current one until the cache expires and the latest release is
+// downloaded again.
+func UnpinRelease(config *Config) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	unlock, err := lockCache(context.Background(), config.CacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(filepath.Join(config.CacheDir, pinnedFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to unpin release: %v", err)
+	}
+	return nil
+}
+
+// pinnedVersion returns the release the configuration is pinned to, if any
+func pinnedVersion(config *Config) string {
+	if config.Version != "" {
+		return config.Version
+	}
+	return PinnedRelease(config)
+}
+
+// loadRelease loads a pinned release, downloading it if it isn't cached yet
+func loadRelease(ctx context.Context, config *Config, version string) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, *DataInfo, error) {
+	if dir := findVersion(config.CacheDir, version); dir != "" {
+		return loadVersion(dir, nil)
+	}
+	if config.Offline {
+		return nil, nil, nil, nil, fmt.Errorf("offline mode: release %s: %w", version, ErrNoCache)
+	}
+
+	if err := os.MkdirAll(config.CacheDir, 0755); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	unlock, err := lockCache(ctx, config.CacheDir)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	dir, err := fetchRelease(ctx, config, version)
+	unlock()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return loadVersion(dir, nil)
+}
+
+// fetchRelease returns the directory of a cached release, downloading it
+// without making it the current one if needed. Must be called with the cache
+// locked.
+func fetchRelease(ctx context.Context, config *Config, version string) (string, error) {
+	if dir := findVersion(config.CacheDir, version); dir != "" {
+		return dir, nil
+	}
+	if config.Offline {
+		return "", fmt.Errorf("offline mode: release %s: %w", version, ErrNoCache)
+	}
+
+	releaseConfig, err := releaseConfig(config, version)
+	if err != nil {
+		return "", err
+	}
+	dir, err := downloadAndExtractFingerprints(ctx, releaseConfig, "", false)
+	if err != nil {
+		return "", fmt.Errorf("failed to download release %s: %w", version, err)
+	}
+	return dir, nil
+}
+
+// releaseConfig returns the configuration downloading a tagged release. The
+// checksum and signature URLs must point to a release, and are followed to
+// the tagged one. SHA256 is the digest of the configured release only, so a
+// tag verified by nothing else cannot be downloaded.
+func releaseConfig(config *Config, version string) (*Config, error) {
+	releaseURL, err := ReleaseURLForVersion(config.ReleaseURL, version)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := *config
+	pinned.ReleaseURL = releaseURL
+	pinned.Version = version
+	pinned.SHA256 = ""
+	if config.ChecksumURL != "" {
+		if pinned.ChecksumURL, err = ReleaseURLForVersion(config.ChecksumURL, version); err != nil {
+			return nil, err
+		}
+	}
+	if config.SignatureURL != "" {
+		if pinned.SignatureURL, err = ReleaseURLForVersion(config.SignatureURL, version); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.SHA256 != "" && !verificationEnabled(&pinned) {
+		return nil, fmt.Errorf("cannot verify release %s: SHA256 only matches %s, set ChecksumURL or SignatureURL", version, config.ReleaseURL)
+	}
+	return &pinned, nil
+}
+
+// findVersion returns the directory of the most recently updated cached
+// release with the given tag or version name, or an empty string
+func findVersion(cacheDir, version string) string {
+	releases, err := cachedReleases(cacheDir)
+	if err != nil {
+		return ""
+	}
+
+	for _, release := range releases {
+		if release.Name == version || release.Tag == version {
+			return release.Dir
+		}
+	}
+	return ""
+}
+
+// cachedReleases returns the complete versions of the cache, most recently
+// updated first
+func cachedReleases(cacheDir string) ([]Release, error) {
+	entries, err := os.ReadDir(versionsDir(cacheDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list cache versions: %v", err)
+	}
+
+	var releases []Release
+	for _, entry := range entries {
+		dir := filepath.Join(versionsDir(cacheDir), entry.Name())
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") || !cacheComplete(dir) {
+			continue
+		}
+		releases = append(releases, *cachedRelease(dir, false, false))
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].UpdatedAt.After(releases[j].UpdatedAt)
+	})
+	return releases, nil
+}
+
+// cachedRelease describes the cache version in dir
+func cachedRelease(dir string, current, pinned bool) *Release {
+	metadata := readCacheMetadata(dir)
+
+	release := &Release{
+		Name:    filepath.Base(dir),
+		Tag:     metadata.Tag,
+		URL:     metadata.URL,
+		Current: current,
+		Pinned:  pinned,
+		Dir:     dir,
+	}
+	if info, err := os.Stat(filepath.Join(dir, "technologies.json")); err == nil {
+		release.UpdatedAt = info.ModTime()
+	}
+	return release
+}
@@ -0,0 +1,270 @@
+package downloader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+const (
+	// latestPath and taggedPath are the release download paths of the test servers
+	latestPath = "/owner/fingerprints/releases/latest/download/"
+	taggedPath = "/owner/fingerprints/releases/download/"
+)
+
+// serveReleases serves an archive per tag, whose technology is named after
+// the tag, and latest as the newest release
+func serveReleases(t *testing.T, tags ...string) (*fileServer, map[string][]byte) {
+	t.Helper()
+
+	archives := make(map[string][]byte)
+	files := make(map[string][]byte)
+	for _, tag := range append(tags, "latest") {
+		entries := validEntries()
+		entries[0].data = []byte(`{"Tech-` + tag + `":{"cats":[1],"html":"tech"}}`)
+		archives[tag] = buildArchive(t, entries...)
+
+		path := taggedPath + tag + "/"
+		if tag == "latest" {
+			path = latestPath
+		}
+		files[path+"wappalyzer-fingerprints.zip"] = archives[tag]
+		files[path+"SHA256SUMS"] = []byte(sha256Hex(archives[tag]) + "  wappalyzer-fingerprints.zip\n")
+	}
+	return serveFiles(t, files), archives
+}
+
+// releaseConfig returns a configuration downloading the latest release of server
+func releaseConfig(t *testing.T, server *fileServer) *downloader.Config {
+	config := newConfig(t, server.Server)
+	config.ReleaseURL = server.URL + latestPath + "wappalyzer-fingerprints.zip"
+	return config
+}
+
+// loadedTechnology returns the single technology loaded with config
+func loadedTechnology(t *testing.T, config *downloader.Config) string {
+	t.Helper()
+
+	technologies, _, _, err := downloader.GetFingerprints(config)
+	if err != nil {
+		t.Fatalf("GetFingerprints() error = %v", err)
+	}
+	for name := range technologies {
+		return name
+	}
+	return ""
+}
+
+// backdateRelease orders the cached releases by the order of the calls,
+// whatever the timestamp resolution
+func backdateRelease(t *testing.T, dir string, minutes int) {
+	t.Helper()
+
+	updated := time.Now().Add(-time.Duration(minutes) * time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "technologies.json"), updated, updated); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseURLForVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		releaseURL string
+		version    string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "latest release",
+			releaseURL: "https://github.com/owner/repo/releases/latest/download/fingerprints.zip",
+			version:    "v1.2.3",
+			want:       "https://github.com/owner/repo/releases/download/v1.2.3/fingerprints.zip",
+		},
+		{
+			name:       "tagged release",
+			releaseURL: "https://github.com/owner/repo/releases/download/v1.0.0/fingerprints.zip",
+			version:    "v1.2.3",
+			want:       "https://github.com/owner/repo/releases/download/v1.2.3/fingerprints.zip",
+		},
+		{
+			name:       "escaped tag",
+			releaseURL: "https://github.com/owner/repo/releases/latest/download/SHA256SUMS",
+			version:    "release 1/2",
+			want:       "https://github.com/owner/repo/releases/download/release%201%2F2/SHA256SUMS",
+		},
+		{
+			name:       "not a release URL",
+			releaseURL: "https://example.com/fingerprints.zip",
+			version:    "v1.2.3",
+			wantErr:    true,
+		},
+		{
+			name:       "tagged release without a file",
+			releaseURL: "https://github.com/owner/repo/releases/download/v1.0.0",
+			version:    "v1.2.3",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := downloader.ReleaseURLForVersion(tt.releaseURL, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReleaseURLForVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReleaseURLForVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinRollbackUnpin(t *testing.T) {
+	server, _ := serveReleases(t, "v1", "v2")
+	config := releaseConfig(t, server)
+	ctx := context.Background()
+
+	if got := loadedTechnology(t, config); got != "Tech-latest" {
+		t.Fatalf("loaded %s, want Tech-latest", got)
+	}
+	latest, err := downloader.CurrentCacheDir(config)
+	if err != nil {
+		t.Fatalf("CurrentCacheDir() error = %v", err)
+	}
+	backdateRelease(t, latest, 30)
+
+	// Pinning downloads the tag and makes it current
+	release, err := downloader.PinRelease(ctx, config, "v1")
+	if err != nil {
+		t.Fatalf("PinRelease() error = %v", err)
+	}
+	if release.Tag != "v1" || !release.Pinned || !release.Current {
+		t.Fatalf("PinRelease() = %+v, want v1 pinned and current", release)
+	}
+	backdateRelease(t, release.Dir, 20)
+	if got := downloader.PinnedRelease(config); got != "v1" {
+		t.Errorf("PinnedRelease() = %q, want v1", got)
+	}
+	forced := *config
+	forced.ForceDownload = true
+	if got := loadedTechnology(t, &forced); got != "Tech-v1" {
+		t.Fatalf("loaded %s with the pin, want Tech-v1", got)
+	}
+
+	if release, err = downloader.PinRelease(ctx, config, "v2"); err != nil {
+		t.Fatalf("PinRelease() error = %v", err)
+	}
+	backdateRelease(t, release.Dir, 10)
+	if got := loadedTechnology(t, config); got != "Tech-v2" {
+		t.Fatalf("loaded %s, want Tech-v2", got)
+	}
+
+	// Rolling back pins the release updated before the current one
+	if release, err = downloader.RollbackRelease(ctx, config); err != nil {
+		t.Fatalf("RollbackRelease() error = %v", err)
+	}
+	if release.Tag != "v1" {
+		t.Fatalf("RollbackRelease() = %+v, want v1", release)
+	}
+	if got := loadedTechnology(t, config); got != "Tech-v1" {
+		t.Fatalf("loaded %s after rollback, want Tech-v1", got)
+	}
+	releases, err := downloader.ListReleases(config)
+	if err != nil || len(releases) != 3 {
+		t.Fatalf("ListReleases() = %+v, %v, want 3 releases", releases, err)
+	}
+	for _, r := range releases {
+		if (r.Tag == "v1") != (r.Pinned && r.Current) || (r.Tag != "v1" && (r.Pinned || r.Current)) {
+			t.Errorf("release %+v, want only v1 pinned and current", r)
+		}
+	}
+
+	// Unpinned, the release stays current until the cache expires
+	if err := downloader.UnpinRelease(config); err != nil {
+		t.Fatalf("UnpinRelease() error = %v", err)
+	}
+	if got := downloader.PinnedRelease(config); got != "" {
+		t.Errorf("PinnedRelease() = %q after unpinning", got)
+	}
+	if got := loadedTechnology(t, config); got != "Tech-v1" {
+		t.Fatalf("loaded %s after unpinning, want Tech-v1", got)
+	}
+	if got := loadedTechnology(t, &forced); got != "Tech-latest" {
+		t.Fatalf("loaded %s after refreshing, want Tech-latest", got)
+	}
+}
+
+func TestPinnedReleaseVerification(t *testing.T) {
+	server, archives := serveReleases(t, "v1")
+
+	tests := []struct {
+		name    string
+		config  func(c *downloader.Config)
+		wantErr bool
+	}{
+		{
+			name: "checksums of the tagged release",
+			config: func(c *downloader.Config) {
+				c.ChecksumURL = server.URL + latestPath + "SHA256SUMS"
+			},
+		},
+		{
+			name: "digest of the latest release only",
+			config: func(c *downloader.Config) {
+				c.SHA256 = sha256Hex(archives["latest"])
+			},
+			wantErr: true,
+		},
+		{
+			name: "digest and checksums of the tagged release",
+			config: func(c *downloader.Config) {
+				c.SHA256 = sha256Hex(archives["latest"])
+				c.ChecksumURL = server.URL + latestPath + "SHA256SUMS"
+			},
+		},
+		{
+			name: "checksums outside of a release",
+			config: func(c *downloader.Config) {
+				c.ChecksumURL = server.URL + "/SHA256SUMS"
+			},
+			wantErr: true,
+		},
+		{
+			name: "signature outside of a release",
+			config: func(c *downloader.Config) {
+				c.SignatureURL = server.URL + "/wappalyzer-fingerprints.zip.minisig"
+				c.PublicKey = newSigner(t, 1).minisignPublicKey()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := releaseConfig(t, server)
+			tt.config(config)
+			config.Version = "v1"
+
+			got, _, _, err := downloader.GetFingerprints(config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetFingerprints() = %v, want an error", got)
+				}
+				if releases, _ := downloader.ListReleases(config); len(releases) != 0 {
+					t.Errorf("ListReleases() = %+v, want none", releases)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetFingerprints() error = %v", err)
+			}
+			if _, ok := got["Tech-v1"]; !ok {
+				t.Errorf("GetFingerprints() technologies = %v, want Tech-v1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// MergeMode defines how a source combines a technology with the definition
+// of an earlier source
+type MergeMode int
+
+const (
+	// MergeReplace replaces earlier definitions of a technology as a whole
+	MergeReplace MergeMode = iota
+
+	// MergeAppend adds the patterns, categories and implied technologies of
+	// the definition to earlier ones, its other fields taking precedence
+	MergeAppend
+)
+
+// Source is a location fingerprints are loaded from. Exactly one of URL, Dir
+// and File must be set.
+type Source struct {
+	// Name identifies the source in provenance, it defaults to its location
+	Name string
+
+	// URL is a fingerprints zip archive, downloaded and cached like ReleaseURL
+	URL string
+
+	// Dir is a local directory holding technologies.json, and optionally
+	// categories.json and groups.json, or the upstream split layout
+	Dir string
+
+	// File is a local technologies JSON file, or a zip or tar archive of
+	// the upstream split layout
+	File string
+
+	// Mode defines how technologies also defined by earlier sources are combined
+	Mode MergeMode
+
+	// SHA256, ChecksumURL and SignatureURL verify the archive of a URL source
+	// like the Config fields of the same name verify ReleaseURL
+	SHA256       string
+	ChecksumURL  string
+	SignatureURL string
+
+	// PublicKey checks the signature of a URL source, it defaults to the
+	// configured one
+	PublicKey string
+}
+
+// ErrUnverifiedSource is returned for a URL source without verification
+// settings when the configuration verifies downloads
+var ErrUnverifiedSource = errors.New("unverified fingerprints source")
+
+// name returns the name of the source used in provenance
+func (s Source) name() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.URL != "":
+		return s.URL
+	case s.Dir != "":
+		return s.Dir
+	default:
+		return s.File
+	}
+}
+
+// LayeredFingerprints is the result of merging several sources
+type LayeredFingerprints struct {
+	Technologies map[string]json.RawMessage
+	Categories   map[string]json.RawMessage
+	Groups       map[string]json.RawMessage
+
+	// Provenance lists, per technology, the names of the sources its merged
+	// definition comes from, lowest precedence first
+	Provenance map[string][]string
+
+	// Info describes the oldest of the downloaded sources, and is stale if
+	// any of them is. Local sources don't affect it.
+	Info DataInfo
+}
+
+// listFields are the technology fields holding lists, possibly written as a
+// single string, which MergeAppend combines instead of replacing
+var listFields = map[string]bool{
+	"cats": true, "html": true, "scripts": true, "scriptSrc": true, "url": true,
+	"text": true, "css": true, "xhr": true, "robots": true, "implies": true,
+	"excludes": true, "requires": true, "requiresCategory": true,
+}
+
+// GetLayeredFingerprints loads the configured sources in order and merges
+// them, later sources taking precedence. Without Sources, ReleaseURL is the
+// only source.
+func GetLayeredFingerprints(ctx context.Context, config *Config) (*LayeredFingerprints, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	sources := config.Sources
+	if len(sources) == 0 {
+		sources = []Source{{URL: config.ReleaseURL}}
+	}
+
+	result := &LayeredFingerprints{
+		Technologies: make(map[string]json.RawMessage),
+		Categories:   make(map[string]json.RawMessage),
+		Groups:       make(map[string]json.RawMessage),
+		Provenance:   make(map[string][]string),
+	}
+
+	for _, source := range sources {
+		technologies, categories, groups, info, err := loadSource(ctx, config, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load source %s: %w", source.name(), err)
+		}
+		if info != nil {
+			result.Info.merge(info)
+		}
+
+		for name, technology := range technologies {
+			existing, ok := result.Technologies[name]
+			if !ok || source.Mode == MergeReplace {
+				result.Technologies[name] = technology
+				result.Provenance[name] = []string{source.name()}
+				continue
+			}
+
+			merged, err := mergeTechnology(existing, technology)
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge %s from source %s: %v", name, source.name(), err)
+			}
+			result.Technologies[name] = merged
+			result.Provenance[name] = append(result.Provenance[name], source.name())
+		}
+
+		// Categories and groups are replaced per ID
+		for id, category := range categories {
+			result.Categories[id] = category
+		}
+		for id, group := range groups {
+			result.Groups[id] = group
+		}
+	}
+
+	return result, nil
+}
+
+// loadSource loads the technologies, categories and groups of a source. The
+// data info is only set for downloaded sources.
+func loadSource(ctx context.Context, config *Config, source Source) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, *DataInfo, error) {
+	switch {
+	case source.URL != "" && source.Dir == "" && source.File == "":
+		sourceConfig, err := sourceConfig(config, source)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return GetFingerprintsWithInfo(ctx, sourceConfig)
+	case source.Dir != "" && source.URL == "" && source.File == "":
+		technologies, categories, groups, err := loadSourceDir(source.Dir)
+		return technologies, categories, groups, nil, err
+	case source.File != "" && source.URL == "" && source.Dir == "":
+		if isArchivePath(source.File) {
+			technologies, categories, groups, err := LoadSplitFingerprints(source.File)
+			return technologies, categories, groups, nil, err
+		}
+		technologies, err := LoadTechnologiesFile(source.File)
+		return technologies, nil, nil, nil, err
+	default:
+		return nil, nil, nil, nil, fmt.Errorf("exactly one of URL, Dir and File must be set")
+	}
+}
+
+// sourceConfig returns the downloader configuration for a URL source. The
+// release URL keeps the cache directory, verification settings and pinned
+// version, unless the source has its own verification settings. Other URLs
+// are cached in their own directory, not pinned, and verified with the
+// settings of the source only: they must have some if the configuration
+// verifies downloads.
+func sourceConfig(config *Config, source Source) (*Config, error) {
+	verified := source.SHA256 != "" || source.ChecksumURL != "" || source.SignatureURL != ""
+	if source.URL == config.ReleaseURL && !verified {
+		return config, nil
+	}
+	if !verified && verificationEnabled(config) {
+		return nil, fmt.Errorf("%w: %s has no SHA256, ChecksumURL or SignatureURL", ErrUnverifiedSource, source.URL)
+	}
+
+	sourceConfig := *config
+	sourceConfig.SHA256 = source.SHA256
+	sourceConfig.ChecksumURL = source.ChecksumURL
+	sourceConfig.SignatureURL = source.SignatureURL
+	if source.PublicKey != "" {
+		sourceConfig.PublicKey = source.PublicKey
+	}
+	sourceConfig.Sources = nil
+	if source.URL == config.ReleaseURL {
+		return &sourceConfig, nil
+	}
+
+	sum := sha256.Sum256([]byte(source.URL))
+	sourceConfig.ReleaseURL = source.URL
+	sourceConfig.CacheDir = filepath.Join(config.CacheDir, "sources", hex.EncodeToString(sum[:8]))
+	sourceConfig.Version = ""
+	return &sourceConfig, nil
+}
+
+// loadSourceDir loads a local fingerprints directory, where only
+// technologies.json is required. Directories without one are loaded as a
+// split layout, see LoadSplitFingerprints.
+func loadSourceDir(dir string) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	if !fileExists(filepath.Join(dir, "technologies.json")) {
+		return LoadSplitFingerprints(dir)
+	}
+
+	technologies, err := LoadTechnologiesFile(filepath.Join(dir, "technologies.json"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load technologies data: %v", err)
+	}
+
+	var categories, groups map[string]json.RawMessage
+	if path := filepath.Join(dir, "categories.json"); fileExists(path) {
+		if categories, err = loadJSONFile(path); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load categories data: %v", err)
+		}
+	}
+	if path := filepath.Join(dir, "groups.json"); fileExists(path) {
+		if groups, err = loadJSONFile(path); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load groups data: %v", err)
+		}
+	}
+
+	return technologies, categories, groups, nil
+}
+
+// mergeTechnology merges the overlay definition of a technology into the
+// base one: list fields are combined, object fields are merged per key and
+// any other field of the overlay replaces the base one
+func mergeTechnology(base, overlay json.RawMessage) (json.RawMessage, error) {
+	baseFields, err := decodeObject(base)
+	if err != nil {
+		return nil, err
+	}
+	overlayFields, err := decodeObject(overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range overlayFields {
+		existing, ok := baseFields[key]
+		switch {
+		case !ok:
+			baseFields[key] = value
+		case listFields[key]:
+			baseFields[key] = appendUnique(toList(existing), toList(value))
+		default:
+			existingObject, isObject := existing.(map[string]interface{})
+			valueObject, valueIsObject := value.(map[string]interface{})
+			if !isObject || !valueIsObject {
+				baseFields[key] = value
+				continue
+			}
+			for k, v := range valueObject {
+				existingObject[k] = v
+			}
+		}
+	}
+
+	return json.Marshal(baseFields)
+}
+
+// decodeObject decodes a JSON object, keeping numbers as written
+func decodeObject(data json.RawMessage) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	return fields, nil
+}
+
+// toList returns a list field as a list, whether it was written as one or not
+func toList(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{value}
+}
+
+// appendUnique appends the values of b missing from a
+func appendUnique(a, b []interface{}) []interface{} {
+	seen := make(map[string]struct{}, len(a))
+	for _, value := range a {
+		seen[fmt.Sprint(value)] = struct{}{}
+	}
+	for _, value := range b {
+		if _, ok := seen[fmt.Sprint(value)]; !ok {
+			seen[fmt.Sprint(value)] = struct{}{}
+			a = append(a, value)
+		}
+	}
+	return a
+}
@@ -0,0 +1,104 @@
+package downloader_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+func TestSourceVerification(t *testing.T) {
+	const (
+		releasePath = "/wappalyzer-fingerprints.zip"
+		sourcePath  = "/internal.zip"
+	)
+
+	release := buildArchive(t, validEntries()...)
+	entries := validEntries()
+	entries[0].data = []byte(`{"Internal":{"cats":[1],"html":"internal"}}`)
+	internal := buildArchive(t, entries...)
+	key := newSigner(t, 1)
+
+	tests := []struct {
+		name    string
+		config  func(c *downloader.Config)
+		source  func(url string) downloader.Source
+		wantErr error
+	}{
+		{
+			name:   "unverified configuration",
+			source: func(url string) downloader.Source { return downloader.Source{URL: url + sourcePath} },
+		},
+		{
+			name:    "unverified source",
+			config:  func(c *downloader.Config) { c.SHA256 = sha256Hex(release) },
+			source:  func(url string) downloader.Source { return downloader.Source{URL: url + sourcePath} },
+			wantErr: downloader.ErrUnverifiedSource,
+		},
+		{
+			name:   "source digest",
+			config: func(c *downloader.Config) { c.SHA256 = sha256Hex(release) },
+			source: func(url string) downloader.Source {
+				return downloader.Source{URL: url + sourcePath, SHA256: sha256Hex(internal)}
+			},
+		},
+		{
+			name:   "source digest mismatch",
+			config: func(c *downloader.Config) { c.SHA256 = sha256Hex(release) },
+			source: func(url string) downloader.Source {
+				return downloader.Source{URL: url + sourcePath, SHA256: sha256Hex(release)}
+			},
+			wantErr: downloader.ErrChecksumMismatch,
+		},
+		{
+			name:   "source signature with the configured key",
+			config: func(c *downloader.Config) { c.PublicKey = key.minisignPublicKey() },
+			source: func(url string) downloader.Source {
+				return downloader.Source{URL: url + sourcePath, SignatureURL: url + sourcePath + ".minisig"}
+			},
+		},
+		{
+			name:   "source signature with another key",
+			config: func(c *downloader.Config) { c.PublicKey = key.minisignPublicKey() },
+			source: func(url string) downloader.Source {
+				return downloader.Source{URL: url + sourcePath, SignatureURL: url + sourcePath + ".minisig", PublicKey: newSigner(t, 2).minisignPublicKey()}
+			},
+			wantErr: downloader.ErrInvalidSignature,
+		},
+		{
+			name:   "release URL source with the configured digest",
+			config: func(c *downloader.Config) { c.SHA256 = sha256Hex(release) },
+			source: func(url string) downloader.Source { return downloader.Source{URL: url + releasePath} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveFiles(t, map[string][]byte{
+				releasePath:             release,
+				sourcePath:              internal,
+				sourcePath + ".minisig": key.minisign(internal, "ED", key.keyID, "timestamp:1700000000"),
+			})
+			config := newConfig(t, server.Server)
+			if tt.config != nil {
+				tt.config(config)
+			}
+			config.Sources = []downloader.Source{tt.source(server.URL)}
+
+			layered, err := downloader.GetLayeredFingerprints(context.Background(), config)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetLayeredFingerprints() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetLayeredFingerprints() error = %v", err)
+			}
+			if len(layered.Technologies) != 1 {
+				t.Errorf("GetLayeredFingerprints() technologies = %v", layered.Technologies)
+			}
+		})
+	}
+}
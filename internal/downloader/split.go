@@ -0,0 +1,339 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrDuplicateTechnology is returned when a technology is defined more than
+// once in a split fingerprints layout
+var ErrDuplicateTechnology = errors.New("duplicate technology")
+
+// LoadSplitFingerprints loads fingerprints in the upstream Wappalyzer layout,
+// where technologies are sharded into technologies/a.json ... z.json and
+// _.json next to categories.json and groups.json. Path is a directory, such
+// as a repository checkout, or a zip or tar (optionally gzipped) archive of one.
+// A single technologies.json is accepted in place of the shards.
+func LoadSplitFingerprints(path string) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open fingerprints: %v", err)
+	}
+
+	var files map[string][]byte
+	switch name := strings.ToLower(path); {
+	case info.IsDir():
+		files, err = collectDirFiles(path)
+	case strings.HasSuffix(name, ".zip"):
+		files, err = collectZipFiles(path)
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		files, err = collectTarFiles(path)
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported fingerprints path %s: not a directory, zip or tar archive", path)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read fingerprints: %v", err)
+	}
+
+	return assembleSplitFingerprints(files)
+}
+
+// isArchivePath reports whether a path names an archive LoadSplitFingerprints reads
+func isArchivePath(path string) bool {
+	name := strings.ToLower(path)
+	for _, suffix := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFingerprintFile reports whether a slash separated path may be part of a
+// fingerprints layout, so only those files are read
+func isFingerprintFile(name string) bool {
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if skipDir(dir) {
+			return false
+		}
+	}
+
+	switch path.Base(name) {
+	case "technologies.json", "categories.json", "groups.json":
+		return true
+	}
+	return path.Ext(name) == ".json" && path.Base(path.Dir(name)) == "technologies"
+}
+
+// skipDir reports whether a directory is skipped when looking for
+// fingerprints: dependencies and VCS data never hold any
+func skipDir(name string) bool {
+	return name == "node_modules" || (strings.HasPrefix(name, ".") && name != "." && name != "..")
+}
+
+// collectDirFiles reads the fingerprint files below a directory
+func collectDirFiles(root string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !isFingerprintFile(rel) {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = data
+		return nil
+	})
+
+	return files, err
+}
+
+// collectZipFiles reads the fingerprint files of a zip archive
+func collectZipFiles(archive string) (map[string][]byte, error) {
+	zipReader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer zipReader.Close()
+
+	files := make(map[string][]byte)
+	var total int64
+	for _, file := range zipReader.File {
+		if !file.Mode().IsRegular() || !isFingerprintFile(file.Name) {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readLimited(rc, file.Name, &total)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[file.Name] = data
+	}
+
+	return files, nil
+}
+
+// collectTarFiles reads the fingerprint files of a tar archive, gzipped or not
+func collectTarFiles(archive string) (map[string][]byte, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if name := strings.ToLower(archive); strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	files := make(map[string][]byte)
+	var total int64
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		if header.Typeflag != tar.TypeReg || !isFingerprintFile(name) {
+			continue
+		}
+
+		data, err := readLimited(tarReader, name, &total)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = data
+	}
+}
+
+// readLimited reads an archive entry within the default extraction limits,
+// adding its size to total
+func readLimited(r io.Reader, name string, total *int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, DefaultMaxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > DefaultMaxEntrySize {
+		return nil, fmt.Errorf("%w: %s", ErrEntryTooLarge, name)
+	}
+
+	*total += int64(len(data))
+	if *total > DefaultMaxExtractedSize {
+		return nil, ErrExtractedTooLarge
+	}
+	return data, nil
+}
+
+// assembleSplitFingerprints locates the fingerprints layout among the
+// collected files and merges its technology shards
+func assembleSplitFingerprints(files map[string][]byte) (map[string]json.RawMessage, map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	// Group the shards by the directory holding the technologies directory
+	shards := make(map[string][]string)
+	for name := range files {
+		if path.Base(path.Dir(name)) == "technologies" {
+			root := path.Dir(path.Dir(name))
+			shards[root] = append(shards[root], name)
+		}
+	}
+
+	var root string
+	var technologies map[string]json.RawMessage
+	switch len(shards) {
+	case 0:
+		// Fall back to a single technologies file
+		root = findRoot(files, "technologies.json")
+		if root == "" {
+			return nil, nil, nil, fmt.Errorf("no technologies found")
+		}
+		var err error
+		if technologies, err = ParseTechnologies(files[path.Join(root, "technologies.json")]); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load technologies data: %v", err)
+		}
+	case 1:
+		for shardRoot, names := range shards {
+			var err error
+			if technologies, err = mergeShards(files, names); err != nil {
+				return nil, nil, nil, err
+			}
+			root = shardRoot
+		}
+	default:
+		roots := make([]string, 0, len(shards))
+		for root := range shards {
+			roots = append(roots, path.Join(root, "technologies"))
+		}
+		sort.Strings(roots)
+		return nil, nil, nil, fmt.Errorf("ambiguous fingerprints layout, technologies found in %s", strings.Join(roots, ", "))
+	}
+
+	// Categories and groups sit next to the technologies
+	var categories, groups map[string]json.RawMessage
+	if data, ok := files[path.Join(root, "categories.json")]; ok {
+		if err := json.Unmarshal(data, &categories); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load categories data: %v", err)
+		}
+	}
+	if data, ok := files[path.Join(root, "groups.json")]; ok {
+		if err := json.Unmarshal(data, &groups); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load groups data: %v", err)
+		}
+	}
+
+	return technologies, categories, groups, nil
+}
+
+// findRoot returns the shallowest directory holding the named file, "." for
+// the top level, or an empty string if there is none
+func findRoot(files map[string][]byte, name string) string {
+	root := ""
+	for file := range files {
+		if path.Base(file) != name {
+			continue
+		}
+		dir := path.Dir(file)
+		if root == "" || strings.Count(dir, "/") < strings.Count(root, "/") {
+			root = dir
+		}
+	}
+	return root
+}
+
+// mergeShards merges technology shards, rejecting technologies defined twice
+func mergeShards(files map[string][]byte, shards []string) (map[string]json.RawMessage, error) {
+	sort.Strings(shards)
+
+	technologies := make(map[string]json.RawMessage)
+	definedIn := make(map[string]string)
+	for _, shard := range shards {
+		err := decodeShard(files[shard], func(name string, technology json.RawMessage) error {
+			if previous, ok := definedIn[name]; ok {
+				return fmt.Errorf("%w: %s in %s and %s", ErrDuplicateTechnology, name, previous, shard)
+			}
+			definedIn[name] = shard
+			technologies[name] = technology
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, ErrDuplicateTechnology) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to load %s: %v", shard, err)
+		}
+	}
+
+	return technologies, nil
+}
+
+// decodeShard decodes a technologies object key by key, so keys repeated
+// within the shard are seen rather than silently overwritten
+func decodeShard(data []byte, add func(name string, technology json.RawMessage) error) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("technologies shard is not a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := token.(string)
+
+		var technology json.RawMessage
+		if err := decoder.Decode(&technology); err != nil {
+			return fmt.Errorf("invalid definition of %s: %v", name, err)
+		}
+		if err := add(name, technology); err != nil {
+			return err
+		}
+	}
+
+	_, err := decoder.Token()
+	return err
+}
@@ -0,0 +1,247 @@
+package downloader_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// splitFixture is a repository checkout in the upstream split layout
+const splitFixture = "testdata/split/repo"
+
+// copyTree copies a directory, adding the given files, and returns the copy
+func copyTree(t *testing.T, src string, extra map[string]string) string {
+	t.Helper()
+
+	dst := t.TempDir()
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return writeFixtureFile(filepath.Join(dst, rel), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range extra {
+		if err := writeFixtureFile(filepath.Join(dst, filepath.FromSlash(name)), []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dst
+}
+
+// writeFixtureFile writes a file, creating its directory
+func writeFixtureFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// archiveTree archives a directory under a top level directory, as GitHub
+// source archives are, in the format named by the file extension
+func archiveTree(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	archive := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var add func(name string, data []byte) error
+	var closers []io.Closer
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		zw := zip.NewWriter(f)
+		add = func(name string, data []byte) error {
+			w, err := zw.Create(name)
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			return err
+		}
+		closers = append(closers, zw)
+	default:
+		var w io.Writer = f
+		if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+			gz := gzip.NewWriter(f)
+			w = gz
+			defer gz.Close()
+		}
+		tw := tar.NewWriter(w)
+		add = func(name string, data []byte) error {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+				return err
+			}
+			_, err := tw.Write(data)
+			return err
+		}
+		closers = append(closers, tw)
+	}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return add("fingerprints-main/"+filepath.ToSlash(rel), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return archive
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestLoadSplitFingerprints(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+	}{
+		{"directory", func(t *testing.T) string { return splitFixture }},
+		{"zip archive", func(t *testing.T) string { return archiveTree(t, splitFixture, "fingerprints.zip") }},
+		{"tar archive", func(t *testing.T) string { return archiveTree(t, splitFixture, "fingerprints.tar") }},
+		{"gzipped tar archive", func(t *testing.T) string { return archiveTree(t, splitFixture, "fingerprints.tar.gz") }},
+		{"tgz archive", func(t *testing.T) string { return archiveTree(t, splitFixture, "fingerprints.tgz") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			technologies, categories, groups, err := downloader.LoadSplitFingerprints(tt.path(t))
+			if err != nil {
+				t.Fatalf("LoadSplitFingerprints() error = %v", err)
+			}
+
+			// Shards of every letter and _.json are merged, dependencies skipped
+			if got, want := sortedKeys(technologies), []string{"1C-Bitrix", "AngularJS", "Apache HTTP Server", "Nginx"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("technologies = %v, want %v", got, want)
+			}
+			if got, want := sortedKeys(categories), []string{"1", "12", "22"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("categories = %v, want %v", got, want)
+			}
+			if got, want := sortedKeys(groups), []string{"3", "7", "9"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("groups = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadSplitFingerprintsErrors(t *testing.T) {
+	duplicate := map[string]string{"src/technologies/z.json": `{"Nginx":{"cats":[22],"html":"nginx"}}`}
+
+	tests := []struct {
+		name    string
+		path    func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name:    "technology in two shards",
+			path:    func(t *testing.T) string { return copyTree(t, splitFixture, duplicate) },
+			wantErr: downloader.ErrDuplicateTechnology,
+		},
+		{
+			name: "technology in two shards of a zip archive",
+			path: func(t *testing.T) string {
+				return archiveTree(t, copyTree(t, splitFixture, duplicate), "fingerprints.zip")
+			},
+			wantErr: downloader.ErrDuplicateTechnology,
+		},
+		{
+			name: "technology twice in a shard",
+			path: func(t *testing.T) string {
+				return copyTree(t, splitFixture, map[string]string{
+					"src/technologies/z.json": `{"Zope":{"cats":[1]},"Zope":{"cats":[22]}}`,
+				})
+			},
+			wantErr: downloader.ErrDuplicateTechnology,
+		},
+		{
+			name: "ambiguous layout",
+			path: func(t *testing.T) string {
+				return copyTree(t, splitFixture, map[string]string{
+					"other/technologies/a.json": `{"Other":{"cats":[1]}}`,
+				})
+			},
+		},
+		{
+			name: "invalid shard",
+			path: func(t *testing.T) string {
+				return copyTree(t, splitFixture, map[string]string{"src/technologies/z.json": `["Zope"]`})
+			},
+		},
+		{
+			name: "no technologies",
+			path: func(t *testing.T) string { return t.TempDir() },
+		},
+		{
+			name: "unsupported file",
+			path: func(t *testing.T) string { return filepath.Join(splitFixture, "README.md") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := downloader.LoadSplitFingerprints(tt.path(t))
+			if err == nil {
+				t.Fatal("LoadSplitFingerprints() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoadSplitFingerprints() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSplitFingerprintsSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, entry := range validEntries() {
+		if err := writeFixtureFile(filepath.Join(dir, "fingerprints", entry.name), entry.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	technologies, categories, groups, err := downloader.LoadSplitFingerprints(dir)
+	if err != nil {
+		t.Fatalf("LoadSplitFingerprints() error = %v", err)
+	}
+	if len(technologies) != 1 || len(categories) != 1 || len(groups) != 1 {
+		t.Errorf("LoadSplitFingerprints() = %v, %v, %v", technologies, categories, groups)
+	}
+}
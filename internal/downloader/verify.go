@@ -0,0 +1,246 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	// ErrChecksumMismatch is returned when a download doesn't match its SHA-256 digest
+	ErrChecksumMismatch = errors.New("fingerprints checksum mismatch")
+
+	// ErrInvalidSignature is returned when a download doesn't match its signature
+	ErrInvalidSignature = errors.New("invalid fingerprints signature")
+)
+
+// maxVerificationFileSize bounds the size of checksum and signature files
+const maxVerificationFileSize = 64 * 1024
+
+// verificationEnabled reports whether the config asks for any verification
+func verificationEnabled(config *Config) bool {
+	return config.SHA256 != "" || config.ChecksumURL != "" || config.SignatureURL != ""
+}
+
+// verifyDownload checks the downloaded archive against the configured digest
+// and signature. Verification fails closed: any error rejects the download.
+func verifyDownload(ctx context.Context, config *Config, data []byte) error {
+	if config.SHA256 != "" || config.ChecksumURL != "" {
+		expected := config.SHA256
+		if expected == "" {
+			checksums, err := fetchVerificationFile(ctx, config, config.ChecksumURL)
+			if err != nil {
+				return fmt.Errorf("failed to get checksums: %v", err)
+			}
+			if expected, err = findChecksum(checksums, archiveName(config.ReleaseURL)); err != nil {
+				return err
+			}
+		}
+
+		if err := verifyChecksum(data, expected); err != nil {
+			return err
+		}
+	}
+
+	if config.SignatureURL != "" {
+		if config.PublicKey == "" {
+			return fmt.Errorf("%w: no public key configured", ErrInvalidSignature)
+		}
+
+		signature, err := fetchVerificationFile(ctx, config, config.SignatureURL)
+		if err != nil {
+			return fmt.Errorf("failed to get signature: %v", err)
+		}
+		if err := VerifySignature(data, signature, config.PublicKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchVerificationFile downloads a checksums or signature file
+func fetchVerificationFile(ctx context.Context, config *Config, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxVerificationFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxVerificationFileSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", maxVerificationFileSize)
+	}
+	return data, nil
+}
+
+// archiveName returns the file name of the archive at releaseURL, ignoring
+// any query string or fragment
+func archiveName(releaseURL string) string {
+	if u, err := url.Parse(releaseURL); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(releaseURL)
+}
+
+// findChecksum returns the digest of the named file from a checksums file in
+// the sha256sum format. A file holding a single digest without a file name
+// applies to any name; a digest listed for another file never does.
+func findChecksum(checksums []byte, name string) (string, error) {
+	var lines, bare []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		lines = append(lines, fields[0])
+		if len(fields) == 1 {
+			bare = append(bare, fields[0])
+			continue
+		}
+		if strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+
+	if len(lines) == 1 && len(bare) == 1 {
+		return bare[0], nil
+	}
+	return "", fmt.Errorf("%w: no checksum listed for %s", ErrChecksumMismatch, name)
+}
+
+// verifyChecksum compares the SHA-256 digest of data with a hex encoded digest
+func verifyChecksum(data []byte, expected string) error {
+	want, err := hex.DecodeString(strings.TrimSpace(expected))
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("%w: invalid SHA-256 digest %q", ErrChecksumMismatch, expected)
+	}
+
+	got := sha256.Sum256(data)
+	if !bytes.Equal(got[:], want) {
+		return fmt.Errorf("%w: got %x, want %x", ErrChecksumMismatch, got, want)
+	}
+	return nil
+}
+
+// VerifySignature checks a detached ed25519 signature of data. The signature
+// is either a minisign signature file or a raw signature, base64 encoded.
+// The public key is either a minisign public key, optionally with its
+// comment line, or a raw ed25519 key, base64 encoded.
+func VerifySignature(data, signature []byte, publicKey string) error {
+	key, keyID, err := parsePublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("untrusted comment:")) {
+		return verifyMinisign(data, signature, key, keyID)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("%w: signature does not match", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// parsePublicKey decodes a minisign or raw ed25519 public key. The key ID is
+// only set for minisign keys.
+func parsePublicKey(publicKey string) (ed25519.PublicKey, []byte, error) {
+	// Skip the comment line of a minisign public key file
+	lines := strings.Split(strings.TrimSpace(publicKey), "\n")
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed public key: %v", err)
+	}
+
+	switch {
+	case len(decoded) == ed25519.PublicKeySize:
+		return ed25519.PublicKey(decoded), nil, nil
+	case len(decoded) == 2+8+ed25519.PublicKeySize && string(decoded[:2]) == "Ed":
+		return ed25519.PublicKey(decoded[10:]), decoded[2:10], nil
+	default:
+		return nil, nil, errors.New("unsupported public key format")
+	}
+}
+
+// verifyMinisign checks a minisign signature file: the signature of the data,
+// legacy or prehashed with BLAKE2b, and the global signature covering the
+// trusted comment
+func verifyMinisign(data, signature []byte, key ed25519.PublicKey, keyID []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) < 4 {
+		return fmt.Errorf("%w: truncated minisign signature", ErrInvalidSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed minisign signature", ErrInvalidSignature)
+	}
+	if keyID != nil && !bytes.Equal(sig[2:10], keyID) {
+		return fmt.Errorf("%w: signed with a different key", ErrInvalidSignature)
+	}
+
+	message := data
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	default:
+		return fmt.Errorf("%w: unsupported minisign algorithm", ErrInvalidSignature)
+	}
+	if !ed25519.Verify(key, message, sig[10:]) {
+		return fmt.Errorf("%w: signature does not match", ErrInvalidSignature)
+	}
+
+	// The global signature covers the signature and the trusted comment
+	const trustedPrefix = "trusted comment: "
+	trustedLine := strings.TrimRight(lines[2], "\r")
+	if !strings.HasPrefix(trustedLine, trustedPrefix) {
+		return fmt.Errorf("%w: missing trusted comment", ErrInvalidSignature)
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed global signature", ErrInvalidSignature)
+	}
+	signed := append(append([]byte{}, sig[10:]...), strings.TrimPrefix(trustedLine, trustedPrefix)...)
+	if !ed25519.Verify(key, signed, globalSig) {
+		return fmt.Errorf("%w: trusted comment signature does not match", ErrInvalidSignature)
+	}
+
+	return nil
+}
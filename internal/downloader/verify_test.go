@@ -0,0 +1,335 @@
+package downloader_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// fileServer serves files by path, which can be replaced between requests
+type fileServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// serveFiles starts a server for the given files
+func serveFiles(t *testing.T, files map[string][]byte) *fileServer {
+	t.Helper()
+
+	s := &fileServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		data, ok := s.files[r.URL.Path]
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// set replaces the files served
+func (s *fileServer) set(files map[string][]byte) {
+	s.mu.Lock()
+	s.files = files
+	s.mu.Unlock()
+}
+
+// signer signs archives with a generated ed25519 key
+type signer struct {
+	public  ed25519.PublicKey
+	private ed25519.PrivateKey
+	keyID   []byte
+}
+
+// newSigner generates a reproducible key
+func newSigner(t *testing.T, seed int64) *signer {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.New(rand.NewSource(seed)))
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	keyID := make([]byte, 8)
+	rand.New(rand.NewSource(seed)).Read(keyID)
+	return &signer{public: public, private: private, keyID: keyID}
+}
+
+// minisignPublicKey returns the public key in the minisign format
+func (s *signer) minisignPublicKey() string {
+	key := append(append([]byte("Ed"), s.keyID...), s.public...)
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(key) + "\n"
+}
+
+// rawPublicKey returns the public key as raw base64
+func (s *signer) rawPublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.public)
+}
+
+// rawSignature returns a raw, base64 encoded signature of data
+func (s *signer) rawSignature(data []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(s.private, data)))
+}
+
+// minisign returns a minisign signature file for data, legacy ("Ed") or
+// prehashed ("ED"), signed under keyID
+func (s *signer) minisign(data []byte, algorithm string, keyID []byte, trustedComment string) []byte {
+	message := data
+	if algorithm == "ED" {
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	}
+	sig := ed25519.Sign(s.private, message)
+	globalSig := ed25519.Sign(s.private, append(append([]byte{}, sig...), trustedComment...))
+
+	encoded := append(append([]byte(algorithm), keyID...), sig...)
+	return []byte(fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(encoded), trustedComment, base64.StdEncoding.EncodeToString(globalSig)))
+}
+
+// sha256Hex returns the hex encoded SHA-256 digest of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerification(t *testing.T) {
+	const (
+		archivePath   = "/wappalyzer-fingerprints.zip"
+		checksumsPath = "/checksums.txt"
+		signaturePath = "/wappalyzer-fingerprints.zip.minisig"
+	)
+
+	key := newSigner(t, 1)
+	otherKey := newSigner(t, 2)
+
+	// The cache first holds the valid archive, then a download of the
+	// updated one is verified
+	previous := buildArchive(t, validEntries()...)
+	entries := validEntries()
+	entries[0].data = []byte(`{"Updated":{"cats":[1],"html":"updated"}}`)
+	updated := buildArchive(t, entries...)
+
+	tests := []struct {
+		name    string
+		files   map[string][]byte
+		config  func(c *downloader.Config, url string)
+		wantErr error
+	}{
+		{
+			name: "matching digest",
+			config: func(c *downloader.Config, url string) {
+				c.SHA256 = sha256Hex(updated)
+			},
+		},
+		{
+			name: "digest mismatch",
+			config: func(c *downloader.Config, url string) {
+				c.SHA256 = sha256Hex(previous)
+			},
+			wantErr: downloader.ErrChecksumMismatch,
+		},
+		{
+			name: "malformed digest",
+			config: func(c *downloader.Config, url string) {
+				c.SHA256 = "not a digest"
+			},
+			wantErr: downloader.ErrChecksumMismatch,
+		},
+		{
+			name: "checksum listed for the archive",
+			files: map[string][]byte{checksumsPath: []byte(
+				sha256Hex(previous) + "  other.zip\n" + sha256Hex(updated) + " *wappalyzer-fingerprints.zip\n")},
+			config: func(c *downloader.Config, url string) {
+				c.ChecksumURL = url + checksumsPath
+			},
+		},
+		{
+			name: "checksum listed for a signed archive URL",
+			files: map[string][]byte{checksumsPath: []byte(
+				sha256Hex(previous) + "  other.zip\n" + sha256Hex(updated) + "  wappalyzer-fingerprints.zip\n")},
+			config: func(c *downloader.Config, url string) {
+				c.ReleaseURL = url + archivePath + "?X-Amz-Signature=abc%2Fdef&expires=1#download"
+				c.ChecksumURL = url + checksumsPath
+			},
+		},
+		{
+			name:  "single digest without a file name",
+			files: map[string][]byte{checksumsPath: []byte(sha256Hex(updated) + "\n")},
+			config: func(c *downloader.Config, url string) {
+				c.ChecksumURL = url + checksumsPath
+			},
+		},
+		{
+			name:  "single digest listed for another file",
+			files: map[string][]byte{checksumsPath: []byte(sha256Hex(updated) + "  other.zip\n")},
+			config: func(c *downloader.Config, url string) {
+				c.ChecksumURL = url + checksumsPath
+			},
+			wantErr: downloader.ErrChecksumMismatch,
+		},
+		{
+			name:  "checksum file mismatch",
+			files: map[string][]byte{checksumsPath: []byte(sha256Hex(previous) + "  wappalyzer-fingerprints.zip\n")},
+			config: func(c *downloader.Config, url string) {
+				c.ChecksumURL = url + checksumsPath
+			},
+			wantErr: downloader.ErrChecksumMismatch,
+		},
+		{
+			name: "missing checksum file",
+			config: func(c *downloader.Config, url string) {
+				c.ChecksumURL = url + checksumsPath
+			},
+			wantErr: errVerificationFailed,
+		},
+		{
+			name:  "raw signature",
+			files: map[string][]byte{signaturePath: key.rawSignature(updated)},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+				c.PublicKey = key.rawPublicKey()
+			},
+		},
+		{
+			name:  "legacy minisign signature",
+			files: map[string][]byte{signaturePath: key.minisign(updated, "Ed", key.keyID, "timestamp:1700000000")},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+				c.PublicKey = key.minisignPublicKey()
+			},
+		},
+		{
+			name:  "prehashed minisign signature",
+			files: map[string][]byte{signaturePath: key.minisign(updated, "ED", key.keyID, "timestamp:1700000000")},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+				c.PublicKey = key.minisignPublicKey()
+			},
+		},
+		{
+			name:  "minisign signature of another archive",
+			files: map[string][]byte{signaturePath: key.minisign(previous, "ED", key.keyID, "timestamp:1700000000")},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+				c.PublicKey = key.minisignPublicKey()
+			},
+			wantErr: downloader.ErrInvalidSignature,
+		},
+		{
+			name:  "wrong key ID",
+			files: map[string][]byte{signaturePath: key.minisign(updated, "ED", otherKey.keyID, "timestamp:1700000000")},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+				c.PublicKey = key.minisignPublicKey()
+			},
+			wantErr: downloader.ErrInvalidSignature,
+		},
+		{
+			name:  "signed with another key",
+			files: map[string][]byte{signaturePath: otherKey.minisign(updated, "ED", key.keyID, "timestamp:1700000000")},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+				c.PublicKey = key.minisignPublicKey()
+			},
+			wantErr: downloader.ErrInvalidSignature,
+		},
+		{
+			name: "tampered trusted comment",
+			files: map[string][]byte{signaturePath: []byte(strings.Replace(
+				string(key.minisign(updated, "ED", key.keyID, "timestamp:1700000000")), "timestamp:1700000000", "timestamp:1800000000", 1))},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+				c.PublicKey = key.minisignPublicKey()
+			},
+			wantErr: downloader.ErrInvalidSignature,
+		},
+		{
+			name:  "no public key",
+			files: map[string][]byte{signaturePath: key.minisign(updated, "ED", key.keyID, "timestamp:1700000000")},
+			config: func(c *downloader.Config, url string) {
+				c.SignatureURL = url + signaturePath
+			},
+			wantErr: downloader.ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveFiles(t, map[string][]byte{archivePath: previous})
+			config := newConfig(t, server.Server)
+
+			if _, _, _, err := downloader.GetFingerprints(config); err != nil {
+				t.Fatalf("GetFingerprints() error = %v", err)
+			}
+			cached, err := downloader.CurrentCacheDir(config)
+			if err != nil {
+				t.Fatalf("CurrentCacheDir() error = %v", err)
+			}
+
+			files := map[string][]byte{archivePath: updated}
+			for path, data := range tt.files {
+				files[path] = data
+			}
+			server.set(files)
+			tt.config(config, server.URL)
+			config.ForceDownload = true
+
+			technologies, _, _, err := downloader.GetFingerprints(config)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("GetFingerprints() error = %v", err)
+				}
+				if _, ok := technologies["Updated"]; !ok {
+					t.Fatalf("GetFingerprints() technologies = %v, want Updated", technologies)
+				}
+				return
+			}
+
+			if tt.wantErr == errVerificationFailed {
+				if err == nil {
+					t.Fatal("GetFingerprints() error = nil, want an error")
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetFingerprints() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// The previous cache survives the rejected download
+			current, err := downloader.CurrentCacheDir(config)
+			if err != nil || current != cached {
+				t.Fatalf("CurrentCacheDir() = %q, %v, want %q", current, err, cached)
+			}
+			config.ForceDownload = false
+			config.Offline = true
+			technologies, _, _, err = downloader.GetFingerprints(config)
+			if err != nil {
+				t.Fatalf("GetFingerprints() error = %v", err)
+			}
+			if _, ok := technologies["Example"]; !ok {
+				t.Fatalf("GetFingerprints() technologies = %v, want Example", technologies)
+			}
+		})
+	}
+}
+
+// errVerificationFailed stands for any error in TestVerification, for
+// failures without a sentinel error
+var errVerificationFailed = errors.New("verification failed")
@@ -0,0 +1,477 @@
+// Package lint validates fingerprints: the schema of every technology, the
+// patterns the compiler would otherwise skip silently, and the references
+// between technologies and categories.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/vectors"
+)
+
+// Severity tells whether an issue breaks detection or is only suspicious
+type Severity string
+
+const (
+	// SeverityError marks issues that break or silently drop detection
+	SeverityError Severity = "error"
+	// SeverityWarning marks issues that are likely, but not certainly, mistakes
+	SeverityWarning Severity = "warning"
+)
+
+// Checks reported in issues
+const (
+	// CheckSchema reports missing, unknown or mistyped fields
+	CheckSchema = "schema"
+	// CheckInvalidPattern reports patterns that don't compile
+	CheckInvalidPattern = "invalid-pattern"
+	// CheckTranslatedPattern reports patterns RE2 rejects that are rewritten
+	// or left to the backtracking engine
+	CheckTranslatedPattern = "translated-pattern"
+	// CheckEmptyMatch reports patterns matching the empty string, so any page
+	CheckEmptyMatch = "empty-match"
+	// CheckUnknownCategory reports category IDs missing from the categories
+	CheckUnknownCategory = "unknown-category"
+	// CheckDanglingReference reports implies, excludes and requires targets
+	// that are not defined
+	CheckDanglingReference = "dangling-reference"
+	// CheckImplicationCycle reports technologies implying themselves
+	CheckImplicationCycle = "implication-cycle"
+)
+
+// Issue is a problem found in the fingerprints
+type Issue struct {
+	Technology string   `json:"technology"`
+	Field      string   `json:"field,omitempty"`
+	Pattern    string   `json:"pattern,omitempty"`
+	Check      string   `json:"check"`
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+}
+
+// String formats the issue on a single line
+func (i Issue) String() string {
+	location := i.Technology
+	if i.Field != "" {
+		location += "." + i.Field
+	}
+	if i.Pattern != "" {
+		return fmt.Sprintf("%s: %s [%s] %s: %q", i.Severity, location, i.Check, i.Message, i.Pattern)
+	}
+	return fmt.Sprintf("%s: %s [%s] %s", i.Severity, location, i.Check, i.Message)
+}
+
+// Report lists the issues found in a set of fingerprints
+type Report struct {
+	Technologies int     `json:"technologies"`
+	Errors       int     `json:"errors"`
+	Warnings     int     `json:"warnings"`
+	Issues       []Issue `json:"issues"`
+}
+
+// OK reports whether no errors were found, warnings aside
+func (r *Report) OK() bool {
+	return r.Errors == 0
+}
+
+// fieldKind is the expected type of a technology field
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindBool
+	kindStringList  // a string or a list of strings
+	kindPatternList // a pattern or a list of patterns
+	kindPatternMap  // an object of patterns
+	kindJSMap       // an object of patterns or other JSON values
+	kindMetaMap     // an object of patterns or lists of patterns
+	kindIntList     // a list of integers
+	kindIntOrList   // an integer or a list of integers
+	kindReferences  // a technology name or a list of them
+	kindTests       // a list of test vectors
+	kindAny
+)
+
+// fields are the known technology fields and their kind
+var fields = map[string]fieldKind{
+	"name":             kindString,
+	"description":      kindString,
+	"website":          kindString,
+	"icon":             kindString,
+	"cpe":              kindString,
+	"saas":             kindBool,
+	"oss":              kindBool,
+	"pricing":          kindStringList,
+	"cats":             kindIntList,
+	"cookies":          kindPatternMap,
+	"headers":          kindPatternMap,
+	"js":               kindJSMap,
+	"meta":             kindMetaMap,
+	"dns":              kindMetaMap,
+	"html":             kindPatternList,
+	"scripts":          kindPatternList,
+	"scriptSrc":        kindPatternList,
+	"url":              kindPatternList,
+	"text":             kindPatternList,
+	"css":              kindPatternList,
+	"robots":           kindPatternList,
+	"xhr":              kindPatternList,
+	"certIssuer":       kindPatternList,
+	"implies":          kindReferences,
+	"excludes":         kindReferences,
+	"requires":         kindReferences,
+	"requiresCategory": kindIntOrList,
+	"tests":            kindTests,
+	"dom":              kindAny,
+	"probe":            kindAny,
+}
+
+// requiredFields must be present in every technology
+var requiredFields = []string{"cats", "website"}
+
+// linter collects the issues of a lint run
+type linter struct {
+	technologies map[string]json.RawMessage
+	categories   map[string]json.RawMessage
+	implies      map[string][]string
+	issues       []Issue
+}
+
+// Lint checks technologies, keyed by name, against the fingerprint schema and
+// each other. Category IDs are only checked when categories are given.
+func Lint(technologies, categories map[string]json.RawMessage) *Report {
+	l := &linter{
+		technologies: technologies,
+		categories:   categories,
+		implies:      make(map[string][]string),
+	}
+
+	for name, data := range technologies {
+		l.lintTechnology(name, data)
+	}
+	l.lintCycles()
+
+	// Sort the issues so reports can be diffed
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		if a.Technology != b.Technology {
+			return a.Technology < b.Technology
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		if a.Check != b.Check {
+			return a.Check < b.Check
+		}
+		return a.Pattern < b.Pattern
+	})
+
+	report := &Report{Technologies: len(technologies), Issues: l.issues}
+	if report.Issues == nil {
+		report.Issues = []Issue{}
+	}
+	for _, issue := range report.Issues {
+		if issue.Severity == SeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+	return report
+}
+
+// report records an issue
+func (l *linter) report(severity Severity, check, technology, field, pattern, format string, args ...interface{}) {
+	l.issues = append(l.issues, Issue{
+		Technology: technology,
+		Field:      field,
+		Pattern:    pattern,
+		Check:      check,
+		Severity:   severity,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// lintTechnology checks the fields of a technology
+func (l *linter) lintTechnology(name string, data json.RawMessage) {
+	var technology map[string]json.RawMessage
+	if err := json.Unmarshal(data, &technology); err != nil || technology == nil {
+		l.report(SeverityError, CheckSchema, name, "", "", "technology is not a JSON object")
+		return
+	}
+
+	for _, field := range requiredFields {
+		if _, ok := technology[field]; !ok {
+			l.report(SeverityError, CheckSchema, name, field, "", "required field is missing")
+		}
+	}
+
+	for field, value := range technology {
+		kind, ok := fields[field]
+		if !ok {
+			l.report(SeverityWarning, CheckSchema, name, field, "", "unknown field")
+			continue
+		}
+		l.lintField(name, field, kind, value)
+	}
+}
+
+// lintField checks a technology field against its kind
+func (l *linter) lintField(name, field string, kind fieldKind, value json.RawMessage) {
+	switch kind {
+	case kindString:
+		var s string
+		if json.Unmarshal(value, &s) != nil {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected a string")
+		} else if field == "cpe" && !strings.HasPrefix(s, "cpe:") {
+			l.report(SeverityWarning, CheckSchema, name, field, "", "CPE %q doesn't start with cpe:", s)
+		}
+
+	case kindBool:
+		var b bool
+		if json.Unmarshal(value, &b) != nil {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected a boolean")
+		}
+
+	case kindStringList:
+		if _, ok := stringList(value); !ok {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected a string or a list of strings")
+		}
+
+	case kindPatternList:
+		patterns, ok := stringList(value)
+		if !ok {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected a pattern or a list of patterns")
+			return
+		}
+		for _, pattern := range patterns {
+			l.lintPattern(name, field, pattern, true)
+		}
+
+	case kindPatternMap:
+		var patterns map[string]string
+		if json.Unmarshal(value, &patterns) != nil {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected an object of patterns")
+			return
+		}
+		for key, pattern := range patterns {
+			l.lintPattern(name, field+"."+key, pattern, false)
+		}
+
+	case kindJSMap:
+		// The loader reads values other than patterns as it does, booleans
+		// and numbers as their text and others as the empty pattern
+		var entries map[string]json.RawMessage
+		if json.Unmarshal(value, &entries) != nil {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected an object of patterns")
+			return
+		}
+		for key, entry := range entries {
+			var pattern string
+			if json.Unmarshal(entry, &pattern) == nil {
+				l.lintPattern(name, field+"."+key, pattern, false)
+			}
+		}
+
+	case kindMetaMap:
+		var entries map[string]json.RawMessage
+		if json.Unmarshal(value, &entries) != nil {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected an object of patterns")
+			return
+		}
+		for key, entry := range entries {
+			patterns, ok := stringList(entry)
+			if !ok {
+				l.report(SeverityError, CheckSchema, name, field+"."+key, "", "expected a pattern or a list of patterns")
+				continue
+			}
+			for _, pattern := range patterns {
+				l.lintPattern(name, field+"."+key, pattern, false)
+			}
+		}
+
+	case kindIntList:
+		var ids []int
+		if json.Unmarshal(value, &ids) != nil {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected a list of category IDs")
+			return
+		}
+		if len(ids) == 0 {
+			l.report(SeverityWarning, CheckSchema, name, field, "", "no categories")
+		}
+		l.lintCategories(name, field, ids)
+
+	case kindIntOrList:
+		var ids []int
+		if json.Unmarshal(value, &ids) != nil {
+			var id int
+			if json.Unmarshal(value, &id) != nil {
+				l.report(SeverityError, CheckSchema, name, field, "", "expected a category ID or a list of them")
+				return
+			}
+			ids = []int{id}
+		}
+		l.lintCategories(name, field, ids)
+
+	case kindReferences:
+		references, ok := stringList(value)
+		if !ok {
+			l.report(SeverityError, CheckSchema, name, field, "", "expected a technology name or a list of them")
+			return
+		}
+		for _, reference := range references {
+			target := referenceName(reference)
+			if _, ok := l.technologies[target]; !ok {
+				l.report(SeverityError, CheckDanglingReference, name, field, "", "%s is not defined", target)
+				continue
+			}
+			if field == "implies" {
+				l.implies[name] = append(l.implies[name], target)
+			}
+		}
+
+	case kindTests:
+		if _, err := vectors.Decode(value); err != nil {
+			l.report(SeverityError, CheckSchema, name, field, "", "%v", err)
+		}
+	}
+}
+
+// lintPattern checks that a pattern compiles, and warns about patterns RE2
+// only evaluates translated and patterns matching the empty string where that
+// means matching any page. Patterns of
+// named headers, cookies and the like are often empty on purpose, to only
+// check for the presence of the name.
+func (l *linter) lintPattern(name, field, pattern string, matchesAnything bool) {
+	parsed, err := parser.ParsePattern(pattern)
+	if err != nil {
+		l.report(SeverityError, CheckInvalidPattern, name, field, pattern, "%v", err)
+		return
+	}
+	if parsed.Translation != "" {
+		l.report(SeverityWarning, CheckTranslatedPattern, name, field, pattern, "%s", parsed.Translation)
+	}
+	if !matchesAnything {
+		return
+	}
+
+	empty := parsed.Pattern == ""
+	if !empty && !parsed.IsLiteral {
+		if regex, err := parser.CompileRegex(parsed.Pattern); err == nil {
+			empty = regex.MatchString("")
+		}
+	}
+	if empty {
+		l.report(SeverityWarning, CheckEmptyMatch, name, field, pattern, "pattern matches the empty string, so any page")
+	}
+}
+
+// lintCategories checks that category IDs are defined
+func (l *linter) lintCategories(name, field string, ids []int) {
+	if l.categories == nil {
+		return
+	}
+	for _, id := range ids {
+		if _, ok := l.categories[strconv.Itoa(id)]; !ok {
+			l.report(SeverityError, CheckUnknownCategory, name, field, "", "category %d is not defined", id)
+		}
+	}
+}
+
+// lintCycles reports each cycle of the implies graph once, on the first
+// technology of the cycle in name order
+func (l *linter) lintCycles() {
+	names := make([]string, 0, len(l.implies))
+	for name, targets := range l.implies {
+		names = append(names, name)
+		sort.Strings(targets)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	seen := make(map[string]bool)
+	var stack []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+
+		for _, target := range l.implies[name] {
+			switch state[target] {
+			case unvisited:
+				visit(target)
+			case visiting:
+				// The stack from the target onwards is a cycle
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == target {
+						l.reportCycle(stack[i:], seen)
+						break
+					}
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+}
+
+// reportCycle reports a cycle unless it was reported already
+func (l *linter) reportCycle(cycle []string, seen map[string]bool) {
+	// Rotate the cycle to start with its first name, so it has one spelling
+	start := 0
+	for i, name := range cycle {
+		if name < cycle[start] {
+			start = i
+		}
+	}
+	rotated := append(append([]string{}, cycle[start:]...), cycle[:start]...)
+
+	key := strings.Join(rotated, "\x00")
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+
+	path := strings.Join(append(rotated, rotated[0]), " -> ")
+	l.report(SeverityError, CheckImplicationCycle, rotated[0], "implies", "", "implication cycle %s", path)
+}
+
+// stringList decodes a string or a list of strings
+func stringList(value json.RawMessage) ([]string, bool) {
+	var list []string
+	if json.Unmarshal(value, &list) == nil {
+		return list, true
+	}
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return []string{s}, true
+	}
+	return nil, false
+}
+
+// referenceName strips the directives, such as a confidence, from a reference
+// to a technology
+func referenceName(reference string) string {
+	if index := strings.Index(reference, "\\;"); index >= 0 {
+		reference = reference[:index]
+	}
+	return strings.TrimSpace(reference)
+}
@@ -0,0 +1,229 @@
+package lint_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/lint"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// categories are the categories the technologies of the tests may use
+var categories = map[string]json.RawMessage{
+	"1":  json.RawMessage(`{"name": "CMS", "priority": 1, "groups": [3]}`),
+	"22": json.RawMessage(`{"name": "Web servers", "priority": 8, "groups": [7]}`),
+}
+
+// found is the part of an issue the tests check, the message aside
+type found struct {
+	Technology string
+	Field      string
+	Pattern    string
+	Check      string
+	Severity   lint.Severity
+}
+
+// technologies decodes the technologies of a test, keyed by name
+func technologies(t *testing.T, data string) map[string]json.RawMessage {
+	t.Helper()
+	var technologies map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &technologies); err != nil {
+		t.Fatalf("invalid test technologies: %v", err)
+	}
+	return technologies
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name         string
+		technologies string
+		noCategories bool
+		want         []found
+	}{
+		{
+			name: "valid",
+			technologies: `{
+				"Nginx": {"cats": [22], "website": "https://nginx.org", "headers": {"Server": "nginx(?:/([\\d.]+))?\\;version:\\1"}, "cpe": "cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*"},
+				"PHP": {"cats": [1], "website": "https://php.net", "cookies": {"PHPSESSID": ""}},
+				"WordPress": {"cats": [1], "website": "https://wordpress.org", "html": "<link [^>]+/wp-content/", "implies": ["PHP\\;confidence:50"], "requiresCategory": 22}
+			}`,
+		},
+		{
+			name: "js values of any type",
+			technologies: `{
+				"Example": {"cats": [1], "website": "https://example.com",
+					"js": {"Example.version": "([\\d.]+)\\;version:\\1", "Example.enabled": true, "Example.build": 2, "Example.config": {"a": 1}, "Example.none": null}}
+			}`,
+		},
+		{
+			name: "uncompilable patterns",
+			technologies: `{
+				"Broken": {"cats": [1], "website": "https://example.com",
+					"html": ["<div class=\"ok\">", "foo(bar"], "scriptSrc": "[unclosed", "meta": {"generator": ["fine", "a{2,1}"]}}
+			}`,
+			want: []found{
+				{"Broken", "html", "foo(bar", lint.CheckInvalidPattern, lint.SeverityError},
+				{"Broken", "meta.generator", "a{2,1}", lint.CheckInvalidPattern, lint.SeverityError},
+				{"Broken", "scriptSrc", "[unclosed", lint.CheckInvalidPattern, lint.SeverityError},
+			},
+		},
+		{
+			name: "unknown categories",
+			technologies: `{
+				"Example": {"cats": [1, 999], "website": "https://example.com", "requiresCategory": [22, 998]}
+			}`,
+			want: []found{
+				{"Example", "cats", "", lint.CheckUnknownCategory, lint.SeverityError},
+				{"Example", "requiresCategory", "", lint.CheckUnknownCategory, lint.SeverityError},
+			},
+		},
+		{
+			name:         "categories not given",
+			technologies: `{"Example": {"cats": [999], "website": "https://example.com"}}`,
+			noCategories: true,
+		},
+		{
+			name: "dangling references",
+			technologies: `{
+				"Example": {"cats": [1], "website": "https://example.com",
+					"implies": ["Defined", "Missing\\;confidence:50"], "excludes": "Gone", "requires": ["Absent"]},
+				"Defined": {"cats": [1], "website": "https://example.com"}
+			}`,
+			want: []found{
+				{"Example", "excludes", "", lint.CheckDanglingReference, lint.SeverityError},
+				{"Example", "implies", "", lint.CheckDanglingReference, lint.SeverityError},
+				{"Example", "requires", "", lint.CheckDanglingReference, lint.SeverityError},
+			},
+		},
+		{
+			name: "implication cycles",
+			technologies: `{
+				"C": {"cats": [1], "website": "https://example.com", "implies": "A"},
+				"A": {"cats": [1], "website": "https://example.com", "implies": ["B"]},
+				"B": {"cats": [1], "website": "https://example.com", "implies": ["C", "D"]},
+				"D": {"cats": [1], "website": "https://example.com"},
+				"Self": {"cats": [1], "website": "https://example.com", "implies": "Self"}
+			}`,
+			want: []found{
+				{"A", "implies", "", lint.CheckImplicationCycle, lint.SeverityError},
+				{"Self", "implies", "", lint.CheckImplicationCycle, lint.SeverityError},
+			},
+		},
+		{
+			name: "empty matches",
+			technologies: `{
+				"Example": {"cats": [1], "website": "https://example.com",
+					"html": ["", "(?:foo)?", "\\;confidence:50", "foo"], "scripts": "x*",
+					"headers": {"X-Example": ""}, "meta": {"generator": ""}, "js": {"example": ""}}
+			}`,
+			want: []found{
+				{"Example", "html", "", lint.CheckEmptyMatch, lint.SeverityWarning},
+				{"Example", "html", "(?:foo)?", lint.CheckEmptyMatch, lint.SeverityWarning},
+				{"Example", "html", "\\;confidence:50", lint.CheckEmptyMatch, lint.SeverityWarning},
+				{"Example", "scripts", "x*", lint.CheckEmptyMatch, lint.SeverityWarning},
+			},
+		},
+		{
+			name: "schema",
+			technologies: `{
+				"Example": {"cats": [], "html": 5, "js": ["Example"], "oss": "yes", "cpe": "f5:nginx", "colour": "blue", "tests": [{"match": "yes"}]},
+				"Array": []
+			}`,
+			want: []found{
+				{"Array", "", "", lint.CheckSchema, lint.SeverityError},
+				{"Example", "cats", "", lint.CheckSchema, lint.SeverityWarning},
+				{"Example", "colour", "", lint.CheckSchema, lint.SeverityWarning},
+				{"Example", "cpe", "", lint.CheckSchema, lint.SeverityWarning},
+				{"Example", "html", "", lint.CheckSchema, lint.SeverityError},
+				{"Example", "js", "", lint.CheckSchema, lint.SeverityError},
+				{"Example", "oss", "", lint.CheckSchema, lint.SeverityError},
+				{"Example", "tests", "", lint.CheckSchema, lint.SeverityError},
+				{"Example", "website", "", lint.CheckSchema, lint.SeverityError},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cats := categories
+			if tt.noCategories {
+				cats = nil
+			}
+			report := lint.Lint(technologies(t, tt.technologies), cats)
+
+			got := []found{}
+			errors, warnings := 0, 0
+			for _, issue := range report.Issues {
+				got = append(got, found{issue.Technology, issue.Field, issue.Pattern, issue.Check, issue.Severity})
+				if issue.Severity == lint.SeverityError {
+					errors++
+				} else {
+					warnings++
+				}
+			}
+			want := tt.want
+			if want == nil {
+				want = []found{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Lint() issues =\n%v\nwant\n%v", got, want)
+			}
+			if report.Errors != errors || report.Warnings != warnings || report.OK() != (errors == 0) {
+				t.Errorf("Lint() counts %d errors and %d warnings, OK() = %v, for %v", report.Errors, report.Warnings, report.OK(), got)
+			}
+		})
+	}
+}
+
+// TestLintAgreesWithLoader checks that technologies whose js values are not
+// all patterns both lint and load
+func TestLintAgreesWithLoader(t *testing.T) {
+	data := `{"Example": {"cats": [1], "website": "https://example.com",
+		"js": {"Example.enabled": true, "Example.build": 2, "Example.config": {"a": 1}}}}`
+	if report := lint.Lint(technologies(t, data), categories); !report.OK() {
+		t.Errorf("Lint() issues = %v", report.Issues)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"technologies.json": data,
+		"categories.json":   `{"1": {"name": "CMS", "priority": 1, "groups": [3]}}`,
+		"groups.json":       `{"3": {"name": "Content"}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+	w, err := wappalyzer.New(wappalyzer.WithSources(wappalyzer.Source{Dir: dir}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if js := w.GetCompiledFingerprints().Apps["Example"].JS; len(js) != 3 {
+		t.Errorf("loaded js patterns = %v, want 3", js)
+	}
+}
+
+// TestLintCycleMessage checks that a cycle is spelled from its first
+// technology in name order, and reported once
+func TestLintCycleMessage(t *testing.T) {
+	report := lint.Lint(technologies(t, `{
+		"Beta": {"cats": [1], "website": "https://example.com", "implies": "Gamma"},
+		"Gamma": {"cats": [1], "website": "https://example.com", "implies": "Alpha"},
+		"Alpha": {"cats": [1], "website": "https://example.com", "implies": "Beta"}
+	}`), categories)
+
+	if len(report.Issues) != 1 {
+		t.Fatalf("Lint() issues = %v, want one cycle", report.Issues)
+	}
+	if want := "implication cycle Alpha -> Beta -> Gamma -> Alpha"; report.Issues[0].Message != want {
+		t.Errorf("cycle message = %q, want %q", report.Issues[0].Message, want)
+	}
+	if line := report.Issues[0].String(); !strings.HasPrefix(line, "error: Alpha.implies [implication-cycle]") {
+		t.Errorf("String() = %q", line)
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // Fingerprints contains a map of fingerprints for tech detection
 type Fingerprints struct {
@@ -16,7 +19,7 @@ type Fingerprint struct {
 	Website     string                 `json:"website"`
 	CPE         string                 `json:"cpe"`
 	Cookies     map[string]string      `json:"cookies"`
-	JS          map[string]string      `json:"js"`
+	JS          JSPatterns             `json:"js"`
 	Headers     map[string]string      `json:"headers"`
 	HTML        interface{}            `json:"html"`
 	Script      interface{}            `json:"scripts"`
@@ -26,6 +29,21 @@ type Fingerprint struct {
 	Name        string                 `json:"name"`
 }
 
+// JSPatterns maps JavaScript properties to patterns. Wappalyzer data may give
+// a property a boolean, a number or an object instead of a pattern, which are
+// read as convertJSMap does.
+type JSPatterns map[string]string
+
+// UnmarshalJSON reads the patterns, whatever the JSON type of their values
+func (p *JSPatterns) UnmarshalJSON(data []byte) error {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*p = convertJSMap(values)
+	return nil
+}
+
 // Technology represents a single technology from the new array-based format
 type Technology struct {
 	Name        string                 `json:"name"`
@@ -108,6 +126,19 @@ func convertJSMap(jsMap map[string]interface{}) map[string]string {
 type CompiledFingerprints struct {
 	// Apps is organized as <name, fingerprint>
 	Apps map[string]*CompiledFingerprint
+	// PatternReports lists the patterns that couldn't be used as written
+	PatternReports []PatternReport
+}
+
+// PatternReport describes what was done with a pattern RE2 rejects
+type PatternReport struct {
+	Technology string `json:"technology"`
+	Field      string `json:"field"`
+	Pattern    string `json:"pattern"`
+	// Skipped is set when the pattern couldn't be used at all
+	Skipped bool `json:"skipped"`
+	// Detail is the translation of the pattern, or why it was skipped
+	Detail string `json:"detail"`
 }
 
 // CompiledFingerprint contains the compiled fingerprints from the tech json
@@ -14,6 +14,8 @@ type ParsedPattern struct {
 	Confidence int
 	// Skip regex compilation (for optimization)
 	SkipRegex bool
+	// Translation describes how a regex RE2 rejects is evaluated, empty if it doesn't
+	Translation string
 }
 
 // MetaTag represents a HTML meta tag with name and content
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvHeader names the columns of the csv format
+var csvHeader = []string{"target", "url", "status", "technology", "version", "categories", "groups", "cpe", "website", "error"}
+
+// csvFormatter writes the csv format: a row per detected technology, and a
+// row without technology for targets with none or that failed. Categories
+// and groups are separated by semicolons.
+type csvFormatter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSV(w io.Writer, info ToolInfo) Formatter {
+	return &csvFormatter{w: csv.NewWriter(w)}
+}
+
+// Write writes the rows of the report
+func (f *csvFormatter) Write(report *Report) error {
+	if !f.header {
+		f.header = true
+		f.w.Write(csvHeader)
+	}
+
+	status := ""
+	if report.StatusCode != 0 {
+		status = strconv.Itoa(report.StatusCode)
+	}
+
+	if len(report.Technologies) == 0 {
+		f.w.Write([]string{report.Target, report.URL, status, "", "", "", "", "", "", report.Error})
+	}
+	for _, technology := range report.Technologies {
+		f.w.Write([]string{
+			report.Target, report.URL, status,
+			technology.Name, technology.Version,
+			strings.Join(technology.Categories, ";"), strings.Join(technology.Groups, ";"),
+			versionedCPE(technology.CPE, technology.Version), technology.Website, report.Error,
+		})
+	}
+
+	f.w.Flush()
+	return f.w.Error()
+}
+
+// Close writes the header when there were no reports
+func (f *csvFormatter) Close() error {
+	if !f.header {
+		f.header = true
+		f.w.Write(csvHeader)
+	}
+	f.w.Flush()
+	return f.w.Error()
+}
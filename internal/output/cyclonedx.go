@@ -0,0 +1,194 @@
+package output
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cycloneDXSpecVersion is the CycloneDX specification the SBOM follows
+const cycloneDXSpecVersion = "1.5"
+
+// cycloneDXBOM is a CycloneDX SBOM in the JSON format
+type cycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp"`
+	Tools     cycloneDXTools      `json:"tools"`
+	Component *cycloneDXComponent `json:"component,omitempty"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type               string               `json:"type"`
+	BOMRef             string               `json:"bom-ref,omitempty"`
+	Name               string               `json:"name"`
+	Version            string               `json:"version,omitempty"`
+	Description        string               `json:"description,omitempty"`
+	CPE                string               `json:"cpe,omitempty"`
+	ExternalReferences []cycloneDXReference `json:"externalReferences,omitempty"`
+	Properties         []cycloneDXProperty  `json:"properties,omitempty"`
+}
+
+type cycloneDXReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cycloneDXFormatter writes the cyclonedx format: an SBOM with a component
+// per technology and version detected, listing the targets it was detected
+// on in wappalyzer:target properties. The analyzed target is the subject of
+// the SBOM when there is only one.
+type cycloneDXFormatter struct {
+	collector
+	w    io.Writer
+	info ToolInfo
+}
+
+func newCycloneDX(w io.Writer, info ToolInfo) Formatter {
+	return &cycloneDXFormatter{w: w, info: info}
+}
+
+// Close writes the SBOM
+func (f *cycloneDXFormatter) Close() error {
+	serial, err := randomUUID()
+	if err != nil {
+		return err
+	}
+
+	bom := cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools: cycloneDXTools{Components: []cycloneDXComponent{{
+				Type:    "application",
+				Name:    f.info.Name,
+				Version: f.info.Version,
+			}}},
+		},
+		Components: []cycloneDXComponent{},
+	}
+
+	if len(f.reports) == 1 {
+		bom.Metadata.Component = &cycloneDXComponent{Type: "application", Name: reportURI(f.reports[0])}
+	}
+
+	// A component per technology and version, whatever the targets
+	components := make(map[string]*cycloneDXComponent)
+	var refs []string
+	for _, report := range f.reports {
+		target := reportURI(report)
+		for _, technology := range report.Technologies {
+			ref := technology.Name
+			if technology.Version != "" {
+				ref += "@" + technology.Version
+			}
+
+			component, ok := components[ref]
+			if !ok {
+				component = newCycloneDXComponent(ref, technology)
+				components[ref] = component
+				refs = append(refs, ref)
+			}
+			component.Properties = append(component.Properties, cycloneDXProperty{Name: "wappalyzer:target", Value: target})
+		}
+	}
+
+	sort.Strings(refs)
+	for _, ref := range refs {
+		bom.Components = append(bom.Components, *components[ref])
+	}
+
+	encoder := json.NewEncoder(f.w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(bom)
+}
+
+// newCycloneDXComponent creates the component of a technology, its targets
+// aside
+func newCycloneDXComponent(ref string, technology Technology) *cycloneDXComponent {
+	component := &cycloneDXComponent{
+		Type:        componentType(technology.Categories),
+		BOMRef:      ref,
+		Name:        technology.Name,
+		Version:     technology.Version,
+		Description: technology.Description,
+		CPE:         versionedCPE(technology.CPE, technology.Version),
+	}
+	if technology.Website != "" {
+		component.ExternalReferences = []cycloneDXReference{{Type: "website", URL: technology.Website}}
+	}
+	for _, category := range technology.Categories {
+		component.Properties = append(component.Properties, cycloneDXProperty{Name: "wappalyzer:category", Value: category})
+	}
+	for _, group := range technology.Groups {
+		component.Properties = append(component.Properties, cycloneDXProperty{Name: "wappalyzer:group", Value: group})
+	}
+	return component
+}
+
+// componentType picks the CycloneDX component type of a technology from its
+// categories
+func componentType(categories []string) string {
+	for _, category := range categories {
+		category = strings.ToLower(category)
+		switch {
+		case strings.Contains(category, "operating system"):
+			return "operating-system"
+		case strings.Contains(category, "framework"):
+			return "framework"
+		case strings.Contains(category, "librar"):
+			return "library"
+		}
+	}
+	return "application"
+}
+
+// versionedCPE fills the version of a CPE 2.3 formatted string, left as a
+// wildcard in fingerprints, with the detected version
+func versionedCPE(cpe, version string) string {
+	if version == "" || !strings.HasPrefix(cpe, "cpe:2.3:") || strings.Contains(cpe, "\\") {
+		return cpe
+	}
+
+	fields := strings.Split(cpe, ":")
+	if len(fields) != 13 || fields[5] != "*" {
+		return cpe
+	}
+	fields[5] = strings.NewReplacer(":", "\\:", "*", "\\*", "?", "\\?").Replace(version)
+	return strings.Join(fields, ":")
+}
+
+// randomUUID returns a random, version 4, UUID
+func randomUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("could not generate a serial number: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonDocument is the JSON format: every report in a single document
+type jsonDocument struct {
+	SchemaVersion int       `json:"schema_version"`
+	Reports       []*Report `json:"reports"`
+}
+
+// jsonFormatter writes the json format
+type jsonFormatter struct {
+	collector
+	w io.Writer
+}
+
+func newJSON(w io.Writer, info ToolInfo) Formatter {
+	return &jsonFormatter{w: w}
+}
+
+// Close writes the document
+func (f *jsonFormatter) Close() error {
+	document := jsonDocument{SchemaVersion: SchemaVersion, Reports: f.reports}
+	if document.Reports == nil {
+		document.Reports = []*Report{}
+	}
+
+	encoder := json.NewEncoder(f.w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(document)
+}
+
+// jsonLinesFormatter writes the jsonl format: a report per line, as the
+// reports complete
+type jsonLinesFormatter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLines(w io.Writer, info ToolInfo) Formatter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonLinesFormatter{encoder: encoder}
+}
+
+// Write writes the report on a line
+func (f *jsonLinesFormatter) Write(report *Report) error {
+	return f.encoder.Encode(report)
+}
+
+// Close does nothing, every line is written already
+func (f *jsonLinesFormatter) Close() error {
+	return nil
+}
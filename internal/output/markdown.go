@@ -0,0 +1,75 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownFormatter writes the markdown format: a table with a row per
+// detected technology, and a row without technology for targets with none
+// or that failed
+type markdownFormatter struct {
+	w      io.Writer
+	header bool
+}
+
+func newMarkdown(w io.Writer, info ToolInfo) Formatter {
+	return &markdownFormatter{w: w}
+}
+
+// Write writes the rows of the report
+func (f *markdownFormatter) Write(report *Report) error {
+	if err := f.writeHeader(); err != nil {
+		return err
+	}
+
+	status := ""
+	switch {
+	case report.Error != "":
+		status = "error: " + report.Error
+	case report.StatusCode != 0:
+		status = fmt.Sprint(report.StatusCode)
+	}
+
+	if len(report.Technologies) == 0 {
+		return f.writeRow(report.Target, status, "", "", "", "")
+	}
+	for _, technology := range report.Technologies {
+		if err := f.writeRow(report.Target, status, technology.Name, technology.Version,
+			strings.Join(technology.Categories, ", "), versionedCPE(technology.CPE, technology.Version)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the header when there were no reports
+func (f *markdownFormatter) Close() error {
+	return f.writeHeader()
+}
+
+// writeHeader writes the header of the table, once
+func (f *markdownFormatter) writeHeader() error {
+	if f.header {
+		return nil
+	}
+	f.header = true
+	_, err := io.WriteString(f.w, "| Target | Status | Technology | Version | Categories | CPE |\n|---|---|---|---|---|---|\n")
+	return err
+}
+
+// writeRow writes a row of the table, escaping the cells
+func (f *markdownFormatter) writeRow(cells ...string) error {
+	var row strings.Builder
+	row.WriteString("|")
+	for _, cell := range cells {
+		cell = strings.ReplaceAll(cell, "|", "\\|")
+		cell = strings.Join(strings.Fields(cell), " ")
+		row.WriteString(" " + cell + " |")
+	}
+	row.WriteString("\n")
+
+	_, err := io.WriteString(f.w, row.String())
+	return err
+}
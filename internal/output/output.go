@@ -0,0 +1,137 @@
+// Package output writes analysis reports in the formats of the command line:
+// a stable JSON document, JSON lines, CSV, a Markdown table, a CycloneDX SBOM
+// and SARIF. Formatters are looked up by name, and new ones can be
+// registered.
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is the version of the report schema, increased on any
+// incompatible change to Report or Technology
+const SchemaVersion = 1
+
+// Report is the analysis of a target
+type Report struct {
+	Target       string       `json:"target"`
+	URL          string       `json:"url,omitempty"`
+	StatusCode   int          `json:"status,omitempty"`
+	Technologies []Technology `json:"technologies"`
+	Error        string       `json:"error,omitempty"`
+	ScannedAt    time.Time    `json:"scanned_at"`
+}
+
+// Technology is a technology detected on a target
+type Technology struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version,omitempty"`
+	Confidence  int      `json:"confidence"`
+	Categories  []string `json:"categories"`
+	Groups      []string `json:"groups"`
+	Description string   `json:"description,omitempty"`
+	Website     string   `json:"website,omitempty"`
+	CPE         string   `json:"cpe,omitempty"`
+}
+
+// Normalize sorts the technologies of the report by name, and their
+// categories and groups, so that the same analysis is always written the
+// same way
+func (r *Report) Normalize() {
+	if r.Technologies == nil {
+		r.Technologies = []Technology{}
+	}
+	sort.Slice(r.Technologies, func(i, j int) bool {
+		return r.Technologies[i].Name < r.Technologies[j].Name
+	})
+	for i := range r.Technologies {
+		technology := &r.Technologies[i]
+		if technology.Categories == nil {
+			technology.Categories = []string{}
+		}
+		if technology.Groups == nil {
+			technology.Groups = []string{}
+		}
+		sort.Strings(technology.Categories)
+		sort.Strings(technology.Groups)
+	}
+}
+
+// Formatter writes reports in an output format. Write is called once per
+// report, as the reports complete, and Close once after the last one.
+// Formats that can't be streamed, such as documents holding every report,
+// are written by Close.
+type Formatter interface {
+	Write(report *Report) error
+	Close() error
+}
+
+// Factory creates a formatter writing to w
+type Factory func(w io.Writer, info ToolInfo) Formatter
+
+// ToolInfo describes the tool writing the reports, for the formats that
+// record it
+type ToolInfo struct {
+	Name    string
+	Version string
+	URL     string
+}
+
+var (
+	formatsMutex sync.RWMutex
+	formats      = map[string]Factory{
+		"json":      newJSON,
+		"jsonl":     newJSONLines,
+		"csv":       newCSV,
+		"markdown":  newMarkdown,
+		"cyclonedx": newCycloneDX,
+		"sarif":     newSARIF,
+	}
+)
+
+// Register adds a format, or replaces the one of the same name
+func Register(name string, factory Factory) {
+	formatsMutex.Lock()
+	defer formatsMutex.Unlock()
+	formats[name] = factory
+}
+
+// Formats returns the names of the registered formats, sorted
+func Formats() []string {
+	formatsMutex.RLock()
+	defer formatsMutex.RUnlock()
+
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a formatter of the named format writing to w
+func New(name string, w io.Writer, info ToolInfo) (Formatter, error) {
+	formatsMutex.RLock()
+	factory, ok := formats[name]
+	formatsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, expected one of %s", name, strings.Join(Formats(), ", "))
+	}
+	return factory(w, info), nil
+}
+
+// collector keeps the reports of the formats written as a whole by Close
+type collector struct {
+	reports []*Report
+}
+
+// Write keeps the report
+func (c *collector) Write(report *Report) error {
+	c.reports = append(c.reports, report)
+	return nil
+}
@@ -0,0 +1,124 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/output"
+)
+
+// sampleReports are a target with technologies, listed out of order, and a
+// target that failed
+func sampleReports() []*output.Report {
+	scannedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []*output.Report{
+		{
+			Target:     "example.com",
+			URL:        "http://example.com",
+			StatusCode: 200,
+			ScannedAt:  scannedAt,
+			Technologies: []output.Technology{
+				{Name: "PHP", Version: "8.2.12", Confidence: 100, Categories: []string{"Programming languages"}, CPE: "cpe:2.3:a:php:php:*:*:*:*:*:*:*:*"},
+				{Name: "Nginx", Version: "1.25.3", Confidence: 100, Categories: []string{"Web servers", "Reverse proxies"}, Groups: []string{"Servers"}, CPE: "cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*"},
+			},
+		},
+		{Target: "unreachable.example", Error: "could not connect", ScannedAt: scannedAt},
+	}
+}
+
+// format writes the sample reports in a format
+func format(t *testing.T, name string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	formatter, err := output.New(name, &buf, output.ToolInfo{Name: "go-wappalyzer", Version: "test"})
+	if err != nil {
+		t.Fatalf("New(%q): %v", name, err)
+	}
+	for _, report := range sampleReports() {
+		report.Normalize()
+		if err := formatter.Write(report); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := formatter.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestJSONLinesSchema(t *testing.T) {
+	want := `{"target":"example.com","url":"http://example.com","status":200,"technologies":[` +
+		`{"name":"Nginx","version":"1.25.3","confidence":100,"categories":["Reverse proxies","Web servers"],"groups":["Servers"],"cpe":"cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*"},` +
+		`{"name":"PHP","version":"8.2.12","confidence":100,"categories":["Programming languages"],"groups":[],"cpe":"cpe:2.3:a:php:php:*:*:*:*:*:*:*:*"}],` +
+		`"scanned_at":"2024-01-02T03:04:05Z"}` + "\n" +
+		`{"target":"unreachable.example","technologies":[],"error":"could not connect","scanned_at":"2024-01-02T03:04:05Z"}` + "\n"
+
+	if got := string(format(t, "jsonl")); got != want {
+		t.Errorf("jsonl output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJSONDocument(t *testing.T) {
+	var document struct {
+		SchemaVersion int               `json:"schema_version"`
+		Reports       []json.RawMessage `json:"reports"`
+	}
+	if err := json.Unmarshal(format(t, "json"), &document); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if document.SchemaVersion != output.SchemaVersion || len(document.Reports) != 2 {
+		t.Errorf("got schema version %d and %d reports, want %d and 2", document.SchemaVersion, len(document.Reports), output.SchemaVersion)
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(format(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	want := [][]string{
+		{"target", "url", "status", "technology", "version", "categories", "groups", "cpe", "website", "error"},
+		{"example.com", "http://example.com", "200", "Nginx", "1.25.3", "Reverse proxies;Web servers", "Servers", "cpe:2.3:a:f5:nginx:1.25.3:*:*:*:*:*:*:*", "", ""},
+		{"example.com", "http://example.com", "200", "PHP", "8.2.12", "Programming languages", "", "cpe:2.3:a:php:php:8.2.12:*:*:*:*:*:*:*", "", ""},
+		{"unreachable.example", "", "", "", "", "", "", "", "", "could not connect"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d: got %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestCycloneDXComponents(t *testing.T) {
+	var bom struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			CPE     string `json:"cpe"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(format(t, "cyclonedx"), &bom); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if bom.BOMFormat != "CycloneDX" || len(bom.Components) != 2 {
+		t.Fatalf("got format %q and %d components, want CycloneDX and 2", bom.BOMFormat, len(bom.Components))
+	}
+	if c := bom.Components[0]; c.Name != "Nginx" || c.Version != "1.25.3" || c.CPE != "cpe:2.3:a:f5:nginx:1.25.3:*:*:*:*:*:*:*" {
+		t.Errorf("got component %+v", c)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := output.New("xml", &bytes.Buffer{}, output.ToolInfo{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,186 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	// sarifVersion is the SARIF specification the log follows
+	sarifVersion = "2.1.0"
+	// sarifSchema is the JSON schema of SARIF 2.1.0 logs
+	sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string                 `json:"id"`
+	ShortDescription sarifMessage           `json:"shortDescription"`
+	HelpURI          string                 `json:"helpUri,omitempty"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	EndTimeUTC                 string              `json:"endTimeUtc"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifFormatter writes the sarif format: a log with a rule per detected
+// technology and a note level result per technology and target. Targets that
+// failed are reported as tool execution notifications.
+type sarifFormatter struct {
+	collector
+	w    io.Writer
+	info ToolInfo
+}
+
+func newSARIF(w io.Writer, info ToolInfo) Formatter {
+	return &sarifFormatter{w: w, info: info}
+}
+
+// Close writes the log
+func (f *sarifFormatter) Close() error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           f.info.Name,
+			Version:        f.info.Version,
+			InformationURI: f.info.URL,
+			Rules:          []sarifRule{},
+		}},
+		Invocations: []sarifInvocation{{
+			ExecutionSuccessful: true,
+			EndTimeUTC:          time.Now().UTC().Format(time.RFC3339),
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]sarifRule)
+	for _, report := range f.reports {
+		location := []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: reportURI(report)},
+		}}}
+
+		if report.Error != "" {
+			run.Invocations[0].ToolExecutionNotifications = append(run.Invocations[0].ToolExecutionNotifications, sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: report.Error},
+				Locations: location,
+			})
+			continue
+		}
+
+		for _, technology := range report.Technologies {
+			if _, ok := rules[technology.Name]; !ok {
+				rules[technology.Name] = sarifRule{
+					ID:               technology.Name,
+					ShortDescription: sarifMessage{Text: technologyDescription(technology)},
+					HelpURI:          technology.Website,
+					Properties:       map[string]interface{}{"categories": technology.Categories, "groups": technology.Groups},
+				}
+			}
+
+			message := technology.Name + " detected"
+			properties := map[string]interface{}{"confidence": technology.Confidence}
+			if technology.Version != "" {
+				message = fmt.Sprintf("%s %s detected", technology.Name, technology.Version)
+				properties["version"] = technology.Version
+			}
+			if cpe := versionedCPE(technology.CPE, technology.Version); cpe != "" {
+				properties["cpe"] = cpe
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:     technology.Name,
+				Level:      "note",
+				Message:    sarifMessage{Text: message},
+				Locations:  location,
+				Properties: properties,
+			})
+		}
+	}
+
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rules[name])
+	}
+
+	encoder := json.NewEncoder(f.w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
+
+// reportURI returns the URL a report was analyzed at, or its target
+func reportURI(report *Report) string {
+	if report.URL != "" {
+		return report.URL
+	}
+	return report.Target
+}
+
+// technologyDescription describes a technology in a sentence
+func technologyDescription(technology Technology) string {
+	if technology.Description != "" {
+		return technology.Description
+	}
+	return technology.Name + " is in use"
+}
@@ -0,0 +1,136 @@
+package parser
+
+import "sort"
+
+// acEdge is a goto transition of the automaton
+type acEdge struct {
+	label byte
+	next  int32
+}
+
+// acState is a single node of the Aho-Corasick trie
+type acState struct {
+	// edges are sorted by label
+	edges []acEdge
+	// fail is the longest proper suffix of this state that is also in the trie
+	fail int32
+	// output is the nearest state on the fail chain that ends a keyword (-1 if none)
+	output int32
+	// keywords ending exactly at this state
+	keywords []int
+}
+
+// ahoCorasick finds all occurrences of a set of keywords in a single pass
+type ahoCorasick struct {
+	states []acState
+	// root holds a dense transition table for the root state, the hottest path
+	root [256]int32
+}
+
+// newAhoCorasick builds an automaton for the keywords, which are identified by their index
+func newAhoCorasick(keywords []string) *ahoCorasick {
+	ac := &ahoCorasick{states: []acState{{fail: 0, output: -1}}}
+
+	// Build the trie
+	for id, keyword := range keywords {
+		state := int32(0)
+		for i := 0; i < len(keyword); i++ {
+			next := ac.child(state, keyword[i])
+			if next < 0 {
+				next = int32(len(ac.states))
+				ac.states = append(ac.states, acState{output: -1})
+				ac.addEdge(state, keyword[i], next)
+			}
+			state = next
+		}
+		ac.states[state].keywords = append(ac.states[state].keywords, id)
+	}
+
+	// Compute fail and output links breadth first
+	queue := make([]int32, 0, len(ac.states))
+	for _, edge := range ac.states[0].edges {
+		ac.root[edge.label] = edge.next
+		queue = append(queue, edge.next)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range ac.states[state].edges {
+			fail := ac.states[state].fail
+			for fail != 0 && ac.child(fail, edge.label) < 0 {
+				fail = ac.states[fail].fail
+			}
+			failState := ac.step(fail, edge.label)
+			if failState < 0 {
+				failState = 0
+			}
+			ac.states[edge.next].fail = failState
+
+			if len(ac.states[failState].keywords) > 0 {
+				ac.states[edge.next].output = failState
+			} else {
+				ac.states[edge.next].output = ac.states[failState].output
+			}
+			queue = append(queue, edge.next)
+		}
+	}
+
+	return ac
+}
+
+// child returns the trie child of state for label, or -1
+func (ac *ahoCorasick) child(state int32, label byte) int32 {
+	edges := ac.states[state].edges
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].label >= label })
+	if i < len(edges) && edges[i].label == label {
+		return edges[i].next
+	}
+	return -1
+}
+
+// addEdge inserts a trie edge keeping the edges sorted
+func (ac *ahoCorasick) addEdge(state int32, label byte, next int32) {
+	edges := ac.states[state].edges
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].label >= label })
+	edges = append(edges, acEdge{})
+	copy(edges[i+1:], edges[i:])
+	edges[i] = acEdge{label: label, next: next}
+	ac.states[state].edges = edges
+}
+
+// step follows a goto transition, treating the root as having a self loop
+func (ac *ahoCorasick) step(state int32, label byte) int32 {
+	if state == 0 {
+		return ac.root[label]
+	}
+	return ac.child(state, label)
+}
+
+// Scan reports the id of every keyword occurring in text. found is called
+// once per occurrence, so a keyword may be reported several times.
+func (ac *ahoCorasick) Scan(text []byte, found func(id int)) {
+	state := int32(0)
+	for _, c := range text {
+		for {
+			if state == 0 {
+				state = ac.root[c]
+				break
+			}
+			if next := ac.child(state, c); next >= 0 {
+				state = next
+				break
+			}
+			state = ac.states[state].fail
+		}
+
+		for _, id := range ac.states[state].keywords {
+			found(id)
+		}
+		for out := ac.states[state].output; out >= 0; out = ac.states[out].output {
+			for _, id := range ac.states[out].keywords {
+				found(id)
+			}
+		}
+	}
+}
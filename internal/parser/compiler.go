@@ -2,6 +2,7 @@ package parser
 
 import (
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
@@ -41,11 +42,26 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 		// Process implied technologies
 		compiledApp.ImpliedTechs = processImpliesList(app.Implies)
 
+		// parse parses a pattern, reporting it unless it is used as written
+		parse := func(field, pattern string) *models.ParsedPattern {
+			parsedPattern, err := ParsePattern(pattern)
+			switch {
+			case err != nil:
+				compiled.PatternReports = append(compiled.PatternReports, models.PatternReport{
+					Technology: name, Field: field, Pattern: pattern, Skipped: true, Detail: err.Error(),
+				})
+			case parsedPattern.Translation != "":
+				compiled.PatternReports = append(compiled.PatternReports, models.PatternReport{
+					Technology: name, Field: field, Pattern: pattern, Detail: parsedPattern.Translation,
+				})
+			}
+			return parsedPattern
+		}
+
 		// Compile header patterns
 		for header, pattern := range app.Headers {
-			parsedPattern, err := ParsePattern(pattern)
-			if err != nil {
-				// Log error and continue with next pattern
+			parsedPattern := parse("headers."+header, pattern)
+			if parsedPattern == nil {
 				continue
 			}
 			compiledApp.HeaderPatterns[strings.ToLower(header)] = parsedPattern
@@ -53,8 +69,8 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 
 		// Compile cookie patterns
 		for cookie, pattern := range app.Cookies {
-			parsedPattern, err := ParsePattern(pattern)
-			if err != nil {
+			parsedPattern := parse("cookies."+cookie, pattern)
+			if parsedPattern == nil {
 				continue
 			}
 			compiledApp.CookiePatterns[strings.ToLower(cookie)] = parsedPattern
@@ -63,8 +79,8 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 		// Compile HTML patterns
 		htmlPatterns := extractPatternList(app.HTML)
 		for _, pattern := range htmlPatterns {
-			parsedPattern, err := ParsePattern(pattern)
-			if err != nil {
+			parsedPattern := parse("html", pattern)
+			if parsedPattern == nil {
 				continue
 			}
 			compiledApp.HTMLPatterns = append(compiledApp.HTMLPatterns, parsedPattern)
@@ -73,8 +89,8 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 		// Compile Script patterns
 		scriptPatterns := extractPatternList(app.Script)
 		for _, pattern := range scriptPatterns {
-			parsedPattern, err := ParsePattern(pattern)
-			if err != nil {
+			parsedPattern := parse("scripts", pattern)
+			if parsedPattern == nil {
 				continue
 			}
 			compiledApp.ScriptPatterns = append(compiledApp.ScriptPatterns, parsedPattern)
@@ -83,8 +99,8 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 		// Compile ScriptSrc patterns
 		scriptSrcPatterns := extractPatternList(app.ScriptSrc)
 		for _, pattern := range scriptSrcPatterns {
-			parsedPattern, err := ParsePattern(pattern)
-			if err != nil {
+			parsedPattern := parse("scriptSrc", pattern)
+			if parsedPattern == nil {
 				continue
 			}
 			compiledApp.ScriptSrcPatterns = append(compiledApp.ScriptSrcPatterns, parsedPattern)
@@ -97,8 +113,8 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 			// Extract meta patterns based on type
 			metaPatterns := extractPatternList(patterns)
 			for _, pattern := range metaPatterns {
-				parsedPattern, err := ParsePattern(pattern)
-				if err != nil {
+				parsedPattern := parse("meta."+metaName, pattern)
+				if parsedPattern == nil {
 					continue
 				}
 				compiledPatterns = append(compiledPatterns, parsedPattern)
@@ -111,8 +127,8 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 
 		// Compile JS patterns
 		for jsName, pattern := range app.JS {
-			parsedPattern, err := ParsePattern(pattern)
-			if err != nil {
+			parsedPattern := parse("js."+jsName, pattern)
+			if parsedPattern == nil {
 				continue
 			}
 			compiledApp.JSPatterns[jsName] = parsedPattern
@@ -121,6 +137,18 @@ func CompileFingerprints(fingerprints *models.Fingerprints) (*models.CompiledFin
 		compiled.Apps[name] = compiledApp
 	}
 
+	// Report in a stable order, the technologies are iterated in map order
+	sort.Slice(compiled.PatternReports, func(i, j int) bool {
+		a, b := compiled.PatternReports[i], compiled.PatternReports[j]
+		if a.Technology != b.Technology {
+			return a.Technology < b.Technology
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		return a.Pattern < b.Pattern
+	})
+
 	return compiled, nil
 }
 
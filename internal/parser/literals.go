@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+)
+
+// minLiteralLength is the shortest literal worth prefiltering on; shorter ones
+// occur in nearly every body and only add noise to the automaton
+const minLiteralLength = 3
+
+// maxLiteralAlternatives caps how many alternatives a requirement may have
+const maxLiteralAlternatives = 16
+
+// RequiredLiterals returns a set of lowercase literals of which at least one
+// must occur in any text the pattern matches (after folding with FoldBody).
+// It returns nil when no useful requirement could be derived, in which case
+// the pattern always has to be evaluated.
+func RequiredLiterals(pattern *models.ParsedPattern) []string {
+	if pattern.IsLiteral {
+		return usableLiterals([]string{pattern.Pattern})
+	}
+
+	// Translated patterns require the literals of the RE2 expression they
+	// are matched with, lookarounds only narrow its matches down
+	regex, err := CompileRegex(pattern.Pattern)
+	if err != nil || regex.Translation().Expression == "" {
+		return nil
+	}
+
+	re, err := syntax.Parse(regex.Translation().Expression, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	return usableLiterals(requiredLiterals(re.Simplify()))
+}
+
+// requiredLiterals computes the literal requirement of a regex syntax tree
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+
+	case syntax.OpCapture:
+		return requiredLiterals(re.Sub[0])
+
+	case syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+		return nil
+
+	case syntax.OpAlternate:
+		// Every branch must contribute, otherwise the alternation requires nothing
+		var alternatives []string
+		for _, sub := range re.Sub {
+			literals := usableLiterals(requiredLiterals(sub))
+			if literals == nil {
+				return nil
+			}
+			alternatives = append(alternatives, literals...)
+		}
+		if len(alternatives) > maxLiteralAlternatives {
+			return nil
+		}
+		return alternatives
+
+	case syntax.OpConcat:
+		// Adjacent literals form one longer literal, then keep the strongest requirement
+		var best []string
+		var run strings.Builder
+		consider := func(literals []string) {
+			if literals = usableLiterals(literals); literals != nil && betterLiterals(literals, best) {
+				best = literals
+			}
+		}
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run.WriteString(string(sub.Rune))
+				continue
+			}
+			if run.Len() > 0 {
+				consider([]string{run.String()})
+				run.Reset()
+			}
+			consider(requiredLiterals(sub))
+		}
+		if run.Len() > 0 {
+			consider([]string{run.String()})
+		}
+		return best
+	}
+
+	return nil
+}
+
+// usableLiterals lowercases the literals and returns nil unless all of them
+// are long enough and ASCII, so that they survive case folding unchanged
+func usableLiterals(literals []string) []string {
+	if len(literals) == 0 {
+		return nil
+	}
+
+	usable := make([]string, 0, len(literals))
+	for _, literal := range literals {
+		if len(literal) < minLiteralLength {
+			return nil
+		}
+		for i := 0; i < len(literal); i++ {
+			if literal[i] >= utf8.RuneSelf {
+				return nil
+			}
+		}
+		usable = append(usable, strings.ToLower(literal))
+	}
+	return usable
+}
+
+// betterLiterals reports whether requirement a is more selective than b,
+// preferring longer shortest literals and then fewer alternatives
+func betterLiterals(a, b []string) bool {
+	if b == nil {
+		return true
+	}
+	if shortestA, shortestB := shortestLiteral(a), shortestLiteral(b); shortestA != shortestB {
+		return shortestA > shortestB
+	}
+	return len(a) < len(b)
+}
+
+// shortestLiteral returns the length of the shortest literal in the set
+func shortestLiteral(literals []string) int {
+	shortest := -1
+	for _, literal := range literals {
+		if shortest < 0 || len(literal) < shortest {
+			shortest = len(literal)
+		}
+	}
+	return shortest
+}
@@ -0,0 +1,270 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"sync"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+)
+
+// longS is U+017F LATIN SMALL LETTER LONG S, the only rune that case folds to
+// an ASCII letter but is left unchanged by bytes.ToLower
+var longS = []byte("ſ")
+
+// matcherEntry is a single pattern of a technology
+type matcherEntry struct {
+	tech    string
+	pattern *models.ParsedPattern
+	// index is the position of the pattern in the technology's pattern list
+	index int
+	// prefiltered is set when the entry is only evaluated after a literal hit
+	prefiltered bool
+	// anchoredStart and anchoredEnd are set when the pattern asserts the
+	// start or the end of the body, see (*BodyMatcher).MatchPart
+	anchoredStart bool
+	anchoredEnd   bool
+}
+
+// BodyMatcher evaluates a set of body patterns (HTML or script) with a
+// literal prefilter: the required literals of all patterns are searched in a
+// single Aho-Corasick pass, and only patterns whose literals occur are
+// evaluated in full.
+type BodyMatcher struct {
+	entries []matcherEntry
+	// owners maps a literal id to the entries requiring it
+	owners [][]int
+	// always lists the entries without a usable literal
+	always    []int
+	literals  []string
+	automaton *ahoCorasick
+	// anchorsOnce computes the anchors of the entries on the first MatchPart
+	anchorsOnce sync.Once
+}
+
+// MatcherIndex is the serialisable form of a BodyMatcher's prefilter, which
+// lets a matcher be restored without analysing every pattern again
+type MatcherIndex struct {
+	// Entries identifies each pattern by technology and position
+	Entries []MatcherIndexEntry
+	// Literals are the required literals, identified by their position
+	Literals []string
+	// Owners maps a literal to the entries requiring it
+	Owners [][]int
+	// Always lists the entries without a usable literal
+	Always []int
+}
+
+// MatcherIndexEntry identifies a pattern of a technology
+type MatcherIndexEntry struct {
+	Tech    string
+	Pattern int
+}
+
+// CompileBodyMatcher builds a BodyMatcher for the patterns of each technology
+func CompileBodyMatcher(patterns map[string][]*models.ParsedPattern) *BodyMatcher {
+	matcher := &BodyMatcher{}
+	literalIDs := make(map[string]int)
+	var literals []string
+
+	for tech, techPatterns := range patterns {
+		for index, pattern := range techPatterns {
+			entry := len(matcher.entries)
+			matcher.entries = append(matcher.entries, matcherEntry{tech: tech, pattern: pattern, index: index})
+
+			required := RequiredLiterals(pattern)
+			if required == nil {
+				matcher.always = append(matcher.always, entry)
+				continue
+			}
+			matcher.entries[entry].prefiltered = true
+
+			for _, literal := range required {
+				id, ok := literalIDs[literal]
+				if !ok {
+					id = len(literals)
+					literalIDs[literal] = id
+					literals = append(literals, literal)
+					matcher.owners = append(matcher.owners, nil)
+				}
+				matcher.owners[id] = append(matcher.owners[id], entry)
+			}
+		}
+	}
+
+	matcher.literals = literals
+	matcher.automaton = newAhoCorasick(literals)
+	return matcher
+}
+
+// NewBodyMatcherFromIndex restores a BodyMatcher for the patterns from an
+// index previously returned by Index, skipping the literal analysis
+func NewBodyMatcherFromIndex(patterns map[string][]*models.ParsedPattern, index *MatcherIndex) (*BodyMatcher, error) {
+	if len(index.Owners) != len(index.Literals) {
+		return nil, fmt.Errorf("index has %d literals but %d owner lists", len(index.Literals), len(index.Owners))
+	}
+
+	matcher := &BodyMatcher{
+		entries:  make([]matcherEntry, len(index.Entries)),
+		owners:   index.Owners,
+		always:   index.Always,
+		literals: index.Literals,
+	}
+
+	for i, entry := range index.Entries {
+		techPatterns := patterns[entry.Tech]
+		if entry.Pattern < 0 || entry.Pattern >= len(techPatterns) {
+			return nil, fmt.Errorf("index refers to missing pattern %d of %s", entry.Pattern, entry.Tech)
+		}
+		matcher.entries[i] = matcherEntry{
+			tech:        entry.Tech,
+			pattern:     techPatterns[entry.Pattern],
+			index:       entry.Pattern,
+			prefiltered: true,
+		}
+	}
+
+	for _, entry := range index.Always {
+		if entry < 0 || entry >= len(matcher.entries) {
+			return nil, fmt.Errorf("index refers to missing entry %d", entry)
+		}
+		matcher.entries[entry].prefiltered = false
+	}
+	for _, owners := range index.Owners {
+		for _, entry := range owners {
+			if entry < 0 || entry >= len(matcher.entries) {
+				return nil, fmt.Errorf("index refers to missing entry %d", entry)
+			}
+		}
+	}
+
+	matcher.automaton = newAhoCorasick(index.Literals)
+	return matcher, nil
+}
+
+// Index returns the serialisable prefilter index of the matcher
+func (m *BodyMatcher) Index() *MatcherIndex {
+	index := &MatcherIndex{
+		Entries:  make([]MatcherIndexEntry, len(m.entries)),
+		Literals: m.literals,
+		Owners:   m.owners,
+		Always:   m.always,
+	}
+	for i, entry := range m.entries {
+		index.Entries[i] = MatcherIndexEntry{Tech: entry.tech, Pattern: entry.index}
+	}
+	return index
+}
+
+// Match adds every technology with a matching pattern to technologies.
+// Technologies already present are not evaluated again.
+func (m *BodyMatcher) Match(body []byte, technologies map[string]struct{}) {
+	m.match(body, true, true, technologies)
+}
+
+// MatchPart is Match for a part of a body. Patterns anchored to the start of
+// the body are only evaluated if the part is its start, and those anchored to
+// its end only if the part is its end, since the anchors would otherwise hold
+// at the edges of the part.
+func (m *BodyMatcher) MatchPart(part []byte, first, last bool, technologies map[string]struct{}) {
+	if !first || !last {
+		m.anchorsOnce.Do(m.findAnchors)
+	}
+	m.match(part, first, last, technologies)
+}
+
+// findAnchors sets the anchors of every entry
+func (m *BodyMatcher) findAnchors() {
+	for i := range m.entries {
+		m.entries[i].anchoredStart, m.entries[i].anchoredEnd = patternAnchors(m.entries[i].pattern)
+	}
+}
+
+// match evaluates the patterns against body, skipping those anchored to a
+// start or an end body doesn't hold
+func (m *BodyMatcher) match(body []byte, first, last bool, technologies map[string]struct{}) {
+	seen := make([]bool, len(m.entries))
+	candidates := make([]int, 0, len(m.always))
+	for _, entry := range m.always {
+		seen[entry] = true
+		candidates = append(candidates, entry)
+	}
+
+	m.automaton.Scan(FoldBody(body), func(id int) {
+		for _, entry := range m.owners[id] {
+			if !seen[entry] {
+				seen[entry] = true
+				candidates = append(candidates, entry)
+			}
+		}
+	})
+
+	var bodyStr string
+	for _, index := range candidates {
+		entry := m.entries[index]
+		if _, ok := technologies[entry.tech]; ok {
+			continue
+		}
+		if (!first && entry.anchoredStart) || (!last && entry.anchoredEnd) {
+			continue
+		}
+
+		// A case-insensitive literal is confirmed by the prefilter hit itself
+		if entry.prefiltered && entry.pattern.IsLiteral && !entry.pattern.IsCaseSensitive {
+			technologies[entry.tech] = struct{}{}
+			continue
+		}
+
+		if bodyStr == "" {
+			bodyStr = string(body)
+		}
+		if MatchPattern(entry.pattern, bodyStr) {
+			technologies[entry.tech] = struct{}{}
+		}
+	}
+}
+
+// patternAnchors reports whether a pattern asserts the start or the end of
+// the text, or of a line
+func patternAnchors(pattern *models.ParsedPattern) (start, end bool) {
+	if pattern.IsLiteral {
+		return false, false
+	}
+
+	regex, err := CompileRegex(pattern.Pattern)
+	if err == nil && regex.Translation().Expression != "" {
+		if re, err := syntax.Parse(regex.Translation().Expression, syntax.Perl); err == nil {
+			return hasOp(re, syntax.OpBeginText, syntax.OpBeginLine), hasOp(re, syntax.OpEndText, syntax.OpEndLine)
+		}
+	}
+
+	// Patterns for the backtracking engine are not parsed, assume the worst
+	return strings.Contains(pattern.Pattern, "^"), strings.Contains(pattern.Pattern, "$")
+}
+
+// hasOp reports whether a regex syntax tree contains any of ops
+func hasOp(re *syntax.Regexp, ops ...syntax.Op) bool {
+	for _, op := range ops {
+		if re.Op == op {
+			return true
+		}
+	}
+	for _, sub := range re.Sub {
+		if hasOp(sub, ops...) {
+			return true
+		}
+	}
+	return false
+}
+
+// FoldBody lowercases a body the way the prefilter literals are lowercased,
+// so that any case-insensitive match leaves its literals intact
+func FoldBody(body []byte) []byte {
+	folded := bytes.ToLower(body)
+	if bytes.Contains(folded, longS) {
+		folded = bytes.ReplaceAll(folded, longS, []byte("s"))
+	}
+	return folded
+}
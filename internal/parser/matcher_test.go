@@ -0,0 +1,132 @@
+package parser_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/detection"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+// syntheticPatterns builds n technologies with pattern shapes found in real fingerprints
+func syntheticPatterns(tb testing.TB, n int) map[string][]*models.ParsedPattern {
+	shapes := []string{
+		`<link[^>]+/tech%d-content/`,
+		`tech%d[.-]([\d.]*\d)[^/]*\.js\;version:\1`,
+		`(?:tech%da|tech%db)\(`,
+		`<div id="tech%d-root"`,
+		`Powered by Tech%d`,
+		`data-tech%d=["']?(?:on|yes)`,
+	}
+
+	patterns := make(map[string][]*models.ParsedPattern)
+	for i := 0; i < n; i++ {
+		shape := shapes[i%len(shapes)]
+		raw := strings.ReplaceAll(shape, "%d", fmt.Sprint(i))
+
+		pattern, err := parser.ParsePattern(raw)
+		if err != nil {
+			tb.Fatalf("could not parse %q: %v", raw, err)
+		}
+		patterns[fmt.Sprintf("Tech%d", i)] = []*models.ParsedPattern{pattern}
+	}
+
+	// A few patterns without any usable literal must still be evaluated
+	for _, raw := range []string{`^\s*<!doctype`, `<x-[a-z]{2}>`} {
+		pattern, err := parser.ParsePattern(raw)
+		if err != nil {
+			tb.Fatalf("could not parse %q: %v", raw, err)
+		}
+		patterns["NoLiteral "+raw] = []*models.ParsedPattern{pattern}
+	}
+
+	return patterns
+}
+
+// syntheticBody builds an HTML page of roughly size bytes mentioning a few technologies
+func syntheticBody(size int) []byte {
+	var body strings.Builder
+	body.WriteString("<!DOCTYPE html><html><head>")
+	body.WriteString(`<link rel="stylesheet" href="/tech6-content/style.css">`)
+	body.WriteString(`<script src="/static/tech7-1.2.3.min.js"></script>`)
+	body.WriteString(`<script>TECH8B(window)</script><x-ab></x-ab>`)
+	for body.Len() < size {
+		body.WriteString(`<p class="lorem">Lorem ipsum dolor sit amet, consectetur adipiscing elit.</p>`)
+	}
+	body.WriteString(`<footer>powered by tech10</footer><span data-tech11=yes></span></body></html>`)
+	return []byte(body.String())
+}
+
+func TestBodyMatcherMatchesUnfilteredPath(t *testing.T) {
+	patterns := syntheticPatterns(t, 200)
+	body := syntheticBody(16 * 1024)
+
+	expected := make(map[string]struct{})
+	detection.MatchHTML(patterns, body, expected)
+
+	actual := make(map[string]struct{})
+	parser.CompileBodyMatcher(patterns).Match(body, actual)
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("prefiltered matches differ:\nexpected %v\nactual   %v", expected, actual)
+	}
+	if len(actual) < 6 {
+		t.Fatalf("expected the synthetic technologies to be detected, got %v", actual)
+	}
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	cases := map[string][]string{
+		`<link[^>]+wp-content`:     {"wp-content"},
+		`(?:gtag|analytics)\.js`:   {"gtag", "analytics"},
+		`jquery[.-]([\d.]*\d)\.js`: {"jquery"},
+		`Powered by Foo`:           {"powered by foo"},
+		`^\s*<`:                    nil,
+		`(?:ab|c)d?`:               nil,
+	}
+
+	for raw, expected := range cases {
+		pattern, err := parser.ParsePattern(raw)
+		if err != nil {
+			t.Fatalf("could not parse %q: %v", raw, err)
+		}
+		if actual := parser.RequiredLiterals(pattern); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("RequiredLiterals(%q) = %q, expected %q", raw, actual, expected)
+		}
+	}
+}
+
+func BenchmarkMatchHTMLUnfiltered(b *testing.B) {
+	patterns := syntheticPatterns(b, 1000)
+	body := syntheticBody(64 * 1024)
+
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		detection.MatchHTML(patterns, body, make(map[string]struct{}))
+	}
+}
+
+func BenchmarkMatchHTMLPrefiltered(b *testing.B) {
+	patterns := syntheticPatterns(b, 1000)
+	body := syntheticBody(64 * 1024)
+	matcher := parser.CompileBodyMatcher(patterns)
+
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher.Match(body, make(map[string]struct{}))
+	}
+}
+
+func BenchmarkCompileBodyMatcher(b *testing.B) {
+	patterns := syntheticPatterns(b, 1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser.CompileBodyMatcher(patterns)
+	}
+}
@@ -3,66 +3,66 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
 )
 
-var (
-	// Cache of compiled regular expressions
-	regexCache = make(map[string]*regexp.Regexp)
+// templateRef matches the group references of a version template
+var templateRef = regexp.MustCompile(`\\(\d+)`)
 
-	// Common version detection patterns
-	versionPatterns = []*regexp.Regexp{
-		regexp.MustCompile(`\\;version:(.+)`),
-		regexp.MustCompile(`[^\d](\d+(?:\.\d+)+)`),
-		regexp.MustCompile(`\bv?(\d+(?:\.\d+)+)`),
-		regexp.MustCompile(`version[:/\s]*([\d.]+)`),
-	}
-)
+// Cache of compiled regular expressions
+var regexCache = make(map[string]*Regex)
 
 // ParsePattern parses a pattern string into a structured model
 // This is crucial for pattern matching in fingerprinting
 func ParsePattern(pattern string) (*models.ParsedPattern, error) {
+	// Clean the pattern if it contains version info, so that the directives
+	// don't count as regex syntax
+	cleanedPattern := cleanPatternString(pattern)
+
 	parsedPattern := &models.ParsedPattern{
-		Pattern:         pattern,
-		IsLiteral:       !isRegexPattern(pattern),
-		IsCaseSensitive: isCaseSensitive(pattern),
+		Pattern:         cleanedPattern,
+		IsLiteral:       !isRegexPattern(cleanedPattern),
+		IsCaseSensitive: isCaseSensitive(cleanedPattern),
 		Confidence:      100, // Default confidence
 	}
 
 	// Check for version extraction
-	version, confidence := extractVersionInfo(pattern)
-	if version != "" {
-		parsedPattern.Version = version
-		parsedPattern.Confidence = confidence
-	}
-
-	// Clean the pattern if it contains version info
-	cleanedPattern := cleanPatternString(pattern)
+	parsedPattern.Version, parsedPattern.Confidence = extractVersionInfo(pattern)
 
 	// If it's a regex pattern, try to compile it
 	if !parsedPattern.IsLiteral {
-		if _, err := CompileRegex(cleanedPattern); err != nil {
+		regex, err := CompileRegex(cleanedPattern)
+		if err != nil {
 			return nil, fmt.Errorf("invalid regex pattern: %v", err)
 		}
-		parsedPattern.Pattern = cleanedPattern
+		if translation := regex.Translation(); translation.Strategy != StrategyNative {
+			parsedPattern.Translation = translation.String()
+		}
 	}
 
 	return parsedPattern, nil
 }
 
-// EvaluatePattern checks if a target string matches the pattern and extracts version info
+// EvaluatePattern checks if a target string matches the pattern and extracts
+// version info, by filling the version template of the pattern with the
+// groups of the match
 func EvaluatePattern(pattern *models.ParsedPattern, target string) (bool, string) {
-	// For literal patterns, perform simple contains check
+	// For literal patterns, perform simple contains check. A literal has no
+	// groups, so its version template only resolves to constant text.
 	if pattern.IsLiteral {
+		matched := false
 		if pattern.IsCaseSensitive {
-			return strings.Contains(target, pattern.Pattern), pattern.Version
+			matched = strings.Contains(target, pattern.Pattern)
+		} else {
+			matched = strings.Contains(strings.ToLower(target), strings.ToLower(pattern.Pattern))
+		}
+		if !matched || pattern.Version == "" {
+			return matched, ""
 		}
-		return strings.Contains(
-			strings.ToLower(target),
-			strings.ToLower(pattern.Pattern),
-		), pattern.Version
+		return true, resolveVersion(pattern.Version, []string{pattern.Pattern})
 	}
 
 	// For regex patterns, use the compiled regex
@@ -71,25 +71,71 @@ func EvaluatePattern(pattern *models.ParsedPattern, target string) (bool, string
 		return false, ""
 	}
 
-	// If the pattern matches, extract version if not already known
-	if regex.MatchString(target) {
-		version := pattern.Version
-
-		// If no version in pattern, try to extract from target
-		if version == "" {
-			for _, vp := range versionPatterns {
-				match := vp.FindStringSubmatch(target)
-				if len(match) > 1 {
-					version = match[1]
-					break
+	// Only patterns with a version template need the groups of the match
+	if pattern.Version == "" {
+		return regex.MatchString(target), ""
+	}
+	groups := regex.FindStringSubmatch(target)
+	if groups == nil {
+		return false, ""
+	}
+	return true, resolveVersion(pattern.Version, groups)
+}
+
+// resolveVersion fills a version template with the groups of a match, as
+// Wappalyzer does: \1 is replaced by the first group, and \1?a:b gives a if
+// the first group is not empty and b otherwise. Groups are replaced from the
+// last one down, so \1 never replaces the start of \10.
+func resolveVersion(template string, groups []string) string {
+	version := strings.TrimSpace(template)
+
+	// References to groups the pattern doesn't have resolve to empty groups
+	last := len(groups) - 1
+	for _, ref := range templateRef.FindAllStringSubmatch(version, -1) {
+		if n, err := strconv.Atoi(ref[1]); err == nil && n > last {
+			last = n
+		}
+	}
+
+	for i := last; i >= 0; i-- {
+		var group string
+		if i < len(groups) {
+			group = groups[i]
+		}
+		ref := fmt.Sprintf("\\%d", i)
+
+		// A ternary runs to the end of the template
+		if start := strings.Index(version, ref+"?"); start >= 0 {
+			rest := version[start+len(ref)+1:]
+			if colon := strings.Index(rest, ":"); colon > 0 {
+				if group != "" {
+					version = version[:start] + rest[:colon]
+				} else {
+					version = version[:start] + rest[colon+1:]
 				}
 			}
 		}
 
-		return true, version
+		version = strings.ReplaceAll(version, ref, group)
+	}
+	return strings.TrimSpace(version)
+}
+
+// MatchPattern checks if a target string matches the pattern, without the
+// cost of extracting version information
+func MatchPattern(pattern *models.ParsedPattern, target string) bool {
+	if pattern.IsLiteral {
+		if pattern.IsCaseSensitive {
+			return strings.Contains(target, pattern.Pattern)
+		}
+		return strings.Contains(strings.ToLower(target), strings.ToLower(pattern.Pattern))
 	}
 
-	return false, ""
+	regex, err := CompileRegex(pattern.Pattern)
+	if err != nil {
+		return false
+	}
+	return regex.MatchString(target)
 }
 
 // isRegexPattern checks if the pattern is a regular expression
@@ -116,39 +162,29 @@ func isCaseSensitive(pattern string) bool {
 	return strings.Contains(pattern, "(?-i)") && !strings.Contains(pattern, "(?i)")
 }
 
-// extractVersionInfo extracts version information and confidence from a pattern
+// extractVersionInfo extracts the version template and confidence from the
+// Wappalyzer directives following a pattern, as in \;version:\1\;confidence:50
 func extractVersionInfo(pattern string) (string, int) {
-	// First try to extract version from Wappalyzer specific format
-	parts := strings.Split(pattern, "\\;")
-	if len(parts) > 1 {
-		for _, part := range parts[1:] {
-			if strings.HasPrefix(part, "version:") {
-				return strings.TrimPrefix(part, "version:"), 80
-			}
-			if strings.HasPrefix(part, "confidence:") {
-				confidence := 100 // Default
-				fmt.Sscanf(strings.TrimPrefix(part, "confidence:"), "%d", &confidence)
-				return "", confidence
-			}
-		}
-	}
+	version, confidence := "", 100 // No version, default confidence
 
-	// Try common version patterns
-	for _, vp := range versionPatterns {
-		match := vp.FindStringSubmatch(pattern)
-		if len(match) > 1 {
-			return match[1], 70 // Lower confidence for generic patterns
+	parts := strings.Split(pattern, "\\;")
+	for _, part := range parts[1:] {
+		switch {
+		case strings.HasPrefix(part, "version:"):
+			version = strings.TrimPrefix(part, "version:")
+		case strings.HasPrefix(part, "confidence:"):
+			fmt.Sscanf(strings.TrimPrefix(part, "confidence:"), "%d", &confidence)
 		}
 	}
 
-	return "", 100 // No version, default confidence
+	return version, confidence
 }
 
 // cleanPatternString removes Wappalyzer-specific directives from patterns
 func cleanPatternString(pattern string) string {
 	// Remove version and other directives
 	index := strings.Index(pattern, "\\;")
-	if index > 0 {
+	if index >= 0 {
 		pattern = pattern[:index]
 	}
 
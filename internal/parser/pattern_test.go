@@ -0,0 +1,74 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+func TestEvaluatePatternVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		target      string
+		wantMatch   bool
+		wantVersion string
+	}{
+		{"group", `nginx/([\d.]+)\;version:\1`, "nginx/1.25.3", true, "1.25.3"},
+		{"constant version", `Foo Server\;version:2`, "Foo Server", true, "2"},
+		{"no template", `nginx/([\d.]+)`, "nginx/1.25.3", true, ""},
+		{"no version guessed from the target", `Powered by Foo`, "Powered by Foo version 4.5", true, ""},
+		{"no version guessed from the pattern", `jquery-1\.12\.4\.min\.js`, "jquery-1.12.4.min.js", true, ""},
+		{"no match", `nginx/([\d.]+)\;version:\1`, "Apache/2.4", false, ""},
+		{"ternary, group matched", `Foo(Pro)?\;version:\1?pro:community`, "FooPro", true, "pro"},
+		{"ternary, group empty", `Foo(Pro)?\;version:\1?pro:community`, "Foo", true, "community"},
+		{"ternary with references, group matched", `foo(\d+)(beta)?\;version:\2?\1-beta:\1`, "foo3beta", true, "3-beta"},
+		{"ternary with references, group empty", `foo(\d+)(beta)?\;version:\2?\1-beta:\1`, "foo3", true, "3"},
+		{"ternary after text", `v(\d+)(-rc)?\;version:\1.x\2?-rc:`, "v7-rc", true, "7.x-rc"},
+		{"ten groups and more", `(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)(k)\;version:\11.\10.\1`, "abcdefghijk", true, "k.j.a"},
+		{"ternary on a tenth group", `(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)?\;version:\10?ten:\1`, "abcdefghi", true, "a"},
+		{"with confidence", `nginx/([\d.]+)\;version:\1\;confidence:50`, "nginx/1.25.3", true, "1.25.3"},
+		{"literal with confidence", `varnish\;confidence:50`, "1.1 varnish", true, ""},
+		{"empty with confidence", `\;confidence:50`, "32770", true, ""},
+		{"literal with constant version", `Foo Server\;version:2`, "foo server", true, "2"},
+		{"literal with a reference", `Foo Server\;version:\1?new:old`, "Foo Server", true, "old"},
+		{"missing group", `Fo+ (\d+)\;version:\2?\1-beta:\1`, "Foo 3", true, "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, err := parser.ParsePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("ParsePattern() error = %v", err)
+			}
+			matched, version := parser.EvaluatePattern(pattern, tt.target)
+			if matched != tt.wantMatch || version != tt.wantVersion {
+				t.Errorf("EvaluatePattern() = %v, %q, want %v, %q", matched, version, tt.wantMatch, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestParsePatternConfidence(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{`nginx`, 100},
+		{`nginx/([\d.]+)\;version:\1`, 100},
+		{`nginx\;confidence:50`, 50},
+		{`\;confidence:50`, 50},
+		{`nginx/([\d.]+)\;version:\1\;confidence:25`, 25},
+		{`nginx/([\d.]+)\;confidence:25\;version:\1`, 25},
+	}
+
+	for _, tt := range tests {
+		pattern, err := parser.ParsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q) error = %v", tt.pattern, err)
+		}
+		if pattern.Confidence != tt.want {
+			t.Errorf("ParsePattern(%q) confidence = %d, want %d", tt.pattern, pattern.Confidence, tt.want)
+		}
+	}
+}
@@ -4,27 +4,164 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+// DefaultBacktrackingBudget is how long a single match of a pattern evaluated
+// by the backtracking engine may take by default
+const DefaultBacktrackingBudget = 100 * time.Millisecond
+
+var (
+	// regexCacheMutex guards regexCache and backtrackingBudget, patterns may
+	// be compiled lazily while matching
+	regexCacheMutex sync.RWMutex
+
+	// backtrackingBudget bounds a single backtracking match, zero disables the engine
+	backtrackingBudget = DefaultBacktrackingBudget
 )
 
-// CompileRegex compiles a regular expression string into a regexp.Regexp
+// Regex is a compiled pattern. Patterns RE2 rejects are either rewritten into
+// an RE2 expression, possibly with lookarounds checked around each match, or
+// evaluated by a backtracking engine within a time budget.
+type Regex struct {
+	re           *regexp.Regexp
+	checks       []lookaround
+	backtracking *regexp2.Regexp
+	// fallback evaluates a rewritten pattern whose first match fails its
+	// lookaround checks, nil when the backtracking engine is disabled
+	fallback    *regexp2.Regexp
+	translation Translation
+}
+
+// MatchString reports whether the pattern matches s. A backtracking match
+// running out of its time budget counts as no match.
+func (r *Regex) MatchString(s string) bool {
+	switch {
+	case r.backtracking != nil:
+		return backtrackingMatch(r.backtracking, s)
+	case len(r.checks) == 0:
+		return r.re.MatchString(s)
+	}
+
+	for i, match := range r.re.FindAllStringIndex(s, -1) {
+		if r.checksPass(s, match[0], match[1]) {
+			return true
+		}
+		// A backtracking engine may retry a shorter first match
+		if i == 0 && r.fallback != nil {
+			return backtrackingMatch(r.fallback, s)
+		}
+	}
+	return false
+}
+
+// FindStringSubmatch returns the leftmost match of the pattern in s followed
+// by its groups, unmatched groups being empty, or nil if there is no match
+func (r *Regex) FindStringSubmatch(s string) []string {
+	switch {
+	case r.backtracking != nil:
+		return backtrackingSubmatch(r.backtracking, s)
+	case len(r.checks) == 0:
+		return r.re.FindStringSubmatch(s)
+	}
+
+	for n, match := range r.re.FindAllStringSubmatchIndex(s, -1) {
+		if r.checksPass(s, match[0], match[1]) {
+			result := make([]string, len(match)/2)
+			for i := range result {
+				if match[2*i] >= 0 {
+					result[i] = s[match[2*i]:match[2*i+1]]
+				}
+			}
+			return result
+		}
+		if n == 0 && r.fallback != nil {
+			return backtrackingSubmatch(r.fallback, s)
+		}
+	}
+	return nil
+}
+
+// backtrackingMatch reports whether a backtracking expression matches s
+// within its time budget
+func backtrackingMatch(re *regexp2.Regexp, s string) bool {
+	matched, err := re.MatchString(s)
+	return err == nil && matched
+}
+
+// backtrackingSubmatch returns the groups of the first match of a
+// backtracking expression in s, or nil if there is none within its budget
+func backtrackingSubmatch(re *regexp2.Regexp, s string) []string {
+	match, err := re.FindStringMatch(s)
+	if err != nil || match == nil {
+		return nil
+	}
+	groups := match.Groups()
+	result := make([]string, len(groups))
+	for i, group := range groups {
+		result[i] = group.String()
+	}
+	return result
+}
+
+// checksPass reports whether all lookarounds hold around a match
+func (r *Regex) checksPass(s string, start, end int) bool {
+	for _, check := range r.checks {
+		if !check.pass(s, start, end) {
+			return false
+		}
+	}
+	return true
+}
+
+// Translation describes how the pattern is evaluated
+func (r *Regex) Translation() Translation {
+	return r.translation
+}
+
+// SetBacktrackingBudget sets how long a single match of a pattern evaluated by
+// the backtracking engine may take. Zero disables the engine, so patterns
+// needing it fail to compile as they did before. The setting is global, and
+// patterns are compiled again on their next use. The engine only checks the
+// budget periodically, so a match may overrun a short budget.
+func SetBacktrackingBudget(budget time.Duration) {
+	regexCacheMutex.Lock()
+	defer regexCacheMutex.Unlock()
+
+	if budget < 0 {
+		budget = 0
+	}
+	backtrackingBudget = budget
+	regexCache = make(map[string]*Regex)
+}
+
+// CompileRegex compiles a regular expression string into a Regex
 // It caches the compiled regexes for better performance
-func CompileRegex(pattern string) (*regexp.Regexp, error) {
+func CompileRegex(pattern string) (*Regex, error) {
 	// Check if we have it in cache
-	if compiled, ok := regexCache[pattern]; ok {
+	regexCacheMutex.RLock()
+	compiled, ok := regexCache[pattern]
+	budget := backtrackingBudget
+	regexCacheMutex.RUnlock()
+	if ok {
 		return compiled, nil
 	}
 
-	// Normalize the pattern
-	normalizedPattern := normalizePattern(pattern)
-
-	// Compile the regex
-	compiled, err := regexp.Compile(normalizedPattern)
+	// Compile the regex, translating it if RE2 rejects it
+	compiled, err := compileTranslated(pattern, budget)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile regex '%s': %v", pattern, err)
 	}
 
-	// Cache it
-	regexCache[pattern] = compiled
+	// Cache it, unless the budget changed meanwhile
+	regexCacheMutex.Lock()
+	if budget == backtrackingBudget {
+		regexCache[pattern] = compiled
+	}
+	regexCacheMutex.Unlock()
 
 	return compiled, nil
 }
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+)
+
+// TagScan holds the tag data collected by ScanTags
+type TagScan struct {
+	// MetaTags maps lowercased meta names to their content
+	MetaTags map[string]string
+	// Scripts contains the external scripts, only Source is set
+	Scripts []models.ScriptPattern
+	// JS contains the variables declared in inline scripts
+	JS map[string]string
+	// Title is the raw text of the first title tag, as ExtractTitle gives it
+	Title string
+}
+
+// ScanTags tokenizes HTML incrementally and collects meta tags, script sources,
+// inline JavaScript variables and the title without keeping the document in
+// memory.
+// No single token may exceed maxTokenSize bytes (0 = no limit); when one does,
+// the data gathered so far is returned together with html.ErrBufferExceeded.
+func ScanTags(r io.Reader, maxTokenSize int) (*TagScan, error) {
+	scan := &TagScan{
+		MetaTags: make(map[string]string),
+		Scripts:  make([]models.ScriptPattern, 0),
+		JS:       make(map[string]string),
+	}
+
+	tokenizer := html.NewTokenizer(r)
+	if maxTokenSize > 0 {
+		tokenizer.SetMaxBuf(maxTokenSize)
+	}
+
+	inScript, inTitle, titled := false, false, false
+	for {
+		switch token := tokenizer.Next(); token {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return scan, err
+			}
+			return scan, nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "meta":
+				attrs := tagAttributes(tokenizer, hasAttr)
+				if attrs["name"] != "" && attrs["content"] != "" {
+					scan.MetaTags[strings.ToLower(attrs["name"])] = attrs["content"]
+				}
+			case "script":
+				attrs := tagAttributes(tokenizer, hasAttr)
+				if attrs["src"] != "" {
+					scan.Scripts = append(scan.Scripts, models.ScriptPattern{Source: attrs["src"]})
+				}
+				inScript = token == html.StartTagToken
+			case "title":
+				// Like browsers, the tokenizer reads <title/> as a start tag
+				inTitle = !titled
+			}
+
+		case html.EndTagToken:
+			if inTitle {
+				titled = true
+			}
+			inScript, inTitle = false, false
+
+		case html.TextToken:
+			if inTitle {
+				scan.Title += string(tokenizer.Raw())
+			}
+			if !inScript {
+				continue
+			}
+
+			// Find variable declarations in the inline script
+			for _, match := range jsVarRegex.FindAllSubmatch(tokenizer.Text(), -1) {
+				if len(match) >= 3 {
+					scan.JS[string(match[1])] = string(match[2])
+				}
+			}
+		}
+	}
+}
+
+// tagAttributes collects the attributes of the current tag with lowercased keys
+func tagAttributes(tokenizer *html.Tokenizer, hasAttr bool) map[string]string {
+	attrs := make(map[string]string)
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = tokenizer.TagAttr()
+		attrs[string(bytes.ToLower(key))] = string(val)
+	}
+	return attrs
+}
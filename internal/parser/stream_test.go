@@ -0,0 +1,79 @@
+package parser_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/net/html"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+func TestScanTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		want    parser.TagScan
+		wantErr error
+	}{
+		{
+			name: "document",
+			html: `<html><head><title>Blog &amp; news</title>` +
+				`<META NAME="Generator" CONTENT="WordPress 6.4.2"><meta name="empty" content="">` +
+				`<script src="/js/jquery-3.7.1.min.js"></script>` +
+				`<script>var appVersion = "1.2.3"; VAR other='x';</script></head>` +
+				`<body><script src=/app.js /><p>var notScript = "no"</p><title>Second</title></body></html>`,
+			want: parser.TagScan{
+				MetaTags: map[string]string{"generator": "WordPress 6.4.2"},
+				Scripts:  []models.ScriptPattern{{Source: "/js/jquery-3.7.1.min.js"}, {Source: "/app.js"}},
+				JS:       map[string]string{"appVersion": "1.2.3", "other": "x"},
+				Title:    "Blog &amp; news",
+			},
+		},
+		{
+			name: "self-closing title, read as browsers do",
+			html: `<title/><p>text</p><title>Real</title>`,
+			want: parser.TagScan{
+				MetaTags: map[string]string{},
+				Scripts:  []models.ScriptPattern{},
+				JS:       map[string]string{},
+				Title:    "<p>text</p><title>Real",
+			},
+		},
+		{
+			name: "truncated",
+			html: `<meta name="generator" content="Hugo"><script>var a = "1`,
+			want: parser.TagScan{
+				MetaTags: map[string]string{"generator": "Hugo"},
+				Scripts:  []models.ScriptPattern{},
+				JS:       map[string]string{},
+			},
+		},
+		{
+			name: "token too large",
+			html: `<meta name="generator" content="Hugo"><script>` + strings.Repeat("x", 4096) + `</script><meta name="late" content="1">`,
+			want: parser.TagScan{
+				MetaTags: map[string]string{"generator": "Hugo"},
+				Scripts:  []models.ScriptPattern{},
+				JS:       map[string]string{},
+			},
+			wantErr: html.ErrBufferExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reading a byte at a time splits every token across reads
+			got, err := parser.ScanTags(iotest.OneByteReader(strings.NewReader(tt.html)), 1024)
+			if err != tt.wantErr {
+				t.Fatalf("ScanTags() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ScanTags() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Upstream patterns are written for JavaScript regular expressions, which
+// support lookarounds and backreferences RE2 rejects. Rather than dropping
+// such patterns, they are translated:
+//
+//   - lookarounds leading or trailing the whole expression are checked at
+//     the start or end of each RE2 match of the rest of the expression
+//   - a hyphen next to a class escape inside brackets, such as [\d.-\w], is
+//     escaped, it is a literal in JavaScript
+//   - anything else is left to a backtracking engine, each match bounded by
+//     a time budget, see SetBacktrackingBudget
+//
+// Lookaround checks look at each leftmost match of the rest of the
+// expression. A first match passing them is the one a backtracking engine
+// finds; when it fails them, the backtracking engine, which may retry a
+// shorter match, evaluates the pattern instead. With the engine disabled the
+// following matches are checked, which may miss a match, but never add one.
+
+// Strategy is how a pattern is evaluated
+type Strategy string
+
+const (
+	// StrategyNative patterns are matched by RE2 as written
+	StrategyNative Strategy = "native"
+	// StrategyRewritten patterns are matched by an equivalent RE2 expression,
+	// possibly with lookarounds checked around each match
+	StrategyRewritten Strategy = "rewritten"
+	// StrategyBacktracking patterns are matched by the backtracking engine
+	StrategyBacktracking Strategy = "backtracking"
+	// StrategyUnsupported patterns can't be evaluated
+	StrategyUnsupported Strategy = "unsupported"
+)
+
+// Translation describes how a pattern is evaluated
+type Translation struct {
+	Strategy Strategy
+	// Expression is the RE2 expression matched, for native and rewritten patterns
+	Expression string
+	// Notes describe the rewrites made, or why a pattern needs the
+	// backtracking engine or is unsupported
+	Notes []string
+}
+
+// String describes the translation on a single line
+func (t Translation) String() string {
+	if len(t.Notes) == 0 {
+		return string(t.Strategy)
+	}
+	return string(t.Strategy) + ": " + strings.Join(t.Notes, "; ")
+}
+
+// lookaround is a lookahead or lookbehind checked around each match
+type lookaround struct {
+	// re matches at the checked position: anchored at its start for
+	// lookaheads, at its end for lookbehinds
+	re *regexp.Regexp
+	// atEnd checks at the end of the match rather than its start
+	atEnd bool
+	// behind checks the text before the position rather than after it
+	behind bool
+	// negate requires re not to match
+	negate bool
+}
+
+// pass reports whether the lookaround holds for a match
+func (l lookaround) pass(s string, start, end int) bool {
+	pos := start
+	if l.atEnd {
+		pos = end
+	}
+
+	var matched bool
+	if l.behind {
+		matched = l.re.MatchString(s[:pos])
+	} else {
+		matched = l.re.MatchString(s[pos:])
+	}
+	return matched != l.negate
+}
+
+// TranslatePattern reports how a pattern is evaluated, using the current
+// backtracking budget
+func TranslatePattern(pattern string) Translation {
+	compiled, err := CompileRegex(pattern)
+	if err != nil {
+		return Translation{Strategy: StrategyUnsupported, Notes: []string{err.Error()}}
+	}
+	return compiled.Translation()
+}
+
+// compileTranslated compiles a pattern with RE2, translating it if RE2
+// rejects it, and falls back to the backtracking engine unless the budget
+// is zero
+func compileTranslated(pattern string, budget time.Duration) (*Regex, error) {
+	normalized := normalizePattern(pattern)
+
+	re, err := regexp.Compile(normalized)
+	if err == nil {
+		return &Regex{re: re, translation: Translation{Strategy: StrategyNative, Expression: normalized}}, nil
+	}
+	re2Err := err
+
+	// Rewrite what RE2 can evaluate
+	var notes []string
+	expression := normalized
+	if fixed, changed := escapeClassHyphens(expression); changed {
+		expression = fixed
+		notes = append(notes, "escaped a literal hyphen next to a class escape in brackets")
+	}
+	if re, err := regexp.Compile(expression); err == nil {
+		return &Regex{re: re, translation: Translation{Strategy: StrategyRewritten, Expression: expression, Notes: notes}}, nil
+	}
+	if compiled, ok := rewriteLookarounds(expression, notes); ok {
+		if budget > 0 {
+			if fallback, err := regexp2.Compile(expression, regexp2.ECMAScript); err == nil {
+				fallback.MatchTimeout = budget
+				compiled.fallback = fallback
+			}
+		}
+		return compiled, nil
+	}
+
+	// Leave the rest to the backtracking engine
+	reason := fmt.Sprintf("RE2 rejects it: %v", unwrapSyntaxError(re2Err))
+	if budget <= 0 {
+		return nil, fmt.Errorf("%s, and the backtracking engine is disabled", reason)
+	}
+	// Upstream patterns are JavaScript expressions, as is the hyphen rewrite
+	backtracking, err := regexp2.Compile(expression, regexp2.ECMAScript)
+	if err != nil {
+		return nil, fmt.Errorf("%s, and the backtracking engine too: %v", reason, err)
+	}
+	backtracking.MatchTimeout = budget
+
+	return &Regex{
+		backtracking: backtracking,
+		translation: Translation{
+			Strategy: StrategyBacktracking,
+			Notes:    []string{fmt.Sprintf("%s, evaluated by the backtracking engine within %v per match", reason, budget)},
+		},
+	}, nil
+}
+
+// unwrapSyntaxError returns the description of a regexp syntax error
+// without the "error parsing regexp" prefix
+func unwrapSyntaxError(err error) string {
+	return strings.TrimPrefix(err.Error(), "error parsing regexp: ")
+}
+
+// rewriteLookarounds rewrites an expression whose lookarounds all lead or
+// trail it into an RE2 expression of the rest, with the lookarounds checked
+// around each match
+func rewriteLookarounds(expression string, notes []string) (*Regex, bool) {
+	// The case insensitivity added by normalization applies to every part
+	flags := ""
+	if strings.HasPrefix(expression, "(?i)") {
+		flags = "(?i)"
+		expression = expression[len(flags):]
+	}
+
+	atoms, ok := splitAtoms(expression)
+	if !ok {
+		return nil, false
+	}
+
+	anchor := ""
+	if len(atoms) > 0 && atoms[0] == "^" {
+		anchor = "^"
+		atoms = atoms[1:]
+	}
+
+	var checks []lookaround
+	addCheck := func(atom string, atEnd bool) bool {
+		check, note, ok := compileLookaround(flags, atom, atEnd)
+		if ok {
+			checks = append(checks, check)
+			notes = append(notes, note)
+		}
+		return ok
+	}
+	for len(atoms) > 0 && isLookaround(atoms[0]) {
+		if !addCheck(atoms[0], false) {
+			return nil, false
+		}
+		atoms = atoms[1:]
+	}
+	for len(atoms) > 0 && isLookaround(atoms[len(atoms)-1]) {
+		if !addCheck(atoms[len(atoms)-1], true) {
+			return nil, false
+		}
+		atoms = atoms[:len(atoms)-1]
+	}
+	if len(checks) == 0 {
+		return nil, false
+	}
+
+	// Lookarounds elsewhere, top level alternations and flag changes would
+	// apply differently once split off
+	for _, atom := range atoms {
+		if atom == "|" || isLookaround(atom) || isFlagGroup(atom) {
+			return nil, false
+		}
+	}
+
+	rest := flags + anchor + strings.Join(atoms, "")
+	re, err := regexp.Compile(rest)
+	if err != nil {
+		return nil, false
+	}
+
+	return &Regex{
+		re:          re,
+		checks:      checks,
+		translation: Translation{Strategy: StrategyRewritten, Expression: rest, Notes: notes},
+	}, true
+}
+
+// compileLookaround compiles a lookaround group into a check
+func compileLookaround(flags, atom string, atEnd bool) (lookaround, string, bool) {
+	var check lookaround
+	var kind, inner string
+	switch {
+	case strings.HasPrefix(atom, "(?="):
+		kind, inner = "lookahead", atom[3:len(atom)-1]
+	case strings.HasPrefix(atom, "(?!"):
+		kind, inner, check.negate = "negative lookahead", atom[3:len(atom)-1], true
+	case strings.HasPrefix(atom, "(?<="):
+		kind, inner, check.behind = "lookbehind", atom[4:len(atom)-1], true
+	case strings.HasPrefix(atom, "(?<!"):
+		kind, inner, check.behind, check.negate = "negative lookbehind", atom[4:len(atom)-1], true, true
+	}
+	check.atEnd = atEnd
+
+	expression := flags + "^(?:" + inner + ")"
+	if check.behind {
+		expression = flags + "(?:" + inner + ")$"
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return check, "", false
+	}
+	check.re = re
+
+	position := "start"
+	if atEnd {
+		position = "end"
+	}
+	return check, fmt.Sprintf("%s %s checked at the %s of each match", kind, atom, position), true
+}
+
+// isLookaround reports whether an atom is a lookahead or lookbehind group
+func isLookaround(atom string) bool {
+	for _, prefix := range []string{"(?=", "(?!", "(?<=", "(?<!"} {
+		if strings.HasPrefix(atom, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFlagGroup reports whether an atom only sets flags, such as (?-i)
+func isFlagGroup(atom string) bool {
+	if !strings.HasPrefix(atom, "(?") || len(atom) < 4 {
+		return false
+	}
+	return strings.Trim(atom[2:len(atom)-1], "imsU-") == ""
+}
+
+// splitAtoms splits an expression into its top level atoms: escapes,
+// character classes, groups, and single characters including operators
+func splitAtoms(expression string) ([]string, bool) {
+	var atoms []string
+	for i := 0; i < len(expression); {
+		end, ok := atomEnd(expression, i)
+		if !ok {
+			return nil, false
+		}
+		atoms = append(atoms, expression[i:end])
+		i = end
+	}
+	return atoms, true
+}
+
+// atomEnd returns the end of the atom starting at i
+func atomEnd(expression string, i int) (int, bool) {
+	switch expression[i] {
+	case '\\':
+		return escapeEnd(expression, i)
+	case '[':
+		return classEnd(expression, i)
+	case '(':
+		for j := i + 1; j < len(expression); {
+			if expression[j] == ')' {
+				return j + 1, true
+			}
+			end, ok := atomEnd(expression, j)
+			if !ok {
+				return 0, false
+			}
+			j = end
+		}
+		return 0, false
+	}
+
+	_, size := utf8.DecodeRuneInString(expression[i:])
+	return i + size, true
+}
+
+// escapeEnd returns the end of the escape sequence starting at i
+func escapeEnd(expression string, i int) (int, bool) {
+	if i+1 >= len(expression) {
+		return 0, false
+	}
+
+	switch c := expression[i+1]; {
+	case (c == 'p' || c == 'P' || c == 'x') && i+2 < len(expression) && expression[i+2] == '{':
+		end := strings.IndexByte(expression[i+2:], '}')
+		if end < 0 {
+			return 0, false
+		}
+		return i + 2 + end + 1, true
+	case c == 'p' || c == 'P':
+		return i + 3, i+3 <= len(expression)
+	}
+
+	_, size := utf8.DecodeRuneInString(expression[i+1:])
+	return i + 1 + size, true
+}
+
+// classEnd returns the end of the character class starting at i
+func classEnd(expression string, i int) (int, bool) {
+	j := i + 1
+	if j < len(expression) && expression[j] == '^' {
+		j++
+	}
+	if j < len(expression) && expression[j] == ']' {
+		j++
+	}
+
+	for j < len(expression) {
+		switch {
+		case expression[j] == ']':
+			return j + 1, true
+		case expression[j] == '\\':
+			end, ok := escapeEnd(expression, j)
+			if !ok {
+				return 0, false
+			}
+			j = end
+		case strings.HasPrefix(expression[j:], "[:"):
+			end := strings.Index(expression[j:], ":]")
+			if end < 0 {
+				j++
+				continue
+			}
+			j += end + 2
+		default:
+			j++
+		}
+	}
+	return 0, false
+}
+
+// isClassEscape reports whether an escape sequence stands for a class of
+// characters rather than a single one
+func isClassEscape(escape string) bool {
+	switch escape {
+	case `\d`, `\D`, `\w`, `\W`, `\s`, `\S`:
+		return true
+	}
+	return strings.HasPrefix(escape, `\p`) || strings.HasPrefix(escape, `\P`)
+}
+
+// escapeClassHyphens escapes the hyphens inside brackets that would form a
+// range with a class escape, which JavaScript reads as literal hyphens
+func escapeClassHyphens(expression string) (string, bool) {
+	var b strings.Builder
+	changed := false
+
+	for i := 0; i < len(expression); {
+		switch expression[i] {
+		case '\\':
+			end, ok := escapeEnd(expression, i)
+			if !ok {
+				return expression, false
+			}
+			b.WriteString(expression[i:end])
+			i = end
+			continue
+		case '[':
+		default:
+			b.WriteByte(expression[i])
+			i++
+			continue
+		}
+
+		end, ok := classEnd(expression, i)
+		if !ok {
+			return expression, false
+		}
+		class := expression[i:end]
+		i = end
+
+		// Split the class into its items and escape hyphens next to a class escape
+		start := 1
+		if strings.HasPrefix(class, "[^") {
+			start = 2
+		}
+		var items []string
+		for j := start; j < len(class)-1; {
+			itemEnd := j + 1
+			if class[j] == '\\' {
+				if itemEnd, ok = escapeEnd(class, j); !ok {
+					return expression, false
+				}
+			}
+			items = append(items, class[j:itemEnd])
+			j = itemEnd
+		}
+
+		b.WriteString(class[:start])
+		for k, item := range items {
+			if item == "-" && k > 0 && k < len(items)-1 && (isClassEscape(items[k-1]) || isClassEscape(items[k+1])) {
+				b.WriteString(`\-`)
+				changed = true
+				continue
+			}
+			b.WriteString(item)
+		}
+		b.WriteString("]")
+	}
+
+	return b.String(), changed
+}
@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+// ecmaScript compiles an expression with the backtracking engine in
+// JavaScript mode, the reference the translations are checked against
+func ecmaScript(t *testing.T, expression string) *regexp2.Regexp {
+	t.Helper()
+
+	re, err := regexp2.Compile(expression, regexp2.ECMAScript)
+	if err != nil {
+		t.Fatalf("regexp2.Compile(%q) error = %v", expression, err)
+	}
+	return re
+}
+
+// ecmaScriptSubmatch returns the groups of the first match of re in s, as
+// Regex.FindStringSubmatch does
+func ecmaScriptSubmatch(re *regexp2.Regexp, s string) []string {
+	match, err := re.FindStringMatch(s)
+	if err != nil || match == nil {
+		return nil
+	}
+	var groups []string
+	for _, group := range match.Groups() {
+		groups = append(groups, group.String())
+	}
+	return groups
+}
+
+func TestEscapeClassHyphens(t *testing.T) {
+	tests := []struct {
+		expression  string
+		want        string
+		wantChanged bool
+		inputs      []string
+	}{
+		{`[\d.-\w]+`, `[\d.\-\w]+`, true, []string{"1.2-a", "-", "..", "#"}},
+		{`[\w-]+`, `[\w-]+`, false, []string{"a-b", "-", "#"}},
+		{`[-\d]`, `[-\d]`, false, []string{"-", "5", "a"}},
+		{`[a-z\d-\s]`, `[a-z\d\-\s]`, true, []string{"q", "-", " ", "7", "#"}},
+		{`[^\s-\d]+`, `[^\s\-\d]+`, true, []string{"ab", "-", "1", " "}},
+		{`[a-z]-\d`, `[a-z]-\d`, false, []string{"a-1", "a1"}},
+		{`\[\d-\w\]`, `\[\d-\w\]`, false, []string{`[1-a]`, `[1a]`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, changed := escapeClassHyphens(tt.expression)
+			if got != tt.want || changed != tt.wantChanged {
+				t.Fatalf("escapeClassHyphens() = %q, %v, want %q, %v", got, changed, tt.want, tt.wantChanged)
+			}
+
+			// RE2 reads the result as JavaScript does
+			compiled, err := compileTranslated(tt.expression, DefaultBacktrackingBudget)
+			if err != nil {
+				t.Fatalf("compileTranslated() error = %v", err)
+			}
+			reference := ecmaScript(t, "(?i)"+got)
+			for _, input := range tt.inputs {
+				want, _ := reference.MatchString(input)
+				if got := compiled.MatchString(input); got != want {
+					t.Errorf("MatchString(%q) = %v, regexp2 says %v", input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteLookarounds(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		rewritten bool
+		inputs    []string
+	}{
+		{`foo(?=bar)`, true, []string{"foobar", "foobaz", "xfoobar"}},
+		{`foo(?!bar)`, true, []string{"foobar", "foobaz", "foo"}},
+		{`(?<=a)b(\d+)`, true, []string{"ab12", "cb12", "b12 ab3"}},
+		{`(?<!a)b(\d+)`, true, []string{"ab12", "cb12", "ab1 cb2", "b9"}},
+		{`^(?!www\.)[\w.]+\.example\.com`, true, []string{"www.example.com", "api.example.com", "www.x.example.com"}},
+		{`(?<=/)jquery[.-]([\d.]+)(?=\.min\.js)`, true, []string{"/jquery-3.6.0.min.js", "/jquery-3.6.0.js", "jquery-1.0.min.js"}},
+		{`foo(\d+)(?!x)`, true, []string{"foo12x", "foo12y", "foo1x foo2"}},
+		{`a(?=b)|c`, false, []string{"ab", "c", "a"}},
+		{`a(?=b)c`, false, []string{"abc", "ac"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			normalized := normalizePattern(tt.pattern)
+			if _, ok := rewriteLookarounds(normalized, nil); ok != tt.rewritten {
+				t.Fatalf("rewriteLookarounds() ok = %v, want %v", ok, tt.rewritten)
+			}
+			compiled, err := compileTranslated(tt.pattern, DefaultBacktrackingBudget)
+			if err != nil {
+				t.Fatalf("compileTranslated() error = %v", err)
+			}
+			if rewritten := compiled.Translation().Strategy == StrategyRewritten; rewritten != tt.rewritten {
+				t.Fatalf("strategy = %s, want rewritten %v", compiled.Translation().Strategy, tt.rewritten)
+			}
+
+			// Without the backtracking engine to retry shorter matches,
+			// checks may miss a match but never add one
+			unassisted, err := compileTranslated(tt.pattern, 0)
+			if tt.rewritten && err != nil {
+				t.Fatalf("compileTranslated() without budget error = %v", err)
+			}
+
+			reference := ecmaScript(t, normalized)
+			for _, input := range tt.inputs {
+				want, _ := reference.MatchString(input)
+				if got := compiled.MatchString(input); got != want {
+					t.Errorf("MatchString(%q) = %v, regexp2 says %v", input, got, want)
+				}
+				if got, want := compiled.FindStringSubmatch(input), ecmaScriptSubmatch(reference, input); !reflect.DeepEqual(got, want) {
+					t.Errorf("FindStringSubmatch(%q) = %q, regexp2 says %q", input, got, want)
+				}
+				if unassisted != nil && unassisted.MatchString(input) && !want {
+					t.Errorf("MatchString(%q) without budget = true, regexp2 says false", input)
+				}
+			}
+		})
+	}
+}
+
+func TestLookaroundPass(t *testing.T) {
+	tests := []struct {
+		atom       string
+		atEnd      bool
+		s          string
+		start, end int
+	}{
+		{`(?=bar)`, true, "foobar", 0, 3},
+		{`(?=bar)`, true, "foobaz", 0, 3},
+		{`(?!bar)`, true, "foobar", 0, 3},
+		{`(?!bar)`, true, "foo", 0, 3},
+		{`(?<=/)`, false, "/jquery", 1, 7},
+		{`(?<=/)`, false, "jquery", 0, 6},
+		{`(?<!www\.)`, false, "www.example", 4, 11},
+		{`(?<!www\.)`, false, "api.example", 4, 11},
+		{`(?=\d{2})`, false, "12ab", 0, 2},
+		{`(?<=ab)`, true, "xxab", 2, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.atom+" "+tt.s, func(t *testing.T) {
+			check, _, ok := compileLookaround("(?i)", tt.atom, tt.atEnd)
+			if !ok {
+				t.Fatalf("compileLookaround(%q) failed", tt.atom)
+			}
+
+			// The same lookaround, run by regexp2 at the checked position
+			pos := tt.start
+			if tt.atEnd {
+				pos = tt.end
+			}
+			match, err := ecmaScript(t, "(?i)"+tt.atom).FindStringMatchStartingAt(tt.s, pos)
+			want := err == nil && match != nil && match.Index == pos
+
+			if got := check.pass(tt.s, tt.start, tt.end); got != want {
+				t.Errorf("pass() = %v, regexp2 says %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBacktrackingEngine(t *testing.T) {
+	tests := []struct {
+		pattern string
+		inputs  []string
+	}{
+		{`(\w+)-\1`, []string{"abc-abc", "abc-abd", "x a-a"}},
+		{`(?<=(\d))x\1`, []string{"1x1", "1x2", "x1"}},
+		{`[\d.-\w]+(\d)\1`, []string{"a-b11", "a-b12"}},
+		{`(?-i)Foo(?=Bar)`, []string{"FooBar", "fooBar"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			compiled, err := compileTranslated(tt.pattern, DefaultBacktrackingBudget)
+			if err != nil {
+				t.Fatalf("compileTranslated() error = %v", err)
+			}
+			expression := tt.pattern
+			if !strings.Contains(expression, "(?-i)") {
+				expression = "(?i)" + expression
+			}
+			expression, _ = escapeClassHyphens(expression)
+			reference := ecmaScript(t, expression)
+
+			for _, input := range tt.inputs {
+				want, _ := reference.MatchString(input)
+				if got := compiled.MatchString(input); got != want {
+					t.Errorf("MatchString(%q) = %v, regexp2 says %v", input, got, want)
+				}
+				if got, want := compiled.FindStringSubmatch(input), ecmaScriptSubmatch(reference, input); !reflect.DeepEqual(got, want) {
+					t.Errorf("FindStringSubmatch(%q) = %q, regexp2 says %q", input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBacktrackingBudget(t *testing.T) {
+	// Exponential for a backtracking engine
+	const pattern = `^(\w+)+\1$`
+	input := strings.Repeat("a", 40) + "!"
+
+	if _, err := compileTranslated(pattern, 0); err == nil {
+		t.Error("compileTranslated() without budget succeeded, want an error")
+	}
+
+	compiled, err := compileTranslated(pattern, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("compileTranslated() error = %v", err)
+	}
+	if strategy := compiled.Translation().Strategy; strategy != StrategyBacktracking {
+		t.Fatalf("strategy = %s, want %s", strategy, StrategyBacktracking)
+	}
+
+	start := time.Now()
+	if compiled.MatchString(input) || compiled.FindStringSubmatch(input) != nil {
+		t.Error("a match running out of budget counts as a match")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("matching took %v despite the budget", elapsed)
+	}
+	if !compiled.MatchString("abab") {
+		t.Error("MatchString(abab) = false within the budget")
+	}
+}
@@ -0,0 +1,265 @@
+// Package vectors runs the test vectors a technology can carry in its tests
+// field: sample inputs it must, or must not, be detected on, with the
+// version it must be detected with. Each vector is turned into a synthetic
+// response and run through the real compiler and matchers.
+//
+// A technology with tests looks like:
+//
+//	"Nginx": {
+//	  "headers": {"Server": "nginx(?:/([\\d.]+))?\\;version:\\1"},
+//	  "tests": [
+//	    {"headers": {"Server": "nginx/1.24.0"}, "version": "1.24.0"},
+//	    {"headers": {"Server": "Apache"}, "match": false}
+//	  ]
+//	}
+//
+// The inputs are headers, cookies, html, meta and scriptSrc. match defaults
+// to true, and the version is only checked when given, an empty one
+// expecting no version.
+package vectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// Field is the technology field holding the test vectors
+const Field = "tests"
+
+// Vector is a sample input of a technology and the detection it must give
+type Vector struct {
+	Headers   map[string]string `json:"headers,omitempty"`
+	Cookies   map[string]string `json:"cookies,omitempty"`
+	HTML      StringList        `json:"html,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	ScriptSrc StringList        `json:"scriptSrc,omitempty"`
+
+	// Match tells whether the technology must be detected, true when unset
+	Match *bool `json:"match,omitempty"`
+
+	// Version is the version the technology must be detected with, not
+	// checked when unset
+	Version *string `json:"version,omitempty"`
+}
+
+// StringList is a string or a list of strings
+type StringList []string
+
+// UnmarshalJSON accepts a single string as well as a list
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*l = StringList{s}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+	*l = list
+	return nil
+}
+
+// ExpectsMatch reports whether the technology must be detected
+func (v *Vector) ExpectsMatch() bool {
+	return v.Match == nil || *v.Match
+}
+
+// validate checks that the vector has an input and a consistent expectation
+func (v *Vector) validate() error {
+	if len(v.Headers) == 0 && len(v.Cookies) == 0 && len(v.HTML) == 0 && len(v.Meta) == 0 && len(v.ScriptSrc) == 0 {
+		return fmt.Errorf("no input")
+	}
+	if v.Version != nil && !v.ExpectsMatch() {
+		return fmt.Errorf("version given but no match expected")
+	}
+	for name, content := range v.Meta {
+		if strings.Contains(content, `"`) && strings.Contains(content, "'") {
+			return fmt.Errorf("meta %s has both quote kinds", name)
+		}
+	}
+	return nil
+}
+
+// Response builds the synthetic response of the vector: the headers, the
+// cookies in a Cookie header, and a body with the html, followed by a meta
+// tag per meta entry and a script tag per scriptSrc, in a stable order
+func (v *Vector) Response() (map[string][]string, []byte) {
+	headers := make(map[string][]string, len(v.Headers)+1)
+	for name, value := range v.Headers {
+		headers[name] = append(headers[name], value)
+	}
+
+	if len(v.Cookies) > 0 {
+		pairs := make([]string, 0, len(v.Cookies))
+		for name, value := range v.Cookies {
+			pairs = append(pairs, name+"="+value)
+		}
+		sort.Strings(pairs)
+		headers["Cookie"] = append(headers["Cookie"], strings.Join(pairs, "; "))
+	}
+
+	var body bytes.Buffer
+	for _, fragment := range v.HTML {
+		body.WriteString(fragment)
+		body.WriteByte('\n')
+	}
+
+	names := make([]string, 0, len(v.Meta))
+	for name := range v.Meta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Patterns see the raw attribute, so the content is quoted, not escaped
+		content, quote := v.Meta[name], `"`
+		if strings.Contains(content, `"`) {
+			quote = "'"
+		}
+		fmt.Fprintf(&body, "<meta name=\"%s\" content=%s%s%s>\n", html.EscapeString(name), quote, content, quote)
+	}
+
+	for _, src := range v.ScriptSrc {
+		fmt.Fprintf(&body, "<script src=\"%s\"></script>\n", strings.ReplaceAll(src, `"`, "%22"))
+	}
+
+	return headers, body.Bytes()
+}
+
+// Parse reads the test vectors of a technology. It returns nil when the
+// technology has no tests field, and an error when a vector is malformed,
+// has unknown fields or no input.
+func Parse(technology json.RawMessage) ([]Vector, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(technology, &fields); err != nil {
+		return nil, fmt.Errorf("technology is not a JSON object")
+	}
+	data, ok := fields[Field]
+	if !ok {
+		return nil, nil
+	}
+	return Decode(data)
+}
+
+// Decode reads the value of a tests field
+func Decode(data json.RawMessage) ([]Vector, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("expected a list of tests")
+	}
+
+	vectors := make([]Vector, len(raw))
+	for i, data := range raw {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&vectors[i]); err != nil {
+			return nil, fmt.Errorf("tests[%d]: %s", i, strings.TrimPrefix(err.Error(), "json: "))
+		}
+		if err := vectors[i].validate(); err != nil {
+			return nil, fmt.Errorf("tests[%d]: %v", i, err)
+		}
+	}
+	return vectors, nil
+}
+
+// Detector returns the technologies detected on a response with their
+// versions, empty when unknown
+type Detector func(headers map[string][]string, body []byte) map[string]string
+
+// Failure is a test vector whose detection differs from the expected one
+type Failure struct {
+	Test    int    `json:"test"`
+	Message string `json:"message"`
+}
+
+// String formats the failure with the index of the vector
+func (f Failure) String() string {
+	return fmt.Sprintf("tests[%d]: %s", f.Test, f.Message)
+}
+
+// Result is the outcome of the test vectors of a technology
+type Result struct {
+	Technology string    `json:"technology"`
+	Tests      int       `json:"tests"`
+	Error      string    `json:"error,omitempty"`
+	Failures   []Failure `json:"failures,omitempty"`
+}
+
+// Passed reports whether the vectors are valid and all of them passed
+func (r *Result) Passed() bool {
+	return r.Error == "" && len(r.Failures) == 0
+}
+
+// Report lists the results of the technologies with tests, sorted by name
+type Report struct {
+	Technologies int      `json:"technologies"`
+	Tests        int      `json:"tests"`
+	Failed       int      `json:"failed"`
+	Results      []Result `json:"results"`
+}
+
+// OK reports whether every technology passed its tests
+func (r *Report) OK() bool {
+	for i := range r.Results {
+		if !r.Results[i].Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs the test vectors of technologies, keyed by name, through detect,
+// which must be built from the same technologies. Technologies without
+// tests are left out of the report.
+func Run(technologies map[string]json.RawMessage, detect Detector) *Report {
+	names := make([]string, 0, len(technologies))
+	for name := range technologies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &Report{Results: []Result{}}
+	for _, name := range names {
+		vectors, err := Parse(technologies[name])
+		if err == nil && vectors == nil {
+			continue
+		}
+
+		result := Result{Technology: name, Tests: len(vectors)}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		for i := range vectors {
+			if message := check(&vectors[i], name, detect); message != "" {
+				result.Failures = append(result.Failures, Failure{Test: i, Message: message})
+			}
+		}
+
+		report.Technologies++
+		report.Tests += result.Tests
+		report.Failed += len(result.Failures)
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// check runs a vector and describes how its detection differs from the
+// expected one, or returns an empty string when it passes
+func check(vector *Vector, technology string, detect Detector) string {
+	version, detected := detect(vector.Response())[technology]
+
+	switch {
+	case !vector.ExpectsMatch() && detected:
+		return "detected, expected no match"
+	case vector.ExpectsMatch() && !detected:
+		return "not detected"
+	case detected && vector.Version != nil && version != *vector.Version:
+		return fmt.Sprintf("version %q, expected %q", version, *vector.Version)
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package wappalyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+var (
+	// defaultMutex guards defaultInstance
+	defaultMutex sync.Mutex
+	// defaultInstance backs the package level category and group getters
+	defaultInstance *Wappalyze
+)
+
+// catalog holds the categories and groups of a fingerprint set
+type catalog struct {
+	categories       map[int]CategoryItem
+	groups           map[int]GroupItem
+	categoryToGroups map[int][]int // Mapping from category ID to group IDs
+	groupCategories  map[int][]int // Mapping from group ID to category IDs
+}
+
+// newCatalog creates an empty catalog
+func newCatalog() *catalog {
+	return &catalog{
+		categories:       make(map[int]CategoryItem),
+		groups:           make(map[int]GroupItem),
+		categoryToGroups: make(map[int][]int),
+		groupCategories:  make(map[int][]int),
+	}
+}
+
+// parseCatalog builds a catalog from raw categories.json and groups.json data
+func parseCatalog(categoriesData, groupsData map[string]json.RawMessage) *catalog {
+	c := newCatalog()
+
+	// Process categories data
+	for categoryIDStr, rawData := range categoriesData {
+		var categoryData map[string]json.RawMessage
+		if err := json.Unmarshal(rawData, &categoryData); err != nil {
+			continue
+		}
+
+		category := CategoryItem{}
+
+		// Extract name
+		if nameData, ok := categoryData["name"]; ok {
+			json.Unmarshal(nameData, &category.Name)
+		}
+
+		// Extract priority
+		if priorityData, ok := categoryData["priority"]; ok {
+			json.Unmarshal(priorityData, &category.Priority)
+		}
+
+		// Extract groups
+		if groupsData, ok := categoryData["groups"]; ok {
+			var groups []int
+			json.Unmarshal(groupsData, &groups)
+			category.Groups = groups
+		}
+
+		// Convert category ID to int
+		categoryID, err := strconv.Atoi(categoryIDStr)
+		if err != nil {
+			continue
+		}
+
+		c.addCategory(categoryID, category)
+	}
+
+	// Process groups data
+	for groupIDStr, rawData := range groupsData {
+		var group GroupItem
+		if err := json.Unmarshal(rawData, &group); err != nil {
+			continue
+		}
+
+		groupID, err := strconv.Atoi(groupIDStr)
+		if err != nil {
+			continue
+		}
+
+		c.groups[groupID] = group
+	}
+
+	return c
+}
+
+// parseCatalogJSON builds a catalog from categories.json and groups.json
+// contents, either of which may be empty
+func parseCatalogJSON(categoriesJSON, groupsJSON []byte) (*catalog, error) {
+	var categoriesData, groupsData map[string]json.RawMessage
+	if len(categoriesJSON) > 0 {
+		if err := json.Unmarshal(categoriesJSON, &categoriesData); err != nil {
+			return nil, fmt.Errorf("could not decode categories: %v", err)
+		}
+	}
+	if len(groupsJSON) > 0 {
+		if err := json.Unmarshal(groupsJSON, &groupsData); err != nil {
+			return nil, fmt.Errorf("could not decode groups: %v", err)
+		}
+	}
+	return parseCatalog(categoriesData, groupsData), nil
+}
+
+// addCategory stores a category and indexes its groups
+func (c *catalog) addCategory(categoryID int, category CategoryItem) {
+	c.categories[categoryID] = category
+
+	// Build category to groups mapping
+	if len(category.Groups) > 0 {
+		c.categoryToGroups[categoryID] = category.Groups
+
+		// Also build reverse mapping (group to categories)
+		for _, groupID := range category.Groups {
+			c.groupCategories[groupID] = append(c.groupCategories[groupID], categoryID)
+		}
+	}
+}
+
+// Categories returns the categories of the instance's fingerprint set
+func (w *Wappalyze) Categories() map[int]CategoryItem {
+	return w.current().catalog.categories
+}
+
+// Groups returns the groups of the instance's fingerprint set
+func (w *Wappalyze) Groups() map[int]GroupItem {
+	return w.current().catalog.groups
+}
+
+// CategoryGroups returns the groups associated with a category
+func (w *Wappalyze) CategoryGroups(categoryID int) []int {
+	return w.current().catalog.categoryToGroups[categoryID]
+}
+
+// GroupCategories returns the categories associated with a group
+func (w *Wappalyze) GroupCategories(groupID int) []int {
+	return w.current().catalog.groupCategories[groupID]
+}
+
+// defaultWappalyze returns the instance backing the package level getters,
+// creating it with the global downloader configuration on first use
+func defaultWappalyze() (*Wappalyze, error) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	if defaultInstance == nil {
+		w, err := New()
+		if err != nil {
+			return nil, err
+		}
+		defaultInstance = w
+	}
+	return defaultInstance, nil
+}
+
+// GetCategoriesMapping returns the categories mapping of the default instance
+func GetCategoriesMapping() map[int]CategoryItem {
+	w, err := defaultWappalyze()
+	if err != nil {
+		return nil
+	}
+	return w.Categories()
+}
+
+// GetGroupsMapping returns the groups mapping of the default instance
+func GetGroupsMapping() map[int]GroupItem {
+	w, err := defaultWappalyze()
+	if err != nil {
+		return nil
+	}
+	return w.Groups()
+}
+
+// GetCategoryGroups returns the groups associated with a category in the default instance
+func GetCategoryGroups(categoryID int) []int {
+	w, err := defaultWappalyze()
+	if err != nil {
+		return nil
+	}
+	return w.CategoryGroups(categoryID)
+}
+
+// GetGroupCategories returns the categories associated with a group in the default instance
+func GetGroupCategories(groupID int) []int {
+	w, err := defaultWappalyze()
+	if err != nil {
+		return nil
+	}
+	return w.GroupCategories(groupID)
+}
@@ -4,6 +4,21 @@ package wappalyzer
 type Config struct {
 	// JSON contains the fingerprints data, if provided directly
 	JSON []byte
+	// Categories contains categories.json data, if provided directly
+	Categories []byte
+	// Groups contains groups.json data, if provided directly
+	Groups []byte
+	// Database contains a compiled fingerprint database, if provided directly
+	Database []byte
+	// DatabasePath is the path of a compiled fingerprint database to load
+	DatabasePath string
+	// Snapshot is a bundled set of fingerprints used when downloading fails
+	Snapshot *Snapshot
+	// ForceSnapshot uses Snapshot without trying to download fingerprints first
+	ForceSnapshot bool
+	// Sources are fingerprint sources merged in increasing order of precedence,
+	// replacing the downloader's sources
+	Sources []Source
 	// DisableJSDetection disables JavaScript pattern detection
 	DisableJSDetection bool
 	// DisableHTMLDetection disables HTML pattern detection
@@ -16,8 +31,17 @@ type Config struct {
 	DisableMetaDetection bool
 	// DisableScriptDetection disables script tag detection
 	DisableScriptDetection bool
-	// MaxBodySize limits the maximum body size to scan
+	// MaxBodySize limits how many bytes of the decoded body are scanned, after
+	// Content-Encoding and charset decoding. Streaming reads stop there, so a
+	// compressed body is only read as far as needed. The AnalyzeURL methods
+	// also read at most MaxBodySize bytes of the response body as sent.
 	MaxBodySize int
+	// DisableBodyDecoding disables Content-Encoding and charset decoding of the body
+	DisableBodyDecoding bool
+	// StreamWindowSize is the overlap kept between chunks by FingerprintReader
+	StreamWindowSize int
+	// OnReloadError is called when a background reload fails
+	OnReloadError func(error)
 }
 
 // Option is a function that configures the wappalyzer client
@@ -30,7 +54,62 @@ func WithCustomFingerprints(fingerprints []byte) Option {
 	}
 }
 
-// WithMaxBodySize sets the maximum body size to scan
+// WithDatabase loads the fingerprints, categories and groups from a compiled
+// database instead of downloading and compiling them
+func WithDatabase(data []byte) Option {
+	return func(c *Config) {
+		c.Database = data
+	}
+}
+
+// WithDatabaseFile loads the fingerprints, categories and groups from a
+// compiled database file instead of downloading and compiling them
+func WithDatabaseFile(path string) Option {
+	return func(c *Config) {
+		c.DatabasePath = path
+	}
+}
+
+// WithSnapshot uses a bundled snapshot of fingerprints, never downloading anything
+func WithSnapshot(snapshot *Snapshot) Option {
+	return func(c *Config) {
+		c.Snapshot = snapshot
+		c.ForceSnapshot = true
+	}
+}
+
+// WithSnapshotFallback uses a bundled snapshot of fingerprints only when
+// downloading them fails
+func WithSnapshotFallback(snapshot *Snapshot) Option {
+	return func(c *Config) {
+		c.Snapshot = snapshot
+		c.ForceSnapshot = false
+	}
+}
+
+// WithSources merges fingerprints from several sources, in increasing order
+// of precedence, instead of downloading the release only
+func WithSources(sources ...Source) Option {
+	return func(c *Config) {
+		c.Sources = sources
+	}
+}
+
+// WithCustomCategories sets custom categories data, in the categories.json format
+func WithCustomCategories(categories []byte) Option {
+	return func(c *Config) {
+		c.Categories = categories
+	}
+}
+
+// WithCustomGroups sets custom groups data, in the groups.json format
+func WithCustomGroups(groups []byte) Option {
+	return func(c *Config) {
+		c.Groups = groups
+	}
+}
+
+// WithMaxBodySize sets how many bytes of the decoded body are scanned
 func WithMaxBodySize(size int) Option {
 	return func(c *Config) {
 		c.MaxBodySize = size
@@ -79,6 +158,30 @@ func WithoutScriptDetection() Option {
 	}
 }
 
+// WithoutBodyDecoding disables Content-Encoding and charset decoding of the body,
+// for callers that already hand over decoded UTF-8 bodies
+func WithoutBodyDecoding() Option {
+	return func(c *Config) {
+		c.DisableBodyDecoding = true
+	}
+}
+
+// WithStreamWindowSize sets the overlap kept between chunks by FingerprintReader,
+// which is the longest body match it is guaranteed to find
+func WithStreamWindowSize(size int) Option {
+	return func(c *Config) {
+		c.StreamWindowSize = size
+	}
+}
+
+// WithReloadErrorHandler sets the function called when a background reload
+// started with AutoReload fails. The instance keeps its previous fingerprints.
+func WithReloadErrorHandler(handler func(error)) Option {
+	return func(c *Config) {
+		c.OnReloadError = handler
+	}
+}
+
 // WithAllDetections enables all detection methods
 func WithAllDetections() Option {
 	return func(c *Config) {
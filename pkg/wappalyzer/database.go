@@ -0,0 +1,91 @@
+package wappalyzer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/database"
+)
+
+// engineFromDatabase creates an engine from a compiled database
+func engineFromDatabase(config *Config) (*engine, error) {
+	data := config.Database
+	if data == nil {
+		var err error
+		data, err = os.ReadFile(config.DatabasePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compiled database: %v", err)
+		}
+	}
+
+	db, err := database.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not load compiled database: %v", err)
+	}
+
+	categories, err := customCatalog(config, catalogFromDatabase(db))
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := newEngine(config, db.Compiled(), categories, db)
+	if err != nil {
+		return nil, err
+	}
+	e.info = DataInfo{UpdatedAt: db.CreatedAt}
+
+	return e, nil
+}
+
+// catalogFromDatabase builds a catalog from the categories and groups of a compiled database
+func catalogFromDatabase(db *database.Database) *catalog {
+	c := newCatalog()
+
+	for categoryID, category := range db.Categories {
+		c.addCategory(categoryID, CategoryItem{
+			Name:     category.Name,
+			Priority: category.Priority,
+			Groups:   category.Groups,
+		})
+	}
+
+	for groupID, group := range db.Groups {
+		c.groups[groupID] = GroupItem{Name: group.Name}
+	}
+
+	return c
+}
+
+// WriteDatabase writes the compiled fingerprints of the instance, the
+// prefilter indexes and the categories and groups as a compiled database,
+// which can later be loaded with WithDatabase or WithDatabaseFile.
+// Source is recorded in the database to describe where the fingerprints came from.
+func (w *Wappalyze) WriteDatabase(out io.Writer, source string) error {
+	e := w.current()
+
+	db := &database.Database{
+		CreatedAt:    time.Now().UTC(),
+		Source:       source,
+		Technologies: database.FromCompiled(e.fingerprints),
+		Categories:   make(map[int]database.Category, len(e.catalog.categories)),
+		Groups:       make(map[int]database.Group, len(e.catalog.groups)),
+		HTMLIndex:    e.htmlMatcher.Index(),
+		ScriptIndex:  e.scriptMatcher.Index(),
+	}
+
+	for categoryID, category := range e.catalog.categories {
+		db.Categories[categoryID] = database.Category{
+			Name:     category.Name,
+			Priority: category.Priority,
+			Groups:   category.Groups,
+		}
+	}
+	for groupID, group := range e.catalog.groups {
+		db.Groups[groupID] = database.Group{Name: group.Name}
+	}
+
+	return database.Encode(out, db)
+}
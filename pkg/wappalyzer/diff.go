@@ -0,0 +1,86 @@
+package wappalyzer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/corpus"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/diff"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+)
+
+// DiffReport lists the differences between two fingerprint sets, see Diff
+type DiffReport = diff.Report
+
+// TechnologyChange lists the changed fields of a technology
+type TechnologyChange = diff.TechnologyChange
+
+// FieldChange lists the values added to and removed from a technology field
+type FieldChange = diff.FieldChange
+
+// RecordedResponse is a recorded HTTP response, with the technologies it is
+// expected to be detected as, see LoadCorpus
+type RecordedResponse = corpus.Response
+
+// DetectionChange lists the technologies only one of two instances detects
+// on a recorded response
+type DetectionChange struct {
+	Response    string   `json:"response"`
+	Appeared    []string `json:"appeared,omitempty"`
+	Disappeared []string `json:"disappeared,omitempty"`
+}
+
+// Diff compares two technology sets, in either the object or the array
+// format, and reports the technologies added, removed and changed, with
+// the patterns, categories and implied technologies changed per field
+func Diff(oldTechnologiesJSON, newTechnologiesJSON []byte) (*DiffReport, error) {
+	oldTechnologies, err := downloader.ParseTechnologies(oldTechnologiesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse old technologies: %v", err)
+	}
+	newTechnologies, err := downloader.ParseTechnologies(newTechnologiesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new technologies: %v", err)
+	}
+
+	return diff.Compare(oldTechnologies, newTechnologies)
+}
+
+// LoadCorpus reads the recorded responses below dir: raw HTTP/1.x responses,
+// status line, headers and body, in files with the .http extension, and
+// their expected detections, in .expected.json files next to them
+func LoadCorpus(dir string) ([]*RecordedResponse, error) {
+	return corpus.Load(dir)
+}
+
+// CompareDetections replays recorded responses through two instances and
+// reports the technologies that appear or disappear with the new one.
+// Responses detected the same way by both are left out.
+func CompareDetections(oldInstance, newInstance *Wappalyze, responses []*RecordedResponse) []DetectionChange {
+	changes := []DetectionChange{}
+
+	for _, response := range responses {
+		oldTechnologies := oldInstance.Fingerprint(response.Headers, response.Body)
+		newTechnologies := newInstance.Fingerprint(response.Headers, response.Body)
+
+		change := DetectionChange{Response: response.Name}
+		for name := range newTechnologies {
+			if _, ok := oldTechnologies[name]; !ok {
+				change.Appeared = append(change.Appeared, name)
+			}
+		}
+		for name := range oldTechnologies {
+			if _, ok := newTechnologies[name]; !ok {
+				change.Disappeared = append(change.Disappeared, name)
+			}
+		}
+
+		if len(change.Appeared) > 0 || len(change.Disappeared) > 0 {
+			sort.Strings(change.Appeared)
+			sort.Strings(change.Disappeared)
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
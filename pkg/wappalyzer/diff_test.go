@@ -0,0 +1,100 @@
+package wappalyzer_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// changedFixture writes the fixture fingerprints to a directory, with Nginx
+// replaced by a technology detected from the same header
+func changedFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"categories.json", "groups.json"} {
+		data, err := os.ReadFile(filepath.Join(fixtureDir, name))
+		if err != nil {
+			t.Fatalf("could not read the fixture: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("could not write the fixture: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(fixtureDir, "technologies.json"))
+	if err != nil {
+		t.Fatalf("could not read the fixture: %v", err)
+	}
+	var technologies map[string]json.RawMessage
+	if err := json.Unmarshal(data, &technologies); err != nil {
+		t.Fatalf("could not decode the fixture: %v", err)
+	}
+	delete(technologies, "Nginx")
+	technologies["OpenResty"] = json.RawMessage(`{"cats": [22], "headers": {"Server": "nginx"}, "website": "https://openresty.org"}`)
+
+	if data, err = json.Marshal(technologies); err != nil {
+		t.Fatalf("could not encode the fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "technologies.json"), data, 0644); err != nil {
+		t.Fatalf("could not write the fixture: %v", err)
+	}
+	return dir
+}
+
+// TestCompareDetections replays the corpus through the fixture and a changed
+// copy of it
+func TestCompareDetections(t *testing.T) {
+	oldInstance := newFixtureInstance(t)
+	newInstance, err := wappalyzer.New(wappalyzer.WithAllDetections(), wappalyzer.WithSources(wappalyzer.Source{Dir: changedFixture(t)}))
+	if err != nil {
+		t.Fatalf("could not load the changed fixture: %v", err)
+	}
+
+	responses, err := wappalyzer.LoadCorpus(corpusDir)
+	if err != nil {
+		t.Fatalf("LoadCorpus() error = %v", err)
+	}
+
+	changes := wappalyzer.CompareDetections(oldInstance, newInstance, responses)
+	want := []wappalyzer.DetectionChange{
+		{Response: "nginx-php", Appeared: []string{"OpenResty"}, Disappeared: []string{"Nginx"}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("CompareDetections() = %+v, want %+v", changes, want)
+	}
+
+	if changes := wappalyzer.CompareDetections(oldInstance, oldInstance, responses); len(changes) != 0 {
+		t.Errorf("CompareDetections() of an instance with itself = %+v", changes)
+	}
+}
+
+// TestDiffFormats checks that Diff accepts both technology set formats
+func TestDiffFormats(t *testing.T) {
+	oldJSON := []byte(`{"Nginx": {"cats": [22], "headers": {"Server": "nginx"}}}`)
+	newJSON := []byte(`[{"name": "Nginx", "cats": [22, 64], "headers": {"Server": "nginx"}}, {"name": "Caddy", "cats": [22]}]`)
+
+	report, err := wappalyzer.Diff(oldJSON, newJSON)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !reflect.DeepEqual(report.Added, []string{"Caddy"}) || len(report.Removed) != 0 {
+		t.Errorf("Diff() added %v, removed %v", report.Added, report.Removed)
+	}
+	if len(report.Changed) != 1 || report.Changed[0].Name != "Nginx" {
+		t.Fatalf("Diff() changed = %+v, want Nginx", report.Changed)
+	}
+
+	// The array format carries the name, which is not a change
+	want := []wappalyzer.FieldChange{{Field: "cats", Added: []string{"64"}}}
+	if !reflect.DeepEqual(report.Changed[0].Fields, want) {
+		t.Errorf("Diff() Nginx fields = %+v, want %+v", report.Changed[0].Fields, want)
+	}
+
+	if _, err := wappalyzer.Diff([]byte(`not json`), newJSON); err == nil {
+		t.Error("Diff() of invalid technologies succeeded")
+	}
+}
@@ -0,0 +1,37 @@
+package wappalyzer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/lint"
+)
+
+// LintReport lists the issues found by Lint
+type LintReport = lint.Report
+
+// LintIssue is a problem found by Lint
+type LintIssue = lint.Issue
+
+// Lint validates fingerprints without compiling them into an instance, which
+// would silently skip anything invalid. It checks technologiesJSON, in either
+// the object or the array format, against the schema and reports patterns
+// that don't compile or match any page, dangling implies, excludes and
+// requires targets and implication cycles. Unknown category IDs are only
+// reported when categoriesJSON is given.
+func Lint(technologiesJSON, categoriesJSON []byte) (*LintReport, error) {
+	technologies, err := downloader.ParseTechnologies(technologiesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse technologies: %v", err)
+	}
+
+	var categories map[string]json.RawMessage
+	if categoriesJSON != nil {
+		if err := json.Unmarshal(categoriesJSON, &categories); err != nil {
+			return nil, fmt.Errorf("failed to parse categories: %v", err)
+		}
+	}
+
+	return lint.Lint(technologies, categories), nil
+}
@@ -0,0 +1,69 @@
+package wappalyzer
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/corpus"
+)
+
+// RecordResponse records a received response for a corpus under the given
+// name, expecting the technologies and versions the instance detects on it.
+// The body is kept as received, so the configured maximum body size bounds
+// the raw bytes read here rather than the decoded ones.
+func (w *Wappalyze) RecordResponse(resp *http.Response, name string) (*RecordedResponse, error) {
+	response, err := corpus.FromHTTP(resp, int64(w.current().config.MaxBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	response.Name = name
+	response.Expected = w.FingerprintWithVersions(response.Headers, response.Body)
+	return response, nil
+}
+
+// SaveRecordedResponse writes a recorded response below dir, with its
+// expectations in a .expected.json file next to it, see LoadCorpus
+func SaveRecordedResponse(dir string, response *RecordedResponse) error {
+	return corpus.Save(dir, response)
+}
+
+// CheckExpectations replays a recorded response through Fingerprint and
+// FingerprintWithVersions and describes how the detections differ from the
+// expected ones, sorted. It returns nil when they match, or when the response
+// has no expectations.
+func (w *Wappalyze) CheckExpectations(response *RecordedResponse) []string {
+	if response.Expected == nil {
+		return nil
+	}
+
+	technologies := w.Fingerprint(response.Headers, response.Body)
+	versions := w.FingerprintWithVersions(response.Headers, response.Body)
+
+	var problems []string
+	for name, expected := range response.Expected {
+		if _, ok := technologies[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: not detected", name))
+			continue
+		}
+		if version := versions[name]; version != expected {
+			problems = append(problems, fmt.Sprintf("%s: version %q, expected %q", name, version, expected))
+		}
+	}
+	for name := range technologies {
+		if _, ok := response.Expected[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: detected but not expected", name))
+		}
+	}
+
+	// Both methods must agree on what is detected
+	for name := range versions {
+		if _, ok := technologies[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: only detected with versions", name))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
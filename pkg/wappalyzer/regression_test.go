@@ -0,0 +1,116 @@
+package wappalyzer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+const (
+	// fixtureDir holds the small, pinned fingerprint set the corpus is checked against
+	fixtureDir = "testdata/regression/fingerprints"
+
+	// corpusDir holds the recorded responses and their expected detections,
+	// see go-wappalyzer record
+	corpusDir = "testdata/regression/corpus"
+)
+
+// newFixtureInstance loads the fixture fingerprints, without touching the network
+func newFixtureInstance(t *testing.T) *wappalyzer.Wappalyze {
+	t.Helper()
+
+	w, err := wappalyzer.New(wappalyzer.WithAllDetections(), wappalyzer.WithSources(wappalyzer.Source{Dir: fixtureDir}))
+	if err != nil {
+		t.Fatalf("could not load fixture fingerprints: %v", err)
+	}
+	return w
+}
+
+// TestRegressionCorpus replays every recorded response and checks that the
+// detected technologies and versions are the expected ones
+func TestRegressionCorpus(t *testing.T) {
+	w := newFixtureInstance(t)
+
+	responses, err := wappalyzer.LoadCorpus(corpusDir)
+	if err != nil {
+		t.Fatalf("LoadCorpus() error = %v", err)
+	}
+	if len(responses) == 0 {
+		t.Fatalf("no recorded responses in %s", corpusDir)
+	}
+
+	for _, response := range responses {
+		response := response
+		t.Run(response.Name, func(t *testing.T) {
+			if response.Expected == nil {
+				t.Fatalf("no expectations, record them with go-wappalyzer record")
+			}
+			for _, problem := range w.CheckExpectations(response) {
+				t.Error(problem)
+			}
+		})
+	}
+}
+
+// TestRecordResponse records a local response and replays it from the corpus
+func TestRecordResponse(t *testing.T) {
+	w := newFixtureInstance(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Server", "nginx/1.24.0")
+		rw.Write([]byte(`<html><head><script src="/js/jquery-3.6.0.min.js"></script></head></html>`))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("could not fetch the response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	recorded, err := w.RecordResponse(resp, "local/page")
+	if err != nil {
+		t.Fatalf("RecordResponse() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := wappalyzer.SaveRecordedResponse(dir, recorded); err != nil {
+		t.Fatalf("SaveRecordedResponse() error = %v", err)
+	}
+
+	responses, err := wappalyzer.LoadCorpus(dir)
+	if err != nil {
+		t.Fatalf("LoadCorpus() error = %v", err)
+	}
+	if len(responses) != 1 || responses[0].Name != "local/page" {
+		t.Fatalf("LoadCorpus() = %d responses, want local/page only", len(responses))
+	}
+
+	response := responses[0]
+	want := map[string]string{"Nginx": "1.24.0", "jQuery": "3.6.0"}
+	if !reflect.DeepEqual(response.Expected, want) {
+		t.Errorf("Expected = %v, want %v", response.Expected, want)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+	if problems := w.CheckExpectations(response); len(problems) > 0 {
+		t.Errorf("CheckExpectations() = %q, want none", problems)
+	}
+
+	// A changed expectation must be reported
+	response.Expected["Nginx"] = "1.25.0"
+	response.Expected["PHP"] = ""
+	delete(response.Expected, "jQuery")
+	wantProblems := []string{
+		`Nginx: version "1.24.0", expected "1.25.0"`,
+		"PHP: not detected",
+		"jQuery: detected but not expected",
+	}
+	if problems := w.CheckExpectations(response); !reflect.DeepEqual(problems, wantProblems) {
+		t.Errorf("CheckExpectations() = %q, want %q", problems, wantProblems)
+	}
+}
@@ -0,0 +1,64 @@
+package wappalyzer
+
+import (
+	"context"
+	"time"
+)
+
+// Reload loads and compiles the instance's fingerprints again, from the same
+// source it was created from, and swaps them in once they are ready.
+//
+// Detection calls are never blocked by a reload: calls in flight finish with
+// the previous fingerprints and later calls use the new ones. If the reload
+// fails, the previous fingerprints are kept and the error is returned.
+//
+// Downloaded fingerprints are fetched again with the downloader configuration,
+// so a cache that has not expired yet is reloaded as is. Serving an expired
+// cache because refreshing it failed counts as a failed reload.
+func (w *Wappalyze) Reload(ctx context.Context) error {
+	w.reloadMutex.Lock()
+	defer w.reloadMutex.Unlock()
+
+	// A reload replaces the fingerprints in use, falling back to the
+	// snapshot would silently downgrade them
+	config := *w.config
+	if !config.ForceSnapshot {
+		config.Snapshot = nil
+	}
+
+	e, err := buildEngine(ctx, &config)
+	if err != nil {
+		return err
+	}
+	if e.info.Stale {
+		return e.info.RefreshError
+	}
+
+	w.engine.Store(e)
+	return nil
+}
+
+// AutoReload reloads the instance every interval in the background until ctx
+// is done. Failed reloads are reported to the handler set with
+// WithReloadErrorHandler. A non-positive interval disables reloading.
+func (w *Wappalyze) AutoReload(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.Reload(ctx); err != nil && ctx.Err() == nil && w.config.OnReloadError != nil {
+					w.config.OnReloadError(err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,65 @@
+package wappalyzer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// TestAutoReload checks that a broken source is reported to the handler on
+// every reload, and that non-positive intervals disable reloading
+func TestAutoReload(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"technologies.json", "categories.json", "groups.json"} {
+		data, err := os.ReadFile(filepath.Join(fixtureDir, name))
+		if err != nil {
+			t.Fatalf("could not read the fixture: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("could not write the fixture: %v", err)
+		}
+	}
+
+	reloadErrors := make(chan error, 16)
+	w, err := wappalyzer.New(wappalyzer.WithSources(wappalyzer.Source{Dir: dir}),
+		wappalyzer.WithReloadErrorHandler(func(err error) {
+			select {
+			case reloadErrors <- err:
+			default:
+			}
+		}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "technologies.json"), []byte("not json"), 0644); err != nil {
+		t.Fatalf("could not break the fixture: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Disabled reloads never run, and must not panic
+	w.AutoReload(ctx, 0)
+	w.AutoReload(ctx, -time.Second)
+	select {
+	case err := <-reloadErrors:
+		t.Fatalf("reloaded with a non-positive interval: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w.AutoReload(ctx, 10*time.Millisecond)
+	select {
+	case <-reloadErrors:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no failed reload reported")
+	}
+
+	// The previous fingerprints are kept
+	if _, ok := w.Fingerprint(map[string][]string{"Server": {"nginx/1.25.3"}}, nil)["Nginx"]; !ok {
+		t.Errorf("Nginx not detected after a failed reload")
+	}
+}
@@ -0,0 +1,378 @@
+package wappalyzer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+// ResultSchemaVersion is the version of the JSON encoding of Result. Fields
+// may be added without changing it, it is increased on any other change.
+const ResultSchemaVersion = 1
+
+// Evidence lists the kinds of data a technology can be detected from
+const (
+	EvidenceHeaders   = "headers"
+	EvidenceCookies   = "cookies"
+	EvidenceHTML      = "html"
+	EvidenceScripts   = "scripts"
+	EvidenceScriptSrc = "scriptSrc"
+	EvidenceMeta      = "meta"
+	EvidenceJS        = "js"
+	// EvidenceImplies marks technologies implied by detected ones
+	EvidenceImplies = "implies"
+)
+
+// Result is the analysis of a response. Technologies are sorted by name,
+// and every list is encoded as [] rather than null when empty, so that the
+// same analysis is always encoded the same way.
+type Result struct {
+	SchemaVersion int          `json:"schema_version"`
+	Title         string       `json:"title,omitempty"`
+	Technologies  []Technology `json:"technologies"`
+}
+
+// Technology is a technology detected in a response
+type Technology struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Confidence of the detection, from 0 to 100
+	Confidence int `json:"confidence"`
+	// Categories and Groups are sorted by ID
+	Categories  []Category `json:"categories"`
+	Groups      []Group    `json:"groups"`
+	Description string     `json:"description,omitempty"`
+	Website     string     `json:"website,omitempty"`
+	Icon        string     `json:"icon,omitempty"`
+	CPE         string     `json:"cpe,omitempty"`
+	// Evidence lists the kinds of data the technology was detected from,
+	// see the Evidence constants
+	Evidence []string `json:"evidence"`
+	// ImpliedBy lists the detected technologies implying this one
+	ImpliedBy []string `json:"implied_by,omitempty"`
+}
+
+// Category is a category of a detected technology. Name is empty for
+// categories missing from the loaded categories.
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Group is a group of the categories of a detected technology
+type Group struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Analyze identifies technologies on a target, based on the received
+// response headers and body, with everything known about them: version,
+// categories, groups, evidence and the title of the page.
+//
+// Body should not be mutated while this function is being called,
+// or it may lead to unexpected results.
+func (w *Wappalyze) Analyze(headers map[string][]string, body []byte) *Result {
+	return w.current().analyze(headers, body, analyzeAll)
+}
+
+// analysis selects the costlier parts of an analysis
+type analysis uint8
+
+const (
+	analyzeVersions analysis = 1 << iota
+	analyzeEvidence
+	analyzeTitle
+	analyzeConfidence
+
+	analyzeAll = analyzeVersions | analyzeEvidence | analyzeTitle | analyzeConfidence
+)
+
+// analyze runs the matchers against the decoded body, and the parts of the
+// analysis selected by what
+func (e *engine) analyze(headers map[string][]string, body []byte, what analysis) *Result {
+	// Decode once so every part of the analysis sees the same text
+	body = e.decodeBody(headers, body)
+
+	technologies := make(map[string]struct{})
+	var evidence map[string][]string
+	if what&analyzeEvidence != 0 {
+		evidence = make(map[string][]string)
+	}
+	e.matchTechnologies(headers, body, technologies, evidence)
+
+	var (
+		versions   map[string]string
+		confidence map[string]int
+	)
+	if what&(analyzeVersions|analyzeConfidence) != 0 {
+		versions, confidence = e.detectDetails(headers, body, technologies, what)
+	}
+	e.addImpliedTechnologies(technologies)
+
+	result := e.newResult(technologies, versions, confidence, evidence)
+	if what&analyzeTitle != 0 {
+		result.Title = parser.ExtractTitle(body)
+	}
+	return result
+}
+
+// newResult describes the detected technologies. Without confidence every
+// technology is given 100.
+func (e *engine) newResult(technologies map[string]struct{}, versions map[string]string, confidence map[string]int, evidence map[string][]string) *Result {
+	result := &Result{
+		SchemaVersion: ResultSchemaVersion,
+		Technologies:  make([]Technology, 0, len(technologies)),
+	}
+
+	// Invert the implications between detected technologies
+	impliedBy := make(map[string][]string)
+	for technology := range technologies {
+		for _, implied := range e.impliesMapping[technology] {
+			if _, ok := technologies[implied]; ok && implied != technology {
+				impliedBy[implied] = append(impliedBy[implied], technology)
+			}
+		}
+	}
+
+	for name := range technologies {
+		technology := Technology{
+			Name:       name,
+			Version:    versions[name],
+			Confidence: impliedConfidence(name, confidence, impliedBy, nil),
+			Categories: []Category{},
+			Groups:     []Group{},
+			Evidence:   []string{},
+		}
+
+		if app, ok := e.fingerprints.Apps[name]; ok {
+			technology.Description = app.Description
+			technology.Website = app.Website
+			technology.Icon = app.Icon
+			technology.CPE = app.CPE
+		}
+
+		groupIDs := make(map[int]struct{})
+		for _, categoryID := range e.categoryMapping[name] {
+			category := Category{ID: categoryID}
+			if item, ok := e.catalog.categories[categoryID]; ok {
+				category.Name = item.Name
+			}
+			technology.Categories = append(technology.Categories, category)
+
+			for _, groupID := range e.catalog.categoryToGroups[categoryID] {
+				if _, ok := groupIDs[groupID]; ok {
+					continue
+				}
+				groupIDs[groupID] = struct{}{}
+				if group, ok := e.catalog.groups[groupID]; ok {
+					technology.Groups = append(technology.Groups, Group{ID: groupID, Name: group.Name})
+				}
+			}
+		}
+		sort.Slice(technology.Categories, func(i, j int) bool { return technology.Categories[i].ID < technology.Categories[j].ID })
+		sort.Slice(technology.Groups, func(i, j int) bool { return technology.Groups[i].ID < technology.Groups[j].ID })
+
+		if by := impliedBy[name]; len(by) > 0 {
+			sort.Strings(by)
+			technology.ImpliedBy = by
+		}
+		if evidence != nil {
+			technology.Evidence = append(technology.Evidence, evidence[name]...)
+			if len(technology.ImpliedBy) > 0 {
+				technology.Evidence = append(technology.Evidence, EvidenceImplies)
+			}
+		}
+
+		result.Technologies = append(result.Technologies, technology)
+	}
+
+	sort.Slice(result.Technologies, func(i, j int) bool {
+		return result.Technologies[i].Name < result.Technologies[j].Name
+	})
+	return result
+}
+
+// impliedConfidence returns the confidence of a technology. A technology
+// only implied by others is as confident as the most confident of them, and
+// any other one left out of confidence is given 100. Visiting holds the
+// technologies on the current path, so cycles don't count.
+func impliedConfidence(name string, confidence map[string]int, impliedBy map[string][]string, visiting map[string]bool) int {
+	if confidence == nil {
+		return 100
+	}
+	if total, ok := confidence[name]; ok {
+		return total
+	}
+	if visiting[name] {
+		return 0
+	}
+	if len(impliedBy[name]) == 0 {
+		return 100
+	}
+
+	if visiting == nil {
+		visiting = make(map[string]bool)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	best := 0
+	for _, by := range impliedBy[name] {
+		if total := impliedConfidence(by, confidence, impliedBy, visiting); total > best {
+			best = total
+		}
+	}
+	return best
+}
+
+// Get returns the detected technology with the given name
+func (r *Result) Get(name string) (Technology, bool) {
+	i := sort.Search(len(r.Technologies), func(i int) bool { return r.Technologies[i].Name >= name })
+	if i < len(r.Technologies) && r.Technologies[i].Name == name {
+		return r.Technologies[i], true
+	}
+	return Technology{}, false
+}
+
+// Has reports whether the technology with the given name was detected
+func (r *Result) Has(name string) bool {
+	_, ok := r.Get(name)
+	return ok
+}
+
+// Names returns the names of the detected technologies, sorted
+func (r *Result) Names() []string {
+	names := make([]string, 0, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		names = append(names, technology.Name)
+	}
+	return names
+}
+
+// Filter returns the result with only the technologies keep returns true for
+func (r *Result) Filter(keep func(Technology) bool) *Result {
+	filtered := *r
+	filtered.Technologies = make([]Technology, 0, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		if keep(technology) {
+			filtered.Technologies = append(filtered.Technologies, technology)
+		}
+	}
+	return &filtered
+}
+
+// Set returns the names of the detected technologies, as Fingerprint does
+func (r *Result) Set() map[string]struct{} {
+	set := make(map[string]struct{}, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		set[technology.Name] = struct{}{}
+	}
+	return set
+}
+
+// Versions returns the version of each detected technology, as
+// FingerprintWithVersions does
+func (r *Result) Versions() map[string]string {
+	versions := make(map[string]string, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		versions[technology.Name] = technology.Version
+	}
+	return versions
+}
+
+// AppInfo returns the description and website of each detected technology,
+// as FingerprintWithInfo does
+func (r *Result) AppInfo() map[string]models.AppInfo {
+	info := make(map[string]models.AppInfo, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		info[technology.Name] = models.AppInfo{Description: technology.Description, Website: technology.Website}
+	}
+	return info
+}
+
+// CatsInfo returns the category IDs of each detected technology, as
+// FingerprintWithCats does
+func (r *Result) CatsInfo() map[string]models.CatsInfo {
+	info := make(map[string]models.CatsInfo, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		var cats models.CatsInfo
+		for _, category := range technology.Categories {
+			cats.Cats = append(cats.Cats, category.ID)
+		}
+		info[technology.Name] = cats
+	}
+	return info
+}
+
+// CategoryNames returns the category names of each detected technology, as
+// FingerprintWithCategories does
+func (r *Result) CategoryNames() map[string][]string {
+	names := make(map[string][]string, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		names[technology.Name] = technology.CategoryNames()
+	}
+	return names
+}
+
+// GroupNames returns the group names of each detected technology, as
+// FingerprintWithGroups does
+func (r *Result) GroupNames() map[string][]string {
+	names := make(map[string][]string, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		names[technology.Name] = technology.GroupNames()
+	}
+	return names
+}
+
+// TechInfo returns the information about each detected technology, as
+// FingerprintWithTechInfo does
+func (r *Result) TechInfo() map[string]TechInfo {
+	info := make(map[string]TechInfo, len(r.Technologies))
+	for _, technology := range r.Technologies {
+		info[technology.Name] = TechInfo{
+			Name:        technology.Name,
+			Description: technology.Description,
+			Website:     technology.Website,
+			CPE:         technology.CPE,
+			Categories:  technology.CategoryNames(),
+			Groups:      technology.GroupNames(),
+			Confidence:  technology.Confidence,
+			Version:     technology.Version,
+		}
+	}
+	return info
+}
+
+// CategoryNames returns the names of the categories of the technology,
+// leaving out the categories missing from the loaded categories
+func (t Technology) CategoryNames() []string {
+	names := make([]string, 0, len(t.Categories))
+	for _, category := range t.Categories {
+		if category.Name != "" {
+			names = append(names, category.Name)
+		}
+	}
+	return names
+}
+
+// GroupNames returns the names of the groups of the technology
+func (t Technology) GroupNames() []string {
+	names := make([]string, 0, len(t.Groups))
+	for _, group := range t.Groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+// InGroup reports whether the technology belongs to the group with the given
+// name, ignoring case
+func (t Technology) InGroup(name string) bool {
+	for _, group := range t.Groups {
+		if strings.EqualFold(group.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package wappalyzer_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// analyzedHeaders and analyzedBody are a WordPress site behind nginx
+var (
+	analyzedHeaders = map[string][]string{
+		"Server":       {"nginx/1.25.3"},
+		"X-Powered-By": {"PHP/8.2.12"},
+	}
+	analyzedBody = []byte(`<html><head><title>Blog</title><meta name="generator" content="WordPress 6.4.2">` +
+		`<link rel='stylesheet' href='/wp-content/themes/theme/style.css'></head></html>`)
+)
+
+// TestAnalyze checks the detections, versions, categories, groups and
+// evidence of a result
+func TestAnalyze(t *testing.T) {
+	w := newFixtureInstance(t)
+	result := w.Analyze(analyzedHeaders, analyzedBody)
+
+	if result.SchemaVersion != wappalyzer.ResultSchemaVersion || result.Title != "Blog" {
+		t.Errorf("got schema version %d and title %q", result.SchemaVersion, result.Title)
+	}
+	if names, want := result.Names(), []string{"MySQL", "Nginx", "PHP", "WordPress"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Names() = %v, want %v", names, want)
+	}
+
+	wordpress, _ := result.Get("WordPress")
+	if wordpress.Version != "6.4.2" || wordpress.Website != "https://wordpress.org" {
+		t.Errorf("WordPress: version %q, website %q", wordpress.Version, wordpress.Website)
+	}
+	if want := []wappalyzer.Category{{ID: 1, Name: "CMS"}, {ID: 11, Name: "Blogs"}}; !reflect.DeepEqual(wordpress.Categories, want) {
+		t.Errorf("WordPress categories = %v, want %v", wordpress.Categories, want)
+	}
+	if want := []wappalyzer.Group{{ID: 3, Name: "Content"}}; !reflect.DeepEqual(wordpress.Groups, want) {
+		t.Errorf("WordPress groups = %v, want %v", wordpress.Groups, want)
+	}
+	if want := []string{wappalyzer.EvidenceHTML, wappalyzer.EvidenceMeta}; !reflect.DeepEqual(wordpress.Evidence, want) {
+		t.Errorf("WordPress evidence = %v, want %v", wordpress.Evidence, want)
+	}
+
+	// PHP is both detected and implied, MySQL only implied
+	php, _ := result.Get("PHP")
+	if want := []string{wappalyzer.EvidenceHeaders, wappalyzer.EvidenceImplies}; !reflect.DeepEqual(php.Evidence, want) || !reflect.DeepEqual(php.ImpliedBy, []string{"WordPress"}) {
+		t.Errorf("PHP evidence = %v, implied by %v", php.Evidence, php.ImpliedBy)
+	}
+	mysql, _ := result.Get("MySQL")
+	if want := []string{wappalyzer.EvidenceImplies}; !reflect.DeepEqual(mysql.Evidence, want) || mysql.Version != "" {
+		t.Errorf("MySQL evidence = %v, version %q", mysql.Evidence, mysql.Version)
+	}
+
+	if result.Has("jQuery") {
+		t.Error("jQuery detected")
+	}
+	if servers := result.Filter(func(t wappalyzer.Technology) bool { return t.InGroup("servers") }); !reflect.DeepEqual(servers.Names(), []string{"MySQL", "Nginx"}) {
+		t.Errorf("technologies in the Servers group = %v", servers.Names())
+	}
+}
+
+// TestFingerprintMethodsAgree checks that the Fingerprint methods are views
+// of Analyze
+func TestFingerprintMethodsAgree(t *testing.T) {
+	w := newFixtureInstance(t)
+	result := w.Analyze(analyzedHeaders, analyzedBody)
+
+	if got := w.Fingerprint(analyzedHeaders, analyzedBody); !reflect.DeepEqual(got, result.Set()) {
+		t.Errorf("Fingerprint() = %v, want %v", got, result.Set())
+	}
+	if got := w.FingerprintWithVersions(analyzedHeaders, analyzedBody); !reflect.DeepEqual(got, result.Versions()) {
+		t.Errorf("FingerprintWithVersions() = %v, want %v", got, result.Versions())
+	}
+	if got := w.FingerprintWithCats(analyzedHeaders, analyzedBody); !reflect.DeepEqual(got, result.CatsInfo()) {
+		t.Errorf("FingerprintWithCats() = %v, want %v", got, result.CatsInfo())
+	}
+	if got := w.FingerprintWithGroups(analyzedHeaders, analyzedBody); !reflect.DeepEqual(got, result.GroupNames()) {
+		t.Errorf("FingerprintWithGroups() = %v, want %v", got, result.GroupNames())
+	}
+	if got := w.FingerprintWithTechInfo(analyzedHeaders, analyzedBody); !reflect.DeepEqual(got, result.TechInfo()) {
+		t.Errorf("FingerprintWithTechInfo() = %v, want %v", got, result.TechInfo())
+	}
+	if _, title := w.FingerprintWithTitle(analyzedHeaders, analyzedBody); title != result.Title {
+		t.Errorf("FingerprintWithTitle() title = %q, want %q", title, result.Title)
+	}
+}
+
+// TestResultJSON pins the JSON encoding of a result, which must stay stable
+// across releases
+func TestResultJSON(t *testing.T) {
+	w := newFixtureInstance(t)
+	result := w.Analyze(map[string][]string{"Server": {"nginx/1.25.3"}}, []byte("<title>Home</title>"))
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"schema_version":1,"title":"Home","technologies":[{"name":"Nginx","version":"1.25.3","confidence":100,` +
+		`"categories":[{"id":22,"name":"Web servers"}],"groups":[{"id":7,"name":"Servers"}],` +
+		`"website":"https://nginx.org/en","cpe":"cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*","evidence":["headers"]}]}`
+	if string(encoded) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", encoded, want)
+	}
+
+	var decoded wappalyzer.Result
+	if err := json.Unmarshal(encoded, &decoded); err != nil || !reflect.DeepEqual(&decoded, result) {
+		t.Errorf("Unmarshal() = %+v, %v, want %+v", decoded, err, result)
+	}
+}
+
+// TestAnalyzeConfidence checks that the confidences of the matching patterns
+// of a technology add up, capped at 100, and that implied technologies are as
+// confident as the technologies implying them
+func TestAnalyzeConfidence(t *testing.T) {
+	w := newFixtureInstance(t)
+
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		body    string
+		want    map[string]int
+	}{
+		{
+			name:    "one pattern",
+			headers: map[string][]string{"Via": {"1.1 varnish (Varnish/7.4)"}},
+			want:    map[string]int{"Varnish": 50},
+		},
+		{
+			name:    "patterns add up",
+			headers: map[string][]string{"Via": {"1.1 varnish (Varnish/7.4)"}, "X-Varnish": {"32770"}},
+			want:    map[string]int{"Varnish": 100},
+		},
+		{
+			name:    "capped",
+			headers: map[string][]string{"Server": {"nginx/1.25.3"}, "Via": {"1.1 varnish"}, "X-Varnish": {"32770"}},
+			want:    map[string]int{"Nginx": 100, "Varnish": 100},
+		},
+		{
+			name:    "implied",
+			headers: analyzedHeaders,
+			body:    string(analyzedBody),
+			want:    map[string]int{"MySQL": 100, "Nginx": 100, "PHP": 100, "WordPress": 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := w.Analyze(tt.headers, []byte(tt.body))
+			got := make(map[string]int)
+			for _, technology := range result.Technologies {
+				got[technology.Name] = technology.Confidence
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() confidence = %v, want %v", got, tt.want)
+			}
+			for name, info := range w.FingerprintWithTechInfo(tt.headers, []byte(tt.body)) {
+				if info.Confidence != tt.want[name] {
+					t.Errorf("FingerprintWithTechInfo() %s confidence = %d, want %d", name, info.Confidence, tt.want[name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package wappalyzer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+// ErrEmptySnapshot is returned when a snapshot contains no technologies,
+// e.g. because it was never generated
+var ErrEmptySnapshot = errors.New("fingerprint snapshot contains no technologies")
+
+// Snapshot is a set of fingerprints bundled with the program, used to detect
+// technologies without any network access. The snapshot sub-package provides
+// one embedded at build time.
+type Snapshot struct {
+	// Version is the upstream release the snapshot was taken from
+	Version string
+	// Date is when the snapshot was taken
+	Date time.Time
+	// Source is the URL the snapshot was downloaded from
+	Source string
+	// Technologies contains the technologies.json data
+	Technologies []byte
+	// Categories contains the categories.json data
+	Categories []byte
+	// Groups contains the groups.json data
+	Groups []byte
+}
+
+// engineFromSnapshot creates an engine from the configured snapshot
+func engineFromSnapshot(config *Config) (*engine, error) {
+	snapshot := config.Snapshot
+
+	technologiesData, err := downloader.ParseTechnologies(snapshot.Technologies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot technologies: %v", err)
+	}
+	if len(technologiesData) == 0 {
+		return nil, ErrEmptySnapshot
+	}
+
+	categories, err := parseCatalogJSON(snapshot.Categories, snapshot.Groups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %v", err)
+	}
+	if categories, err = customCatalog(config, categories); err != nil {
+		return nil, err
+	}
+
+	fingerprints, err := fingerprintsFromTechnologies(technologiesData)
+	if err != nil {
+		return nil, err
+	}
+
+	compiledFingerprints, err := parser.CompileFingerprints(fingerprints)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile fingerprints: %v", err)
+	}
+
+	e, err := newEngine(config, compiledFingerprints, categories, nil)
+	if err != nil {
+		return nil, err
+	}
+	e.snapshot = snapshot
+	e.info = DataInfo{UpdatedAt: snapshot.Date}
+
+	return e, nil
+}
+
+// Snapshot returns the bundled snapshot the instance was built from, or nil
+// if its fingerprints came from another source
+func (w *Wappalyze) Snapshot() *Snapshot {
+	return w.current().snapshot
+}
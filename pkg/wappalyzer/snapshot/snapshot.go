@@ -0,0 +1,118 @@
+// Package snapshot embeds a snapshot of the wappalyzer fingerprints into the
+// program, for scanners that cannot download anything.
+//
+// Importing this package adds the fingerprints to the binary, so only do so
+// when offline use is needed:
+//
+//	fingerprints, err := snapshot.Load()
+//	if err != nil {
+//		return err
+//	}
+//	w, err := wappalyzer.New(wappalyzer.WithSnapshot(fingerprints))
+//
+// The snapshot is refreshed from the latest release with go generate, which
+// must run before building programs relying on it: the repository only
+// carries an empty placeholder, and Load returns ErrEmptySnapshot for it
+// rather than leaving a scanner without fingerprints.
+package snapshot
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+//go:generate go run ../../../cmd/fingerprints-manager -force -snapshot data
+
+//go:embed data/technologies.json data/categories.json data/groups.json data/snapshot.json
+var files embed.FS
+
+// info describes the embedded snapshot
+type info struct {
+	Version string `json:"version"`
+	Date    string `json:"date"`
+	Source  string `json:"source"`
+}
+
+// Load returns the embedded snapshot. It fails with ErrEmptySnapshot if the
+// snapshot was never generated, see the package documentation.
+func Load() (*wappalyzer.Snapshot, error) {
+	return load(files)
+}
+
+// Version returns the upstream release the embedded snapshot was taken from,
+// which is empty for the placeholder
+func Version() (string, error) {
+	meta, err := readInfo(files)
+	if err != nil {
+		return "", err
+	}
+	return meta.Version, nil
+}
+
+// Date returns when the embedded snapshot was taken, which is the zero time
+// for the placeholder
+func Date() (time.Time, error) {
+	meta, err := readInfo(files)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return meta.date(), nil
+}
+
+// load reads a snapshot from the data directory of fsys, failing if it holds
+// no technologies
+func load(fsys fs.FS) (*wappalyzer.Snapshot, error) {
+	meta, err := readInfo(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &wappalyzer.Snapshot{
+		Version: meta.Version,
+		Date:    meta.date(),
+		Source:  meta.Source,
+	}
+	for name, data := range map[string]*[]byte{
+		"technologies.json": &snapshot.Technologies,
+		"categories.json":   &snapshot.Categories,
+		"groups.json":       &snapshot.Groups,
+	} {
+		if *data, err = fs.ReadFile(fsys, "data/"+name); err != nil {
+			return nil, err
+		}
+	}
+
+	technologies, err := downloader.ParseTechnologies(snapshot.Technologies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot technologies: %v", err)
+	}
+	if len(technologies) == 0 {
+		return nil, fmt.Errorf("%w: run go generate in pkg/wappalyzer/snapshot before building", wappalyzer.ErrEmptySnapshot)
+	}
+	return snapshot, nil
+}
+
+// readInfo reads the description of a snapshot
+func readInfo(fsys fs.FS) (info, error) {
+	var meta info
+	data, err := fs.ReadFile(fsys, "data/snapshot.json")
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("invalid snapshot.json: %v", err)
+	}
+	return meta, nil
+}
+
+// date parses the snapshot date, returning the zero time if it is unknown
+func (i info) date() time.Time {
+	date, _ := time.Parse(time.RFC3339, i.Date)
+	return date
+}
@@ -0,0 +1,92 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// fixtureDir holds the small, pinned fingerprint set of the regression tests
+const fixtureDir = "../testdata/regression/fingerprints"
+
+// detectNginx builds an instance from a snapshot and checks that it detects
+// nginx from the Server header
+func detectNginx(t *testing.T, snapshot *wappalyzer.Snapshot) {
+	t.Helper()
+
+	w, err := wappalyzer.New(wappalyzer.WithSnapshot(snapshot))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, ok := w.Fingerprint(map[string][]string{"Server": {"nginx/1.25.3"}}, nil)["Nginx"]; !ok {
+		t.Errorf("Nginx not detected from the snapshot")
+	}
+}
+
+// TestEmbeddedSnapshot detects a technology with the embedded snapshot, once
+// it is generated
+func TestEmbeddedSnapshot(t *testing.T) {
+	version, err := Version()
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	date, err := Date()
+	if err != nil {
+		t.Fatalf("Date() error = %v", err)
+	}
+
+	snapshot, err := Load()
+	if errors.Is(err, wappalyzer.ErrEmptySnapshot) {
+		if version != "" || !date.IsZero() {
+			t.Errorf("placeholder has version %q and date %v", version, date)
+		}
+		t.Skip("the embedded snapshot is the empty placeholder, run go generate to test it")
+	}
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if snapshot.Version != version || !snapshot.Date.Equal(date) {
+		t.Errorf("Load() has version %q and date %v, want %q and %v", snapshot.Version, snapshot.Date, version, date)
+	}
+	detectNginx(t, snapshot)
+}
+
+// TestLoad loads a generated snapshot and detects a technology with it
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/snapshot.json": {Data: []byte(`{"version":"v1.2.3","date":"2024-01-02T03:04:05Z","source":"https://example.com/fingerprints.zip"}`)},
+	}
+	for _, name := range []string{"technologies.json", "categories.json", "groups.json"} {
+		data, err := os.ReadFile(filepath.Join(fixtureDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys["data/"+name] = &fstest.MapFile{Data: data}
+	}
+
+	snapshot, err := load(fsys)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if snapshot.Version != "v1.2.3" || snapshot.Date.IsZero() {
+		t.Errorf("got version %q and date %v", snapshot.Version, snapshot.Date)
+	}
+	detectNginx(t, snapshot)
+}
+
+// TestLoadPlaceholder checks that an empty snapshot is refused
+func TestLoadPlaceholder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/snapshot.json":     {Data: []byte(`{"version":"","date":"","source":""}`)},
+		"data/technologies.json": {Data: []byte(`{}`)},
+		"data/categories.json":   {Data: []byte(`{}`)},
+		"data/groups.json":       {Data: []byte(`{}`)},
+	}
+	if _, err := load(fsys); !errors.Is(err, wappalyzer.ErrEmptySnapshot) {
+		t.Errorf("load() error = %v, want ErrEmptySnapshot", err)
+	}
+}
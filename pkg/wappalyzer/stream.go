@@ -0,0 +1,109 @@
+package wappalyzer
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/decoder"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/detection"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+const (
+	// DefaultStreamWindowSize is the default overlap kept between chunks when
+	// streaming, and therefore the longest body match that is guaranteed to be found
+	DefaultStreamWindowSize = 16 * 1024
+
+	// streamChunkSize is how much new data is collected before body patterns are evaluated
+	streamChunkSize = 256 * 1024
+
+	// streamMaxTokenSize bounds the size of a single HTML token (e.g. an inline script)
+	streamMaxTokenSize = 1024 * 1024
+)
+
+// FingerprintReader identifies technologies on a target like Fingerprint, but
+// reads the body from r in a single streaming pass with bounded memory.
+//
+// Body patterns are matched over a sliding window (see WithStreamWindowSize),
+// and tags are extracted with an incremental tokenizer, so very large
+// responses can be fingerprinted without buffering them. As with Fingerprint,
+// at most MaxBodySize bytes of the decoded body are scanned.
+func (w *Wappalyze) FingerprintReader(headers map[string][]string, r io.Reader) (map[string]struct{}, error) {
+	result, err := w.current().analyzeReader(headers, r, 0)
+	if err != nil {
+		return nil, err
+	}
+	return result.Set(), nil
+}
+
+// analyzeReader is the streaming counterpart of analyze. Versions and
+// evidence need the whole body, so only analyzeTitle is honored in what.
+func (e *engine) analyzeReader(headers map[string][]string, r io.Reader, what analysis) (*Result, error) {
+	technologies := make(map[string]struct{})
+
+	// Header based detection doesn't need the body
+	detection.MatchHeaders(e.headerPatterns, headers, technologies)
+	cookies := detection.ExtractCookiesFromHeaders(headers)
+	detection.MatchCookies(e.cookiePatterns, cookies, technologies)
+
+	if !e.config.DisableBodyDecoding {
+		decoded, err := decoder.NewReader(headers, r)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding response body: %v", err)
+		}
+		r = decoded
+	}
+
+	// MaxBodySize bounds the decoded body, which also bounds how much of a
+	// compressed body is read
+	if e.config.MaxBodySize > 0 {
+		r = io.LimitReader(r, int64(e.config.MaxBodySize))
+	}
+
+	// Feed every byte the tokenizer reads to the windowed body matcher too
+	var bodyMatchers []*parser.BodyMatcher
+	if !e.config.DisableHTMLDetection {
+		bodyMatchers = append(bodyMatchers, e.htmlMatcher)
+	}
+	if !e.config.DisableScriptDetection {
+		bodyMatchers = append(bodyMatchers, e.scriptMatcher)
+	}
+
+	window := e.config.StreamWindowSize
+	if window <= 0 {
+		window = DefaultStreamWindowSize
+	}
+	matcher := detection.NewStreamMatcher(bodyMatchers, window, streamChunkSize, technologies)
+
+	tags, err := parser.ScanTags(io.TeeReader(r, matcher), streamMaxTokenSize)
+	if err == html.ErrBufferExceeded {
+		// A token was too large to extract tags from, keep matching the rest of the body
+		_, err = io.Copy(matcher, r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	matcher.Flush()
+
+	if !e.config.DisableScriptDetection {
+		detection.MatchScriptSrc(e.scriptSrcPatterns, tags.Scripts, technologies)
+	}
+
+	if !e.config.DisableMetaDetection {
+		detection.MatchMetaTags(e.metaPatterns, tags.MetaTags, technologies)
+	}
+
+	if !e.config.DisableJSDetection {
+		detection.MatchJS(e.jsPatterns, tags.JS, technologies)
+	}
+
+	e.addImpliedTechnologies(technologies)
+
+	result := e.newResult(technologies, nil, nil, nil)
+	if what&analyzeTitle != 0 {
+		result.Title = tags.Title
+	}
+	return result, nil
+}
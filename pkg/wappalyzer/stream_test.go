@@ -0,0 +1,136 @@
+package wappalyzer_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// gzipBody compresses body with gzip
+func gzipBody(t *testing.T, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		t.Fatalf("could not compress body: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("could not compress body: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestAnalyzeURL checks that the AnalyzeURL methods agree with the Fingerprint
+// methods
+func TestAnalyzeURL(t *testing.T) {
+	w := newFixtureInstance(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		for name, values := range analyzedHeaders {
+			rw.Header()[name] = values
+		}
+		rw.Write(analyzedBody)
+	}))
+	defer server.Close()
+
+	technologies, err := w.AnalyzeURL(server.URL)
+	if err != nil {
+		t.Fatalf("AnalyzeURL() error = %v", err)
+	}
+	if want := w.Fingerprint(analyzedHeaders, analyzedBody); !reflect.DeepEqual(technologies, want) {
+		t.Errorf("AnalyzeURL() = %v, want %v", technologies, want)
+	}
+
+	info, err := w.AnalyzeURLWithInfo(server.URL)
+	if want := w.FingerprintWithInfo(analyzedHeaders, analyzedBody); err != nil || !reflect.DeepEqual(info, want) {
+		t.Errorf("AnalyzeURLWithInfo() = %v, %v, want %v", info, err, want)
+	}
+
+	cats, err := w.AnalyzeURLWithCats(server.URL)
+	if want := w.FingerprintWithCats(analyzedHeaders, analyzedBody); err != nil || !reflect.DeepEqual(cats, want) {
+		t.Errorf("AnalyzeURLWithCats() = %v, %v, want %v", cats, err, want)
+	}
+
+	technologies, title, err := w.AnalyzeURLWithTitle(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil || title != "Blog" || !reflect.DeepEqual(technologies, w.Fingerprint(analyzedHeaders, analyzedBody)) {
+		t.Errorf("AnalyzeURLWithTitle() = %v, %q, %v", technologies, title, err)
+	}
+}
+
+// TestAnalyzeURLMaxBodySize checks that the AnalyzeURL methods read at most
+// MaxBodySize bytes of the response body
+func TestAnalyzeURLMaxBodySize(t *testing.T) {
+	body := []byte(strings.Repeat(" ", 8192) + `<link rel="stylesheet" href="/wp-content/themes/theme/style.css">`)
+	markerEnd := bytes.Index(body, []byte("/wp-content/")) + len("/wp-content/")
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(body)
+	}))
+	defer server.Close()
+
+	for _, limit := range []int{0, markerEnd - 1, markerEnd} {
+		w, err := wappalyzer.New(wappalyzer.WithAllDetections(), wappalyzer.WithSources(wappalyzer.Source{Dir: fixtureDir}),
+			wappalyzer.WithMaxBodySize(limit))
+		if err != nil {
+			t.Fatalf("could not load fixture fingerprints: %v", err)
+		}
+
+		technologies, err := w.AnalyzeURL(server.URL)
+		if err != nil {
+			t.Fatalf("AnalyzeURL() error = %v", err)
+		}
+		if _, ok := technologies["WordPress"]; ok != (limit != markerEnd-1) {
+			t.Errorf("AnalyzeURL() with a limit of %d = %v", limit, technologies)
+		}
+	}
+}
+
+// TestMaxBodySize checks that Fingerprint and FingerprintReader both scan
+// MaxBodySize bytes of the decoded body, however it is encoded
+func TestMaxBodySize(t *testing.T) {
+	marker := `<link rel="stylesheet" href="/wp-content/themes/theme/style.css">`
+	// The padding compresses to far fewer bytes than the limit
+	body := []byte(strings.Repeat(" ", 8192) + marker)
+	// The WordPress pattern needs the body up to the end of /wp-content/
+	markerEnd := bytes.Index(body, []byte("/wp-content/")) + len("/wp-content/")
+
+	encodings := map[string][]byte{
+		"identity": body,
+		"gzip":     gzipBody(t, body),
+	}
+
+	for encoding, encoded := range encodings {
+		for _, limit := range []int{0, markerEnd - 1, markerEnd, 2 * markerEnd} {
+			t.Run(fmt.Sprintf("%s/%d", encoding, limit), func(t *testing.T) {
+				w, err := wappalyzer.New(wappalyzer.WithAllDetections(), wappalyzer.WithSources(wappalyzer.Source{Dir: fixtureDir}),
+					wappalyzer.WithMaxBodySize(limit))
+				if err != nil {
+					t.Fatalf("could not load fixture fingerprints: %v", err)
+				}
+
+				headers := map[string][]string{"Content-Encoding": {encoding}}
+				want := limit == 0 || limit >= markerEnd
+
+				buffered := w.Fingerprint(headers, encoded)
+				if _, ok := buffered["WordPress"]; ok != want {
+					t.Errorf("Fingerprint() = %v, want WordPress detected: %v", buffered, want)
+				}
+
+				streamed, err := w.FingerprintReader(headers, bytes.NewReader(encoded))
+				if err != nil {
+					t.Fatalf("FingerprintReader() error = %v", err)
+				}
+				if !reflect.DeepEqual(streamed, buffered) {
+					t.Errorf("FingerprintReader() = %v, Fingerprint() = %v", streamed, buffered)
+				}
+			})
+		}
+	}
+}
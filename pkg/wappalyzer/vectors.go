@@ -0,0 +1,56 @@
+package wappalyzer
+
+import (
+	"fmt"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/vectors"
+)
+
+// FingerprintTestReport lists the results of RunFingerprintTests
+type FingerprintTestReport = vectors.Report
+
+// FingerprintTestResult is the outcome of the tests of a technology
+type FingerprintTestResult = vectors.Result
+
+// FingerprintTestFailure is a test whose detection differs from the expected one
+type FingerprintTestFailure = vectors.Failure
+
+// RunFingerprintTests runs the test vectors technologies carry in their tests
+// field: sample headers, cookies, html, meta and scriptSrc inputs with the
+// expected match and version. technologiesJSON, in either the object or the
+// array format, is compiled into an instance with every detection enabled,
+// and each vector is run through it as a synthetic response. Categories are
+// only needed by technologies with requiresCategory.
+func RunFingerprintTests(technologiesJSON, categoriesJSON []byte) (*FingerprintTestReport, error) {
+	technologies, err := downloader.ParseTechnologies(technologiesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse technologies: %v", err)
+	}
+
+	categories, err := parseCatalogJSON(categoriesJSON, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints, err := fingerprintsFromTechnologies(technologies)
+	if err != nil {
+		return nil, err
+	}
+	compiledFingerprints, err := parser.CompileFingerprints(fingerprints)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile fingerprints: %v", err)
+	}
+
+	config := &Config{}
+	WithAllDetections()(config)
+	e, err := newEngine(config, compiledFingerprints, categories, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wappalyze{config: config}
+	w.engine.Store(e)
+	return vectors.Run(technologies, w.FingerprintWithVersions), nil
+}
@@ -0,0 +1,120 @@
+package wappalyzer_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mamamialezatoz/go-wappalyzer/pkg/wappalyzer"
+)
+
+// TestFixtureFingerprintTests runs the test vectors of the fixture fingerprints
+func TestFixtureFingerprintTests(t *testing.T) {
+	technologies, err := os.ReadFile(filepath.Join(fixtureDir, "technologies.json"))
+	if err != nil {
+		t.Fatalf("could not read the fixture technologies: %v", err)
+	}
+	categories, err := os.ReadFile(filepath.Join(fixtureDir, "categories.json"))
+	if err != nil {
+		t.Fatalf("could not read the fixture categories: %v", err)
+	}
+
+	report, err := wappalyzer.RunFingerprintTests(technologies, categories)
+	if err != nil {
+		t.Fatalf("RunFingerprintTests() error = %v", err)
+	}
+	if report.Tests == 0 {
+		t.Fatalf("no tests in the fixture fingerprints")
+	}
+	for _, result := range report.Results {
+		if result.Error != "" {
+			t.Errorf("%s: invalid tests: %s", result.Technology, result.Error)
+		}
+		for _, failure := range result.Failures {
+			t.Errorf("%s: %s", result.Technology, failure)
+		}
+	}
+
+	// The tests field must not break linting nor detection
+	lintReport, err := wappalyzer.Lint(technologies, categories)
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	for _, issue := range lintReport.Issues {
+		if issue.Field == "tests" {
+			t.Errorf("Lint() reported %s", issue)
+		}
+	}
+}
+
+// TestFingerprintTestFailures checks that failing and invalid tests are
+// reported per technology
+func TestFingerprintTestFailures(t *testing.T) {
+	technologies := []byte(`{
+		"Nginx": {
+			"cats": [22],
+			"website": "https://nginx.org",
+			"headers": {"Server": "nginx(?:/([\\d.]+))?\\;version:\\1"},
+			"tests": [
+				{"headers": {"Server": "nginx/1.24.0"}, "version": "1.24.0"},
+				{"headers": {"Server": "nginx/1.24.0"}, "version": "1.25.0"},
+				{"headers": {"Server": "lighttpd"}},
+				{"headers": {"Server": "nginx"}, "match": false}
+			]
+		},
+		"PHP": {
+			"cats": [27],
+			"website": "https://php.net",
+			"cookies": {"PHPSESSID": ""},
+			"tests": [{"cookies": {"PHPSESSID": "abc"}}]
+		},
+		"Generator": {
+			"cats": [1],
+			"website": "https://example.com",
+			"meta": {"generator": "^Generator ([\\d.]+)\\;version:\\1"},
+			"scriptSrc": "generator\\.js",
+			"tests": [
+				{"meta": {"generator": "Generator 2.0"}, "version": "2.0"},
+				{"scriptSrc": ["/static/generator.js"], "version": ""}
+			]
+		},
+		"Invalid": {
+			"cats": [1],
+			"website": "https://example.com",
+			"html": "invalid",
+			"tests": [{"script": "invalid"}]
+		},
+		"Untested": {
+			"cats": [1],
+			"website": "https://example.com",
+			"html": "untested"
+		}
+	}`)
+
+	report, err := wappalyzer.RunFingerprintTests(technologies, nil)
+	if err != nil {
+		t.Fatalf("RunFingerprintTests() error = %v", err)
+	}
+	if report.OK() {
+		t.Errorf("OK() = true, want false")
+	}
+
+	want := []wappalyzer.FingerprintTestResult{
+		{Technology: "Generator", Tests: 2},
+		{Technology: "Invalid", Error: `tests[0]: unknown field "script"`},
+		{Technology: "Nginx", Tests: 4, Failures: []wappalyzer.FingerprintTestFailure{
+			{Test: 1, Message: `version "1.24.0", expected "1.25.0"`},
+			{Test: 2, Message: "not detected"},
+			{Test: 3, Message: "detected, expected no match"},
+		}},
+		{Technology: "PHP", Tests: 1},
+	}
+	if !reflect.DeepEqual(report.Results, want) {
+		t.Errorf("Results = %+v, want %+v", report.Results, want)
+	}
+	if report.Technologies != 4 || report.Tests != 7 || report.Failed != 3 {
+		t.Errorf("report = %d technologies, %d tests, %d failed, want 4, 7 and 3",
+			report.Technologies, report.Tests, report.Failed)
+	}
+}
@@ -0,0 +1,190 @@
+package wappalyzer
+
+import (
+	"strings"
+
+	"github.com/mamamialezatoz/go-wappalyzer/internal/detection"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/parser"
+)
+
+// FingerprintWithVersions identifies technologies on a target and returns
+// their versions, taken from the patterns with a version template such as
+// \;version:\1. The version is empty when no matching pattern reveals one,
+// and for implied technologies.
+func (w *Wappalyze) FingerprintWithVersions(headers map[string][]string, body []byte) map[string]string {
+	return w.current().analyze(headers, body, analyzeVersions).Versions()
+}
+
+// detectDetails evaluates the patterns of detected technologies against an
+// already decoded body, for the parts of the analysis selected by what.
+//
+// With analyzeVersions, the versions are taken from the matching patterns
+// with a version template. When several patterns give a version the most
+// specific, longest one is kept.
+//
+// With analyzeConfidence, the confidence of a technology is the sum of the
+// confidences of its matching patterns, capped at 100 as Wappalyzer does.
+// Technologies none of whose patterns match again, such as implied ones,
+// are left out.
+//
+// The parts of the body the patterns apply to are only extracted when one
+// needs them.
+func (e *engine) detectDetails(headers map[string][]string, body []byte, technologies map[string]struct{}, what analysis) (map[string]string, map[string]int) {
+	var (
+		versions   map[string]string
+		confidence map[string]int
+	)
+	if what&analyzeVersions != 0 {
+		versions = make(map[string]string)
+	}
+	if what&analyzeConfidence != 0 {
+		confidence = make(map[string]int)
+	}
+
+	// skip reports whether a pattern has nothing to add to the analysis
+	skip := func(pattern *models.ParsedPattern) bool {
+		return confidence == nil && pattern.Version == ""
+	}
+
+	var (
+		normalizedHeaders map[string][]string
+		cookies           map[string]string
+		metaTags          map[string]string
+		jsValues          map[string]string
+		scripts           []models.ScriptPattern
+		bodyStr           string
+	)
+
+	for technology := range technologies {
+		evaluate := func(pattern *models.ParsedPattern, target string) bool {
+			matched, version := parser.EvaluatePattern(pattern, target)
+			if matched && versions != nil && moreSpecific(version, versions[technology]) {
+				versions[technology] = version
+			}
+			return matched
+		}
+		// count adds the confidence of a pattern once, however many of
+		// its targets it matched
+		count := func(pattern *models.ParsedPattern, matched bool) {
+			if matched && confidence != nil {
+				confidence[technology] += pattern.Confidence
+			}
+		}
+
+		for name, pattern := range e.headerPatterns[technology] {
+			if skip(pattern) {
+				continue
+			}
+			if normalizedHeaders == nil {
+				normalizedHeaders = make(map[string][]string, len(headers))
+				for header, values := range headers {
+					normalizedHeaders[strings.ToLower(header)] = values
+				}
+			}
+			matched := false
+			for _, value := range normalizedHeaders[strings.ToLower(name)] {
+				matched = evaluate(pattern, value) || matched
+			}
+			count(pattern, matched)
+		}
+
+		for name, pattern := range e.cookiePatterns[technology] {
+			if skip(pattern) {
+				continue
+			}
+			if cookies == nil {
+				cookies = detection.ExtractCookiesFromHeaders(headers)
+			}
+			if value, ok := cookies[strings.ToLower(name)]; ok {
+				count(pattern, evaluate(pattern, value))
+			}
+		}
+
+		if !e.config.DisableHTMLDetection || !e.config.DisableScriptDetection {
+			var patterns []*models.ParsedPattern
+			if !e.config.DisableHTMLDetection {
+				patterns = append(patterns, e.htmlPatterns[technology]...)
+			}
+			if !e.config.DisableScriptDetection {
+				patterns = append(patterns, e.scriptPatterns[technology]...)
+			}
+			for _, pattern := range patterns {
+				if skip(pattern) {
+					continue
+				}
+				if bodyStr == "" {
+					bodyStr = string(body)
+				}
+				count(pattern, evaluate(pattern, bodyStr))
+			}
+		}
+
+		if !e.config.DisableScriptDetection {
+			for _, pattern := range e.scriptSrcPatterns[technology] {
+				if skip(pattern) {
+					continue
+				}
+				if scripts == nil {
+					scripts = parser.ExtractScripts(body)
+				}
+				matched := false
+				for _, script := range scripts {
+					if script.Source != "" {
+						matched = evaluate(pattern, script.Source) || matched
+					}
+				}
+				count(pattern, matched)
+			}
+		}
+
+		if !e.config.DisableMetaDetection {
+			for name, patterns := range e.metaPatterns[technology] {
+				for _, pattern := range patterns {
+					if skip(pattern) {
+						continue
+					}
+					if metaTags == nil {
+						metaTags = make(map[string]string)
+						for metaName, content := range parser.ExtractMetaTags(body) {
+							metaTags[strings.ToLower(metaName)] = content
+						}
+					}
+					if content, ok := metaTags[strings.ToLower(name)]; ok {
+						count(pattern, evaluate(pattern, content))
+					}
+				}
+			}
+		}
+
+		if !e.config.DisableJSDetection {
+			for name, pattern := range e.jsPatterns[technology] {
+				if skip(pattern) {
+					continue
+				}
+				if jsValues == nil {
+					jsValues = parser.ExtractJS(body)
+				}
+				if value, ok := jsValues[name]; ok {
+					count(pattern, evaluate(pattern, value))
+				}
+			}
+		}
+	}
+
+	for technology, total := range confidence {
+		if total > 100 {
+			confidence[technology] = 100
+		}
+	}
+	return versions, confidence
+}
+
+// moreSpecific reports whether version should replace the current one: it is
+// longer, or as long and greater, so the choice doesn't depend on map order
+func moreSpecific(version, current string) bool {
+	if len(version) != len(current) {
+		return len(version) > len(current)
+	}
+	return version > current
+}
@@ -4,14 +4,18 @@ package wappalyzer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/mamamialezatoz/go-wappalyzer/internal/database"
+	"github.com/mamamialezatoz/go-wappalyzer/internal/decoder"
 	"github.com/mamamialezatoz/go-wappalyzer/internal/detection"
 	"github.com/mamamialezatoz/go-wappalyzer/internal/downloader"
 	"github.com/mamamialezatoz/go-wappalyzer/internal/models"
@@ -42,19 +46,43 @@ type TechInfo struct {
 	Version     string   `json:"version,omitempty"`
 }
 
-var (
-	syncOnce          sync.Once
-	categoriesMapping map[int]CategoryItem
-	groupsMapping     map[int]GroupItem
-	categoryToGroups  map[int][]int // Mapping from category ID to group IDs
-	groupCategories   map[int][]int // Mapping from group ID to category IDs
+// Source is a location fingerprints are loaded from, see WithSources
+type Source = downloader.Source
+
+// MergeMode defines how a source combines a technology with earlier definitions
+type MergeMode = downloader.MergeMode
+
+// PatternReport describes a pattern RE2 rejects, see Wappalyze.PatternReports
+type PatternReport = models.PatternReport
+
+// DataInfo describes how old the loaded fingerprints are, see Wappalyze.DataInfo
+type DataInfo = downloader.DataInfo
 
+const (
+	// MergeReplace replaces earlier definitions of a technology as a whole
+	MergeReplace = downloader.MergeReplace
+	// MergeAppend adds patterns, categories and implied technologies to earlier definitions
+	MergeAppend = downloader.MergeAppend
+)
+
+var (
 	// downloaderConfig holds the configuration for the fingerprints downloader
 	downloaderConfig *downloader.Config
 )
 
 // Wappalyze is a client for working with technology detection
 type Wappalyze struct {
+	config *Config
+	// engine holds the current *engine, replaced as a whole by Reload
+	engine atomic.Value
+	// reloadMutex serializes reloads
+	reloadMutex sync.Mutex
+}
+
+// engine holds compiled fingerprints and everything derived from them.
+// It is never modified once built, so detection runs without locking
+// while a reload builds its replacement.
+type engine struct {
 	config            *Config
 	fingerprints      *models.CompiledFingerprints
 	headerPatterns    map[string]map[string]*models.ParsedPattern
@@ -64,107 +92,36 @@ type Wappalyze struct {
 	scriptSrcPatterns map[string][]*models.ParsedPattern
 	metaPatterns      map[string]map[string][]*models.ParsedPattern
 	jsPatterns        map[string]map[string]*models.ParsedPattern
+	htmlMatcher       *parser.BodyMatcher
+	scriptMatcher     *parser.BodyMatcher
 	impliesMapping    map[string][]string
 	categoryMapping   map[string][]int
+	catalog           *catalog
+	snapshot          *Snapshot
+	provenance        map[string][]string
+	info              DataInfo
 }
 
 // SetDownloaderConfig sets the global configuration for the fingerprints downloader
 func SetDownloaderConfig(config *downloader.Config) {
 	downloaderConfig = config
-}
-
-func loadDataOnce() error {
-	var err error
-	syncOnce.Do(func() {
-		categoriesMapping = make(map[int]CategoryItem)
-		groupsMapping = make(map[int]GroupItem)
-		categoryToGroups = make(map[int][]int)
-		groupCategories = make(map[int][]int)
-
-		// Download and load data from ZeroCostAutomation/wappalyzer-fingerprints
-		config := downloaderConfig
-		if config == nil {
-			config = downloader.DefaultConfig()
-		}
-
-		// Get the fingerprints data
-		_, categoriesData, groupsData, err := downloader.GetFingerprints(config)
-		if err != nil {
-			err = fmt.Errorf("failed to get fingerprints data: %v", err)
-			return
-		}
-
-		// Process categories data
-		for categoryIDStr, rawData := range categoriesData {
-			var categoryData map[string]json.RawMessage
-			if err = json.Unmarshal(rawData, &categoryData); err != nil {
-				continue
-			}
-
-			category := CategoryItem{}
-
-			// Extract name
-			if nameData, ok := categoryData["name"]; ok {
-				json.Unmarshal(nameData, &category.Name)
-			}
-
-			// Extract priority
-			if priorityData, ok := categoryData["priority"]; ok {
-				json.Unmarshal(priorityData, &category.Priority)
-			}
-
-			// Extract groups
-			if groupsData, ok := categoryData["groups"]; ok {
-				var groups []int
-				json.Unmarshal(groupsData, &groups)
-				category.Groups = groups
-			}
-
-			// Convert category ID to int
-			categoryID, err := strconv.Atoi(categoryIDStr)
-			if err != nil {
-				continue
-			}
-
-			// Store in mapping
-			categoriesMapping[categoryID] = category
-
-			// Build category to groups mapping
-			if len(category.Groups) > 0 {
-				categoryToGroups[categoryID] = category.Groups
-
-				// Also build reverse mapping (group to categories)
-				for _, groupID := range category.Groups {
-					groupCategories[groupID] = append(groupCategories[groupID], categoryID)
-				}
-			}
-		}
-
-		// Process groups data
-		for groupIDStr, rawData := range groupsData {
-			var group GroupItem
-			if err = json.Unmarshal(rawData, &group); err != nil {
-				continue
-			}
 
-			groupID, err := strconv.Atoi(groupIDStr)
-			if err != nil {
-				continue
-			}
+	// The default instance has to be recreated with the new configuration
+	defaultMutex.Lock()
+	defaultInstance = nil
+	defaultMutex.Unlock()
+}
 
-			groupsMapping[groupID] = group
-		}
-	})
-	return err
+// SetBacktrackingBudget sets how long a single match of a pattern RE2 can't
+// evaluate, even translated, may take in the backtracking engine before it
+// counts as no match. Zero disables the engine and such patterns are skipped.
+// The setting applies to all instances.
+func SetBacktrackingBudget(budget time.Duration) {
+	parser.SetBacktrackingBudget(budget)
 }
 
 // New creates a new technology detection instance
 func New(options ...Option) (*Wappalyze, error) {
-	// Load the categories and groups data
-	if err := loadDataOnce(); err != nil {
-		return nil, err
-	}
-
 	// Default config
 	config := &Config{}
 
@@ -173,13 +130,41 @@ func New(options ...Option) (*Wappalyze, error) {
 		option(config)
 	}
 
-	// Get fingerprints data
-	var fingerprintsData []byte
-	var err error
+	e, err := buildEngine(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	wappalyze := &Wappalyze{config: config}
+	wappalyze.engine.Store(e)
+
+	return wappalyze, nil
+}
+
+// buildEngine loads and compiles the fingerprints selected by the config
+func buildEngine(ctx context.Context, config *Config) (*engine, error) {
+	// A compiled database carries everything, including categories and groups
+	if config.Database != nil || config.DatabasePath != "" {
+		return engineFromDatabase(config)
+	}
 
-	if config.JSON != nil && len(config.JSON) > 0 {
-		// Use provided fingerprints data
-		fingerprintsData = config.JSON
+	// A forced snapshot never touches the network
+	if config.Snapshot != nil && config.ForceSnapshot {
+		return engineFromSnapshot(config)
+	}
+
+	// Get fingerprints data
+	var fingerprints *models.Fingerprints
+	var categories *catalog
+	var provenance map[string][]string
+	var info DataInfo
+
+	if len(config.JSON) > 0 {
+		// Use provided fingerprints data, with only the categories and groups provided alongside
+		fingerprints = &models.Fingerprints{}
+		if err := json.NewDecoder(bytes.NewReader(config.JSON)).Decode(fingerprints); err != nil {
+			return nil, fmt.Errorf("could not decode fingerprints: %v", err)
+		}
 	} else {
 		// Download and get the fingerprints data
 		dlConfig := downloaderConfig
@@ -187,53 +172,128 @@ func New(options ...Option) (*Wappalyze, error) {
 			dlConfig = downloader.DefaultConfig()
 		}
 
-		// Get the fingerprints data
-		technologiesData, _, _, err := downloader.GetFingerprints(dlConfig)
+		// Get the fingerprints data, merged from several sources if configured
+		sources := config.Sources
+		if len(sources) == 0 {
+			sources = dlConfig.Sources
+		}
+
+		var technologiesData, categoriesData, groupsData map[string]json.RawMessage
+		var err error
+		if len(sources) > 0 {
+			layeredConfig := *dlConfig
+			layeredConfig.Sources = sources
+
+			var layered *downloader.LayeredFingerprints
+			if layered, err = downloader.GetLayeredFingerprints(ctx, &layeredConfig); err == nil {
+				technologiesData, categoriesData, groupsData = layered.Technologies, layered.Categories, layered.Groups
+				provenance = layered.Provenance
+				info = layered.Info
+			}
+		} else {
+			var dataInfo *downloader.DataInfo
+			if technologiesData, categoriesData, groupsData, dataInfo, err = downloader.GetFingerprintsWithInfo(ctx, dlConfig); err == nil {
+				info = *dataInfo
+			}
+		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to get fingerprints data: %v", err)
+			if config.Snapshot != nil {
+				return engineFromSnapshot(config)
+			}
+			return nil, fmt.Errorf("failed to get fingerprints data: %w", err)
 		}
+		categories = parseCatalog(categoriesData, groupsData)
 
 		// Convert the technologies data to the expected format
-		fingerprints := &models.Fingerprints{
-			Apps: make(map[string]*models.Fingerprint),
+		fingerprints, err = fingerprintsFromTechnologies(technologiesData)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		// Process the technologies data
-		for appName, rawData := range technologiesData {
-			var app models.Fingerprint
-			if err = json.Unmarshal(rawData, &app); err != nil {
-				return nil, fmt.Errorf("failed to parse fingerprint data for %s: %v", appName, err)
-			}
+	// Custom categories and groups take precedence over downloaded ones
+	categories, err := customCatalog(config, categories)
+	if err != nil {
+		return nil, err
+	}
 
-			// Ensure name is set (should already be in new format, but just to be safe)
-			if app.Name == "" {
-				app.Name = appName
-			}
+	// Compile fingerprints
+	compiledFingerprints, err := parser.CompileFingerprints(fingerprints)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile fingerprints: %v", err)
+	}
 
-			// Process JS values to ensure they're all strings
-			if app.JS != nil {
-				app.JS = processJSValues(app.JS)
-			}
+	e, err := newEngine(config, compiledFingerprints, categories, nil)
+	if err != nil {
+		return nil, err
+	}
+	e.provenance = provenance
+	e.info = info
 
-			fingerprints.Apps[appName] = &app
-		}
+	return e, nil
+}
 
-		// Marshal the fingerprints to JSON
-		fingerprintsData, err = json.Marshal(fingerprints)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal fingerprints: %v", err)
-		}
+// customCatalog returns the catalog built from the custom categories and
+// groups of the config, falling back to the given catalog for whichever of
+// the two was not provided
+func customCatalog(config *Config, fallback *catalog) (*catalog, error) {
+	if fallback == nil {
+		fallback = newCatalog()
+	}
+	if len(config.Categories) == 0 && len(config.Groups) == 0 {
+		return fallback, nil
 	}
 
-	// Parse fingerprints from JSON
-	var fingerprints models.Fingerprints
-	err = json.NewDecoder(bytes.NewReader(fingerprintsData)).Decode(&fingerprints)
+	custom, err := parseCatalogJSON(config.Categories, config.Groups)
 	if err != nil {
-		return nil, fmt.Errorf("could not decode fingerprints: %v", err)
+		return nil, err
 	}
 
-	wappalyze := &Wappalyze{
+	if len(config.Categories) == 0 {
+		for categoryID, category := range fallback.categories {
+			custom.addCategory(categoryID, category)
+		}
+	}
+	if len(config.Groups) == 0 {
+		custom.groups = fallback.groups
+	}
+
+	return custom, nil
+}
+
+// fingerprintsFromTechnologies converts raw technologies data, keyed by
+// technology name, to fingerprints
+func fingerprintsFromTechnologies(technologiesData map[string]json.RawMessage) (*models.Fingerprints, error) {
+	fingerprints := &models.Fingerprints{
+		Apps: make(map[string]*models.Fingerprint),
+	}
+
+	// Process the technologies data
+	for appName, rawData := range technologiesData {
+		var app models.Fingerprint
+		if err := json.Unmarshal(rawData, &app); err != nil {
+			return nil, fmt.Errorf("failed to parse fingerprint data for %s: %v", appName, err)
+		}
+
+		// Ensure name is set (should already be in new format, but just to be safe)
+		if app.Name == "" {
+			app.Name = appName
+		}
+
+		fingerprints.Apps[appName] = &app
+	}
+
+	return fingerprints, nil
+}
+
+// newEngine creates an engine from compiled fingerprints and their
+// categories and groups. If db is
+// given, the body matchers are restored from it instead of being compiled.
+func newEngine(config *Config, compiledFingerprints *models.CompiledFingerprints, categories *catalog, db *database.Database) (*engine, error) {
+	e := &engine{
 		config:            config,
+		fingerprints:      compiledFingerprints,
+		catalog:           categories,
 		headerPatterns:    make(map[string]map[string]*models.ParsedPattern),
 		cookiePatterns:    make(map[string]map[string]*models.ParsedPattern),
 		htmlPatterns:      make(map[string][]*models.ParsedPattern),
@@ -245,60 +305,23 @@ func New(options ...Option) (*Wappalyze, error) {
 		categoryMapping:   make(map[string][]int),
 	}
 
-	// Compile fingerprints
-	compiledFingerprints, err := parser.CompileFingerprints(&fingerprints)
-	if err != nil {
-		return nil, fmt.Errorf("could not compile fingerprints: %v", err)
-	}
-	wappalyze.fingerprints = compiledFingerprints
-
 	// Extract and organize patterns
-	if err := wappalyze.organizePatterns(); err != nil {
+	if err := e.organizePatterns(); err != nil {
 		return nil, fmt.Errorf("failed to organize patterns: %v", err)
 	}
 
+	// Build the prefiltered body matchers
+	if err := e.buildBodyMatchers(db); err != nil {
+		return nil, fmt.Errorf("failed to build body matchers: %v", err)
+	}
+
 	// Build implies mapping
-	wappalyze.buildImpliesMapping()
+	e.buildImpliesMapping()
 
 	// Build category mapping
-	wappalyze.buildCategoryMapping()
+	e.buildCategoryMapping()
 
-	return wappalyze, nil
-}
-
-// processJSValues converts JS property values to strings
-func processJSValues(js interface{}) map[string]string {
-	if js == nil {
-		return nil
-	}
-
-	result := make(map[string]string)
-
-	switch jsData := js.(type) {
-	case map[string]string:
-		// Already in the expected format
-		return jsData
-	case map[string]interface{}:
-		for key, value := range jsData {
-			switch v := value.(type) {
-			case string:
-				result[key] = v
-			case bool:
-				if v {
-					result[key] = "true"
-				} else {
-					result[key] = "false"
-				}
-			case float64, int, int64:
-				result[key] = fmt.Sprintf("%v", v)
-			default:
-				// For complex objects, just use an empty pattern
-				result[key] = ""
-			}
-		}
-	}
-
-	return result
+	return e, nil
 }
 
 // NewWithCustomFingerprints creates a new technology detection instance with custom fingerprints
@@ -308,31 +331,36 @@ func NewWithCustomFingerprints(fingerprintsJSON []byte) (*Wappalyze, error) {
 
 // GetCompiledFingerprints returns the compiled fingerprints
 func (w *Wappalyze) GetCompiledFingerprints() *models.CompiledFingerprints {
-	return w.fingerprints
+	return w.current().fingerprints
 }
 
-// GetCategoriesMapping returns the categories mapping
-func GetCategoriesMapping() map[int]CategoryItem {
-	loadDataOnce()
-	return categoriesMapping
+// PatternReports lists the patterns of the fingerprints in use that RE2
+// rejects, with how each was translated, or why it was skipped. It is nil
+// for instances loaded from a compiled database.
+func (w *Wappalyze) PatternReports() []PatternReport {
+	return w.current().fingerprints.PatternReports
 }
 
-// GetGroupsMapping returns the groups mapping
-func GetGroupsMapping() map[int]GroupItem {
-	loadDataOnce()
-	return groupsMapping
+// Provenance returns the names of the sources the definition of a technology
+// was merged from, lowest precedence first. It is nil unless the instance was
+// built from several sources, see WithSources.
+func (w *Wappalyze) Provenance(technology string) []string {
+	return w.current().provenance[technology]
 }
 
-// GetCategoryGroups returns the groups associated with a category
-func GetCategoryGroups(categoryID int) []int {
-	loadDataOnce()
-	return categoryToGroups[categoryID]
+// DataInfo describes how old the fingerprints in use are: when they were
+// downloaded or last revalidated, or when the compiled database or snapshot
+// they come from was built, and whether an expired cache is being served
+// because refreshing it failed, see downloader.Config.AllowStale.
+// UpdatedAt is zero for custom fingerprints.
+func (w *Wappalyze) DataInfo() DataInfo {
+	return w.current().info
 }
 
-// GetGroupCategories returns the categories associated with a group
-func GetGroupCategories(groupID int) []int {
-	loadDataOnce()
-	return groupCategories[groupID]
+// current returns the engine in use. Callers load it once per call so a
+// concurrent reload can't mix two fingerprint sets in one result.
+func (w *Wappalyze) current() *engine {
+	return w.engine.Load().(*engine)
 }
 
 // Fingerprint identifies technologies on a target, based on
@@ -341,299 +369,218 @@ func GetGroupCategories(groupID int) []int {
 // Body should not be mutated while this function is being called,
 // or it may lead to unexpected results.
 func (w *Wappalyze) Fingerprint(headers map[string][]string, body []byte) map[string]struct{} {
-	technologies := make(map[string]struct{})
-
-	// Match all the technologies based on the data we have
-	w.detectTechnologies(headers, body, technologies)
-
-	// Get implied technologies
-	w.addImpliedTechnologies(technologies)
-
-	return technologies
+	return w.current().analyze(headers, body, 0).Set()
 }
 
 // FingerprintWithInfo identifies technologies on a target and returns
 // additional information about each detected technology.
 func (w *Wappalyze) FingerprintWithInfo(headers map[string][]string, body []byte) map[string]models.AppInfo {
-	technologies := make(map[string]struct{})
-	w.detectTechnologies(headers, body, technologies)
-	w.addImpliedTechnologies(technologies)
-
-	result := make(map[string]models.AppInfo)
-	for technology := range technologies {
-		if app, ok := w.fingerprints.Apps[technology]; ok {
-			info := models.AppInfo{
-				Description: app.Description,
-				Website:     app.Website,
-			}
-			result[technology] = info
-		} else {
-			result[technology] = models.AppInfo{}
-		}
-	}
-	return result
+	return w.current().analyze(headers, body, 0).AppInfo()
 }
 
 // FingerprintWithCats identifies technologies on a target and returns
 // additional category information about each detected technology.
 func (w *Wappalyze) FingerprintWithCats(headers map[string][]string, body []byte) map[string]models.CatsInfo {
-	technologies := make(map[string]struct{})
-	w.detectTechnologies(headers, body, technologies)
-	w.addImpliedTechnologies(technologies)
-
-	result := make(map[string]models.CatsInfo)
-	for technology := range technologies {
-		cats, ok := w.categoryMapping[technology]
-		if !ok {
-			result[technology] = models.CatsInfo{}
-			continue
-		}
-		result[technology] = models.CatsInfo{Cats: cats}
-	}
-	return result
+	return w.current().analyze(headers, body, 0).CatsInfo()
 }
 
 // FingerprintWithTitle identifies technologies on a target and returns
 // the title of the page along with the technologies.
 func (w *Wappalyze) FingerprintWithTitle(headers map[string][]string, body []byte) (map[string]struct{}, string) {
-	technologies := w.Fingerprint(headers, body)
-	title := parser.ExtractTitle(body)
-	return technologies, title
+	result := w.current().analyze(headers, body, analyzeTitle)
+	return result.Set(), result.Title
 }
 
 // FingerprintWithCategories identifies technologies on a target and returns
 // additional human-readable category information about each detected technology.
 func (w *Wappalyze) FingerprintWithCategories(headers map[string][]string, body []byte) map[string][]string {
-	technologies := make(map[string]struct{})
-	w.detectTechnologies(headers, body, technologies)
-	w.addImpliedTechnologies(technologies)
-
-	result := make(map[string][]string)
-	for technology := range technologies {
-		catIDs, ok := w.categoryMapping[technology]
-		if !ok {
-			result[technology] = []string{}
-			continue
-		}
-
-		catNames := make([]string, 0, len(catIDs))
-		for _, catID := range catIDs {
-			if cat, ok := categoriesMapping[catID]; ok {
-				catNames = append(catNames, cat.Name)
-			}
-		}
-		result[technology] = catNames
-	}
-	return result
+	return w.current().analyze(headers, body, 0).CategoryNames()
 }
 
 // FingerprintWithGroups identifies technologies on a target and returns
 // additional human-readable group information about each detected technology.
 func (w *Wappalyze) FingerprintWithGroups(headers map[string][]string, body []byte) map[string][]string {
-	technologies := make(map[string]struct{})
-	w.detectTechnologies(headers, body, technologies)
-	w.addImpliedTechnologies(technologies)
-
-	result := make(map[string][]string)
-	for technology := range technologies {
-		catIDs, ok := w.categoryMapping[technology]
-		if !ok {
-			result[technology] = []string{}
-			continue
-		}
-
-		// Collect all unique group IDs for this technology's categories
-		groupIDsMap := make(map[int]struct{})
-		for _, catID := range catIDs {
-			if groupIDs, ok := categoryToGroups[catID]; ok {
-				for _, groupID := range groupIDs {
-					groupIDsMap[groupID] = struct{}{}
-				}
-			}
-		}
-
-		// Convert group IDs to names
-		groupNames := make([]string, 0, len(groupIDsMap))
-		for groupID := range groupIDsMap {
-			if group, ok := groupsMapping[groupID]; ok {
-				groupNames = append(groupNames, group.Name)
-			}
-		}
-
-		result[technology] = groupNames
-	}
-	return result
+	return w.current().analyze(headers, body, 0).GroupNames()
 }
 
 // FingerprintWithTechInfo identifies technologies on a target and returns
-// comprehensive information including categories and groups.
+// comprehensive information including categories, groups and versions.
 func (w *Wappalyze) FingerprintWithTechInfo(headers map[string][]string, body []byte) map[string]TechInfo {
-	technologies := make(map[string]struct{})
-	w.detectTechnologies(headers, body, technologies)
-	w.addImpliedTechnologies(technologies)
-
-	result := make(map[string]TechInfo)
-	for technology := range technologies {
-		techInfo := TechInfo{
-			Name:        technology,
-			Description: "",
-			Website:     "",
-			Categories:  []string{},
-			Groups:      []string{},
-			Confidence:  100,
-		}
-
-		// Add tech details if available
-		if app, ok := w.fingerprints.Apps[technology]; ok {
-			techInfo.Description = app.Description
-			techInfo.Website = app.Website
-			techInfo.CPE = app.CPE
-		}
-
-		// Add category information
-		if catIDs, ok := w.categoryMapping[technology]; ok {
-			categoryNames := make([]string, 0, len(catIDs))
-			groupIDsMap := make(map[int]struct{})
-
-			for _, catID := range catIDs {
-				if cat, ok := categoriesMapping[catID]; ok {
-					categoryNames = append(categoryNames, cat.Name)
-
-					// Collect groups for this category
-					if groupIDs, ok := categoryToGroups[catID]; ok {
-						for _, groupID := range groupIDs {
-							groupIDsMap[groupID] = struct{}{}
-						}
-					}
-				}
-			}
-
-			techInfo.Categories = categoryNames
-
-			// Convert group IDs to names
-			groupNames := make([]string, 0, len(groupIDsMap))
-			for groupID := range groupIDsMap {
-				if group, ok := groupsMapping[groupID]; ok {
-					groupNames = append(groupNames, group.Name)
-				}
-			}
-			techInfo.Groups = groupNames
-		}
-
-		result[technology] = techInfo
-	}
-	return result
+	return w.current().analyze(headers, body, analyzeVersions|analyzeConfidence).TechInfo()
 }
 
 // organizePatterns extracts patterns from compiled fingerprints and organizes them
 // for efficient matching
-func (w *Wappalyze) organizePatterns() error {
-	for name, app := range w.fingerprints.Apps {
+func (e *engine) organizePatterns() error {
+	for name, app := range e.fingerprints.Apps {
 		// Organize header patterns
 		if len(app.HeaderPatterns) > 0 {
-			w.headerPatterns[name] = app.HeaderPatterns
+			e.headerPatterns[name] = app.HeaderPatterns
 		}
 
 		// Organize cookie patterns
 		if len(app.CookiePatterns) > 0 {
-			w.cookiePatterns[name] = app.CookiePatterns
+			e.cookiePatterns[name] = app.CookiePatterns
 		}
 
 		// Organize HTML patterns
 		if len(app.HTMLPatterns) > 0 {
-			w.htmlPatterns[name] = app.HTMLPatterns
+			e.htmlPatterns[name] = app.HTMLPatterns
 		}
 
 		// Organize script patterns
 		if len(app.ScriptPatterns) > 0 {
-			w.scriptPatterns[name] = app.ScriptPatterns
+			e.scriptPatterns[name] = app.ScriptPatterns
 		}
 
 		// Organize script src patterns
 		if len(app.ScriptSrcPatterns) > 0 {
-			w.scriptSrcPatterns[name] = app.ScriptSrcPatterns
+			e.scriptSrcPatterns[name] = app.ScriptSrcPatterns
 		}
 
 		// Organize meta patterns
 		if len(app.MetaPatterns) > 0 {
-			if _, ok := w.metaPatterns[name]; !ok {
-				w.metaPatterns[name] = make(map[string][]*models.ParsedPattern)
+			if _, ok := e.metaPatterns[name]; !ok {
+				e.metaPatterns[name] = make(map[string][]*models.ParsedPattern)
 			}
 
 			for meta, patterns := range app.MetaPatterns {
-				w.metaPatterns[name][meta] = patterns
+				e.metaPatterns[name][meta] = patterns
 			}
 		}
 
 		// Organize JS patterns
 		if len(app.JSPatterns) > 0 {
-			w.jsPatterns[name] = app.JSPatterns
+			e.jsPatterns[name] = app.JSPatterns
 		}
 	}
 
 	return nil
 }
 
+// buildBodyMatchers builds the literal prefiltered matchers for body patterns,
+// restoring them from the database's prefilter index when one is given
+func (e *engine) buildBodyMatchers(db *database.Database) error {
+	if db == nil || db.HTMLIndex == nil || db.ScriptIndex == nil {
+		e.htmlMatcher = parser.CompileBodyMatcher(e.htmlPatterns)
+		e.scriptMatcher = parser.CompileBodyMatcher(e.scriptPatterns)
+		return nil
+	}
+
+	var err error
+	if e.htmlMatcher, err = parser.NewBodyMatcherFromIndex(e.htmlPatterns, db.HTMLIndex); err != nil {
+		return fmt.Errorf("invalid HTML index: %v", err)
+	}
+	if e.scriptMatcher, err = parser.NewBodyMatcherFromIndex(e.scriptPatterns, db.ScriptIndex); err != nil {
+		return fmt.Errorf("invalid script index: %v", err)
+	}
+	return nil
+}
+
 // buildImpliesMapping builds a mapping of technology to implied technologies
-func (w *Wappalyze) buildImpliesMapping() {
-	for name, app := range w.fingerprints.Apps {
+func (e *engine) buildImpliesMapping() {
+	for name, app := range e.fingerprints.Apps {
 		if len(app.ImpliedTechs) > 0 {
-			w.impliesMapping[name] = app.ImpliedTechs
+			e.impliesMapping[name] = app.ImpliedTechs
 		}
 	}
 }
 
 // buildCategoryMapping builds a mapping of technology to categories
-func (w *Wappalyze) buildCategoryMapping() {
-	for name, app := range w.fingerprints.Apps {
+func (e *engine) buildCategoryMapping() {
+	for name, app := range e.fingerprints.Apps {
 		if len(app.Cats) > 0 {
-			w.categoryMapping[name] = app.Cats
+			e.categoryMapping[name] = app.Cats
 		}
 	}
 }
 
-// detectTechnologies performs the actual technology detection
-func (w *Wappalyze) detectTechnologies(headers map[string][]string, body []byte, technologies map[string]struct{}) {
+// decodeBody undoes the Content-Encoding of the body and converts it to UTF-8.
+// Decoding is best effort: on failure the matchers get the most decoded form available.
+// The decoded body is truncated to MaxBodySize, as FingerprintReader does.
+func (e *engine) decodeBody(headers map[string][]string, body []byte) []byte {
+	if !e.config.DisableBodyDecoding {
+		body, _ = decoder.DecodeBody(headers, body, int64(e.config.MaxBodySize))
+	}
+
+	if e.config.MaxBodySize > 0 && len(body) > e.config.MaxBodySize {
+		body = body[:e.config.MaxBodySize]
+	}
+	return body
+}
+
+// matchTechnologies runs every enabled matcher against an already decoded
+// body. Unless evidence is nil, the kinds of data each technology was
+// detected from are recorded in it.
+func (e *engine) matchTechnologies(headers map[string][]string, body []byte, technologies map[string]struct{}, evidence map[string][]string) {
+	// With evidence, each matcher reports into a set of its own
+	match := func(source string, matcher func(found map[string]struct{})) {
+		if evidence == nil {
+			matcher(technologies)
+			return
+		}
+		found := make(map[string]struct{})
+		matcher(found)
+		for technology := range found {
+			technologies[technology] = struct{}{}
+			evidence[technology] = append(evidence[technology], source)
+		}
+	}
+
 	// Match based on headers
-	detection.MatchHeaders(w.headerPatterns, headers, technologies)
+	match(EvidenceHeaders, func(found map[string]struct{}) {
+		detection.MatchHeaders(e.headerPatterns, headers, found)
+	})
 
 	// Extract cookies from headers and match
-	cookies := detection.ExtractCookiesFromHeaders(headers)
-	detection.MatchCookies(w.cookiePatterns, cookies, technologies)
+	match(EvidenceCookies, func(found map[string]struct{}) {
+		cookies := detection.ExtractCookiesFromHeaders(headers)
+		detection.MatchCookies(e.cookiePatterns, cookies, found)
+	})
 
 	// Skip HTML-based detection if disabled
-	if !w.config.DisableHTMLDetection {
+	if !e.config.DisableHTMLDetection {
 		// Match based on HTML patterns
-		detection.MatchHTML(w.htmlPatterns, body, technologies)
+		match(EvidenceHTML, func(found map[string]struct{}) {
+			e.htmlMatcher.Match(body, found)
+		})
 	}
 
 	// Skip script detection if disabled
-	if !w.config.DisableScriptDetection {
+	if !e.config.DisableScriptDetection {
 		// Match based on script patterns
-		detection.MatchScripts(w.scriptPatterns, body, technologies)
+		match(EvidenceScripts, func(found map[string]struct{}) {
+			e.scriptMatcher.Match(body, found)
+		})
 
 		// Extract and match script sources
-		scripts := parser.ExtractScripts(body)
-		detection.MatchScriptSrc(w.scriptSrcPatterns, scripts, technologies)
+		match(EvidenceScriptSrc, func(found map[string]struct{}) {
+			scripts := parser.ExtractScripts(body)
+			detection.MatchScriptSrc(e.scriptSrcPatterns, scripts, found)
+		})
 	}
 
 	// Skip meta tag detection if disabled
-	if !w.config.DisableMetaDetection {
+	if !e.config.DisableMetaDetection {
 		// Extract and match meta tags
-		metaTags := parser.ExtractMetaTags(body)
-		detection.MatchMetaTags(w.metaPatterns, metaTags, technologies)
+		match(EvidenceMeta, func(found map[string]struct{}) {
+			metaTags := parser.ExtractMetaTags(body)
+			detection.MatchMetaTags(e.metaPatterns, metaTags, found)
+		})
 	}
 
 	// Skip JS detection if disabled
-	if !w.config.DisableJSDetection {
+	if !e.config.DisableJSDetection {
 		// Extract and match JS patterns
-		jsPatterns := parser.ExtractJS(body)
-		detection.MatchJS(w.jsPatterns, jsPatterns, technologies)
+		match(EvidenceJS, func(found map[string]struct{}) {
+			jsPatterns := parser.ExtractJS(body)
+			detection.MatchJS(e.jsPatterns, jsPatterns, found)
+		})
 	}
 }
 
 // addImpliedTechnologies adds technologies that are implied by detected ones
-func (w *Wappalyze) addImpliedTechnologies(technologies map[string]struct{}) {
+func (e *engine) addImpliedTechnologies(technologies map[string]struct{}) {
 	var queue []string
 	for tech := range technologies {
 		queue = append(queue, tech)
@@ -643,7 +590,7 @@ func (w *Wappalyze) addImpliedTechnologies(technologies map[string]struct{}) {
 		var current string
 		current, queue = queue[0], queue[1:]
 
-		if implies, ok := w.impliesMapping[current]; ok {
+		if implies, ok := e.impliesMapping[current]; ok {
 			for _, implied := range implies {
 				if _, exists := technologies[implied]; !exists {
 					technologies[implied] = struct{}{}
@@ -656,10 +603,12 @@ func (w *Wappalyze) addImpliedTechnologies(technologies map[string]struct{}) {
 
 // GetTechByGroup returns a list of technologies belonging to a specific group
 func (w *Wappalyze) GetTechByGroup(groupID int) []string {
+	e := w.current()
+
 	var result []string
 
 	// Get categories in this group
-	categories := GetGroupCategories(groupID)
+	categories := e.catalog.groupCategories[groupID]
 	if len(categories) == 0 {
 		return result
 	}
@@ -671,7 +620,7 @@ func (w *Wappalyze) GetTechByGroup(groupID int) []string {
 	}
 
 	// Find technologies with these categories
-	for tech, catIDs := range w.categoryMapping {
+	for tech, catIDs := range e.categoryMapping {
 		for _, catID := range catIDs {
 			if _, ok := categoryMap[catID]; ok {
 				result = append(result, tech)
@@ -685,91 +634,51 @@ func (w *Wappalyze) GetTechByGroup(groupID int) []string {
 
 // AnalyzeURL fetches the given URL and performs technology detection
 func (w *Wappalyze) AnalyzeURL(url string) (map[string]struct{}, error) {
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "http://" + url
-	}
-
-	resp, err := http.DefaultClient.Get(url)
+	headers, body, err := w.fetchURL(url)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching URL: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Limit the body size if configured
-	var reader io.Reader = resp.Body
-	if w.config.MaxBodySize > 0 {
-		reader = io.LimitReader(resp.Body, int64(w.config.MaxBodySize))
-	}
-
-	body, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return nil, err
 	}
-
-	return w.Fingerprint(resp.Header, body), nil
+	return w.Fingerprint(headers, body), nil
 }
 
 // AnalyzeURLWithInfo fetches the given URL and performs technology detection with additional info
 func (w *Wappalyze) AnalyzeURLWithInfo(url string) (map[string]models.AppInfo, error) {
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "http://" + url
-	}
-
-	resp, err := http.DefaultClient.Get(url)
+	headers, body, err := w.fetchURL(url)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching URL: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Limit the body size if configured
-	var reader io.Reader = resp.Body
-	if w.config.MaxBodySize > 0 {
-		reader = io.LimitReader(resp.Body, int64(w.config.MaxBodySize))
-	}
-
-	body, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return nil, err
 	}
-
-	return w.FingerprintWithInfo(resp.Header, body), nil
+	return w.FingerprintWithInfo(headers, body), nil
 }
 
 // AnalyzeURLWithCats fetches the given URL and performs technology detection with category info
 func (w *Wappalyze) AnalyzeURLWithCats(url string) (map[string]models.CatsInfo, error) {
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "http://" + url
-	}
-
-	resp, err := http.DefaultClient.Get(url)
+	headers, body, err := w.fetchURL(url)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching URL: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Limit the body size if configured
-	var reader io.Reader = resp.Body
-	if w.config.MaxBodySize > 0 {
-		reader = io.LimitReader(resp.Body, int64(w.config.MaxBodySize))
+		return nil, err
 	}
+	return w.FingerprintWithCats(headers, body), nil
+}
 
-	body, err := io.ReadAll(reader)
+// AnalyzeURLWithTitle fetches the given URL and performs technology detection with page title
+func (w *Wappalyze) AnalyzeURLWithTitle(url string) (map[string]struct{}, string, error) {
+	headers, body, err := w.fetchURL(url)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return nil, "", err
 	}
-
-	return w.FingerprintWithCats(resp.Header, body), nil
+	techs, title := w.FingerprintWithTitle(headers, body)
+	return techs, title, nil
 }
 
-// AnalyzeURLWithTitle fetches the given URL and performs technology detection with page title
-func (w *Wappalyze) AnalyzeURLWithTitle(url string) (map[string]struct{}, string, error) {
+// fetchURL fetches the given URL and reads its body, at most MaxBodySize
+// bytes of it as sent
+func (w *Wappalyze) fetchURL(url string) (map[string][]string, []byte, error) {
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
 		url = "http://" + url
 	}
 
 	resp, err := http.DefaultClient.Get(url)
 	if err != nil {
-		return nil, "", fmt.Errorf("error fetching URL: %v", err)
+		return nil, nil, fmt.Errorf("error fetching URL: %v", err)
 	}
 	defer resp.Body.Close()
 
@@ -781,9 +690,7 @@ func (w *Wappalyze) AnalyzeURLWithTitle(url string) (map[string]struct{}, string
 
 	body, err := io.ReadAll(reader)
 	if err != nil {
-		return nil, "", fmt.Errorf("error reading response body: %v", err)
+		return nil, nil, fmt.Errorf("error reading response body: %v", err)
 	}
-
-	techs, title := w.FingerprintWithTitle(resp.Header, body)
-	return techs, title, nil
+	return resp.Header, body, nil
 }